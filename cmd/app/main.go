@@ -4,6 +4,7 @@ import (
 	"first-task/internal/client"
 	"first-task/internal/config"
 	"first-task/pkg/logger"
+	"first-task/pkg/mask"
 	"flag"
 
 	_ "first-task/docs"
@@ -19,6 +20,11 @@ import (
 // @host localhost:8080
 // @BasePath /
 
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description "Bearer <api key or jwt>"
+
 func main() {
 	configFile := flag.String("c", "./config.yml", ".yml config file")
 	flag.Parse()
@@ -26,6 +32,10 @@ func main() {
 	zap.ReplaceGlobals(logger.SetupLogger())
 
 	cfg := config.MustLoad(*configFile)
+	// customer data never reaches log files
+	zap.ReplaceGlobals(zap.L().WithOptions(
+		logger.Redact(mask.New(cfg.PrivacyConfig.SensitiveFields)),
+	))
 
 	c := client.NewClient(cfg)
 	c.Init()
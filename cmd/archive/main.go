@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"first-task/internal/archive"
+	"first-task/internal/config"
+	"first-task/internal/storage/fieldcrypt"
+	"first-task/internal/storage/postgres"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// exports orders older than cutoff to compressed files, verifies and restores
+// archives made by this tool or by archiver of the app
+func main() {
+	configFile := flag.String("c", "./config.yml", ".yml config file")
+	dir := flag.String("dir", "", "archives directory, archive.dir of config by default")
+	format := flag.String("format", "", "ndjson or columnar, archive.format of config by default")
+	before := flag.String(
+		"before", "", "export orders created before this date (2006-01-02 or RFC3339)",
+	)
+	olderThan := flag.Duration(
+		"older-than", 0, "export orders older than this, archive.older_than of config by default",
+	)
+	verify := flag.String("verify", "", "check checksums of archive in this directory")
+	restore := flag.String("restore", "", "import archive from this directory to postgres")
+	flag.Parse()
+
+	cfg := config.MustLoad(*configFile)
+	if *dir != "" {
+		cfg.ArchiveConfig.Dir = *dir
+	}
+	if *format != "" {
+		cfg.ArchiveConfig.Format = *format
+	}
+
+	ctx, stop := signal.NotifyContext(
+		context.Background(), syscall.SIGINT, syscall.SIGTERM,
+	)
+	defer stop()
+
+	switch {
+	case *verify != "":
+		m, err := archive.Verify(*verify)
+		if err != nil {
+			fail(err)
+		}
+		fmt.Printf("archive is ok: %d orders in %d files\n", m.Orders, len(m.Files))
+
+	case *restore != "":
+		cipher := fieldcrypt.NewCipher(cfg.EncryptionConfig)
+		db := postgres.NewPostgres(cfg.PostgresConfig)
+		db.SetCipher(cipher)
+		defer db.Shutdown()
+
+		restored, skipped, err := archive.Restore(ctx, *restore, db, cipher)
+		if err != nil {
+			fail(fmt.Errorf("stopped after %d orders: %w", restored, err))
+		}
+		fmt.Printf("%d orders restored, %d already exist\n", restored, skipped)
+
+	default:
+		cutoff := time.Now().Add(-cfg.ArchiveConfig.OlderThan)
+		if *olderThan > 0 {
+			cutoff = time.Now().Add(-*olderThan)
+		}
+		if *before != "" {
+			var err error
+			if cutoff, err = parseDate(*before); err != nil {
+				fail(err)
+			}
+		}
+
+		from, err := archive.LastCutoff(cfg.ArchiveConfig.Dir)
+		if err != nil {
+			fail(err)
+		}
+		if !cutoff.After(from) {
+			fmt.Printf("orders before %s are already archived\n", from.Format(time.RFC3339))
+			return
+		}
+
+		// orders are exported sealed, so cipher isn't needed
+		db := postgres.NewPostgres(cfg.PostgresConfig)
+		defer db.Shutdown()
+
+		path, m, err := archive.NewArchiver(db, cfg.ArchiveConfig).Export(ctx, from, cutoff)
+		if err != nil {
+			fail(err)
+		}
+		fmt.Printf("%d orders in %d files archived to %s\n", m.Orders, len(m.Files), path)
+	}
+}
+
+func parseDate(s string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err.Error())
+	os.Exit(1)
+}
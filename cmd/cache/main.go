@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"first-task/internal/config"
+	"first-task/internal/storage/redisStorage"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// deletes only keys of this service from redis, other apps in shared db are
+// not touched
+func main() {
+	configFile := flag.String("c", "./config.yml", ".yml config file")
+	stale := flag.Bool(
+		"stale", false, "delete only keys with version other than key_version",
+	)
+	dryRun := flag.Bool("dry-run", false, "only count keys, don't delete")
+	flag.Parse()
+
+	cfg := config.MustLoad(*configFile)
+
+	ctx, stop := signal.NotifyContext(
+		context.Background(), syscall.SIGINT, syscall.SIGTERM,
+	)
+	defer stop()
+
+	rs := redisStorage.NewRedisStorage(cfg.RedisConfig)
+	defer rs.Shutdown()
+
+	n, err := rs.Purge(ctx, *stale, *dryRun)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "purge stopped after %d keys: %s\n", n, err.Error())
+		os.Exit(1)
+	}
+
+	if *dryRun {
+		fmt.Printf("%d keys would be deleted\n", n)
+		return
+	}
+	fmt.Printf("%d keys deleted\n", n)
+}
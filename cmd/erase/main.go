@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"first-task/internal/client"
+	"first-task/internal/config"
+	"first-task/internal/storage"
+	"first-task/internal/storage/postgres"
+	"first-task/internal/storage/redisStorage"
+	"first-task/internal/storage/sqlite"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// deletes one order or erases all orders of customer by request of data
+// subject, erasure of customer is written to audit table
+func main() {
+	configFile := flag.String("c", "./config.yml", ".yml config file")
+	customer := flag.String("customer", "", "customer_id whose orders are erased")
+	orderUID := flag.String("order", "", "order_uid of order to delete")
+	mode := flag.String("mode", storage.ErasureAnonymize, "delete or anonymize, for -customer only")
+	reason := flag.String("reason", "", "reason of erasure written to audit")
+	actor := flag.String("actor", os.Getenv("USER"), "who requested erasure")
+	flag.Parse()
+
+	if (*customer == "") == (*orderUID == "") {
+		fail(fmt.Errorf("exactly one of -customer and -order is needed"))
+	}
+
+	cfg := config.MustLoad(*configFile)
+
+	ctx, stop := signal.NotifyContext(
+		context.Background(), syscall.SIGINT, syscall.SIGTERM,
+	)
+	defer stop()
+
+	var dbs storage.DataBaser
+	switch cfg.Storage {
+	case "", client.StoragePostgres:
+		dbs = postgres.NewPostgres(cfg.PostgresConfig)
+	case client.StorageSQLite:
+		dbs = sqlite.NewSQLite(cfg.SQLiteConfig)
+	default:
+		fail(fmt.Errorf("unknown storage: %s", cfg.Storage))
+	}
+
+	// cache is cleaned too, otherwise erased order is served until ttl
+	str := storage.NewStorage(
+		redisStorage.NewRedisStorage(cfg.RedisConfig), dbs, cfg.TimeoutsConfig,
+	)
+	defer str.Shutdown()
+	str.SetFileArchives(cfg.ArchiveConfig.Enabled)
+
+	if *orderUID != "" {
+		if err := str.DeleteOrder(ctx, *orderUID); err != nil {
+			fail(err)
+		}
+		fmt.Printf("order %s deleted\n", *orderUID)
+		return
+	}
+
+	er, err := str.EraseCustomer(ctx, *customer, *mode, *actor, *reason)
+	if err != nil {
+		fail(err)
+	}
+	out, err := json.MarshalIndent(er, "", "  ")
+	if err != nil {
+		fail(err)
+	}
+	fmt.Println(string(out))
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err.Error())
+	os.Exit(1)
+}
@@ -7,6 +7,7 @@ import (
 	item "first-task/internal/entities/Item"
 	order "first-task/internal/entities/Order"
 	payment "first-task/internal/entities/Payment"
+	"first-task/internal/schema"
 	"fmt"
 	"math/rand"
 	"net"
@@ -177,6 +178,10 @@ func main() {
 			kafka.Message{
 				Key:   []byte(ord.OrderUID),
 				Value: kafkaValue,
+				Headers: []kafka.Header{{
+					Key:   schema.Header,
+					Value: []byte(strconv.Itoa(schema.CurrentVersion)),
+				}},
 			},
 		)
 		if err != nil {
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"first-task/internal/client"
+	"first-task/internal/config"
+	"first-task/internal/storage/fieldcrypt"
+	"first-task/internal/storage/postgres"
+	"first-task/internal/storage/sqlite"
+	"first-task/pkg/envelope"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+type resealer interface {
+	ResealDeliveries(ctx context.Context, batchSize int) (int, error)
+	Shutdown()
+}
+
+// adds new primary key to key file or re-encrypts delivery rows of db by
+// primary key, fields which aren't in encryption.fields are decrypted
+func main() {
+	configFile := flag.String("c", "./config.yml", ".yml config file")
+	rotate := flag.String("rotate", "", "add new primary key with this id to key file")
+	batch := flag.Int("batch", 1000, "rows in one transaction")
+	flag.Parse()
+
+	cfg := config.MustLoad(*configFile)
+	if cfg.EncryptionConfig.KeyFile == "" {
+		fail(fieldcrypt.ErrNoKeys)
+	}
+
+	if *rotate != "" {
+		kf, err := envelope.ReadKeyFile(cfg.EncryptionConfig.KeyFile)
+		if errors.Is(err, os.ErrNotExist) {
+			kf, err = new(envelope.KeyFile), nil
+		}
+		if err != nil {
+			fail(err)
+		}
+		if err := kf.Rotate(*rotate); err != nil {
+			fail(err)
+		}
+		if err := kf.Write(cfg.EncryptionConfig.KeyFile); err != nil {
+			fail(err)
+		}
+		fmt.Printf(
+			"key %s is primary in %s, restart the app and run this command without -rotate\n",
+			*rotate, cfg.EncryptionConfig.KeyFile,
+		)
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(
+		context.Background(), syscall.SIGINT, syscall.SIGTERM,
+	)
+	defer stop()
+
+	fc := fieldcrypt.NewCipher(cfg.EncryptionConfig)
+
+	var db resealer
+	switch cfg.Storage {
+	case "", client.StoragePostgres:
+		pg := postgres.NewPostgres(cfg.PostgresConfig)
+		pg.SetCipher(fc)
+		db = pg
+	case client.StorageSQLite:
+		sl := sqlite.NewSQLite(cfg.SQLiteConfig)
+		sl.SetCipher(fc)
+		db = sl
+	default:
+		fail(fmt.Errorf("unknown storage: %s", cfg.Storage))
+	}
+	defer db.Shutdown()
+
+	n, err := db.ResealDeliveries(ctx, *batch)
+	if err != nil {
+		fail(fmt.Errorf("stopped after %d rows: %w", n, err))
+	}
+	fmt.Printf("%d delivery rows re-encrypted\n", n)
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err.Error())
+	os.Exit(1)
+}
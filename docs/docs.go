@@ -16,9 +16,168 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/admin/customers/{customer_id}/erase": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "delete or anonymize all orders of customer, returns audit record",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "EraseCustomerAPI",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Идентификатор покупателя",
+                        "name": "customer_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Режим удаления",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.EraseRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Данные удалены",
+                        "schema": {
+                            "$ref": "#/definitions/storage.Erasure"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный режим",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Нет ключа или токена",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Нет scope admin",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Слишком много запросов",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/orders/{order_uid}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "delete order with delivery, payment and items",
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "DeleteOrderAPI",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Уникальный номер заказа",
+                        "name": "order_uid",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Заказ удален"
+                    },
+                    "401": {
+                        "description": "Нет ключа или токена",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Нет scope admin",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Заказ не найден",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Слишком много запросов",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/healthz": {
+            "get": {
+                "description": "liveness, process answers requests, dependencies aren't checked",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Health"
+                ],
+                "summary": "Healthz",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.LivenessResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/order/{order_uid}": {
             "get": {
-                "description": "get order",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "get order, customer data is masked without admin scope",
                 "produces": [
                     "application/json"
                 ],
@@ -42,8 +201,14 @@ const docTemplate = `{
                             "$ref": "#/definitions/order.Order"
                         }
                     },
-                    "400": {
-                        "description": "Плохой запрос",
+                    "401": {
+                        "description": "Нет ключа или токена",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Нет scope orders:read",
                         "schema": {
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
@@ -54,6 +219,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/handlers.ErrorResponse"
                         }
                     },
+                    "429": {
+                        "description": "Слишком много запросов",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Ошибка сервера",
                         "schema": {
@@ -62,26 +233,60 @@ const docTemplate = `{
                     }
                 }
             }
+        },
+        "/readyz": {
+            "get": {
+                "description": "readiness, status and latency of every dependency and cache warmup",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Health"
+                ],
+                "summary": "Readyz",
+                "responses": {
+                    "200": {
+                        "description": "Готов принимать трафик",
+                        "schema": {
+                            "$ref": "#/definitions/health.Report"
+                        }
+                    },
+                    "503": {
+                        "description": "Зависимость недоступна или кеш не прогрет",
+                        "schema": {
+                            "$ref": "#/definitions/health.Report"
+                        }
+                    }
+                }
+            }
         }
     },
     "definitions": {
         "delivery.Delivery": {
             "type": "object",
+            "required": [
+                "address",
+                "city",
+                "email",
+                "name",
+                "phone",
+                "region"
+            ],
             "properties": {
                 "address": {
-                    "type": "string"
+                    "type": "string",
+                    "minLength": 2
                 },
                 "city": {
-                    "type": "string"
+                    "type": "string",
+                    "minLength": 2
                 },
                 "email": {
                     "type": "string"
                 },
-                "id": {
-                    "type": "integer"
-                },
                 "name": {
-                    "type": "string"
+                    "type": "string",
+                    "minLength": 2
                 },
                 "phone": {
                     "type": "string"
@@ -94,6 +299,18 @@ const docTemplate = `{
                 }
             }
         },
+        "handlers.EraseRequest": {
+            "type": "object",
+            "properties": {
+                "mode": {
+                    "description": "delete or anonymize",
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                }
+            }
+        },
         "handlers.ErrorResponse": {
             "type": "object",
             "properties": {
@@ -105,17 +322,69 @@ const docTemplate = `{
                 }
             }
         },
+        "handlers.LivenessResponse": {
+            "type": "object",
+            "properties": {
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "health.CheckResult": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "latency_ms": {
+                    "type": "number"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "health.Report": {
+            "type": "object",
+            "properties": {
+                "checks": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/health.CheckResult"
+                    }
+                },
+                "ready": {
+                    "type": "boolean"
+                }
+            }
+        },
         "item.Item": {
             "type": "object",
+            "required": [
+                "brand",
+                "chrt_id",
+                "name",
+                "nm_id",
+                "price",
+                "rid",
+                "size",
+                "status",
+                "total_price",
+                "track_number"
+            ],
             "properties": {
                 "brand": {
-                    "type": "string"
+                    "type": "string",
+                    "maxLength": 50,
+                    "minLength": 2
                 },
                 "chrt_id": {
                     "type": "integer"
                 },
                 "name": {
-                    "type": "string"
+                    "type": "string",
+                    "maxLength": 100,
+                    "minLength": 2
                 },
                 "nm_id": {
                     "type": "integer"
@@ -127,7 +396,8 @@ const docTemplate = `{
                     "type": "string"
                 },
                 "sale": {
-                    "type": "integer"
+                    "type": "integer",
+                    "maximum": 100
                 },
                 "size": {
                     "type": "string"
@@ -145,6 +415,21 @@ const docTemplate = `{
         },
         "order.Order": {
             "type": "object",
+            "required": [
+                "customer_id",
+                "date_created",
+                "delivery",
+                "delivery_service",
+                "entry",
+                "items",
+                "locale",
+                "oof_shard",
+                "order_uid",
+                "payment",
+                "shardkey",
+                "sm_id",
+                "track_number"
+            ],
             "properties": {
                 "customer_id": {
                     "type": "string"
@@ -166,12 +451,19 @@ const docTemplate = `{
                 },
                 "items": {
                     "type": "array",
+                    "minItems": 1,
                     "items": {
                         "$ref": "#/definitions/item.Item"
                     }
                 },
                 "locale": {
-                    "type": "string"
+                    "type": "string",
+                    "enum": [
+                        "ru",
+                        "en",
+                        "fr",
+                        "de"
+                    ]
                 },
                 "oof_shard": {
                     "type": "string"
@@ -195,6 +487,15 @@ const docTemplate = `{
         },
         "payment.Payment": {
             "type": "object",
+            "required": [
+                "amount",
+                "bank",
+                "currency",
+                "goods_total",
+                "payment_dt",
+                "provider",
+                "transaction"
+            ],
             "properties": {
                 "amount": {
                     "type": "number"
@@ -206,16 +507,16 @@ const docTemplate = `{
                     "type": "string"
                 },
                 "custom_fee": {
-                    "type": "number"
+                    "type": "number",
+                    "minimum": 0
                 },
                 "delivery_cost": {
-                    "type": "number"
+                    "type": "number",
+                    "minimum": 0
                 },
                 "goods_total": {
-                    "type": "number"
-                },
-                "id": {
-                    "type": "integer"
+                    "type": "number",
+                    "minimum": 0
                 },
                 "payment_dt": {
                     "type": "integer"
@@ -230,6 +531,47 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "storage.Erasure": {
+            "type": "object",
+            "properties": {
+                "actor": {
+                    "type": "string"
+                },
+                "archive_files_kept": {
+                    "description": "archive files may still keep data of erased orders",
+                    "type": "boolean"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "customer_id": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "mode": {
+                    "type": "string"
+                },
+                "order_uids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "reason": {
+                    "type": "string"
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "BearerAuth": {
+            "description": "\"Bearer \u003capi key or jwt\u003e\"",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
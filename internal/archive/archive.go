@@ -0,0 +1,368 @@
+package archive
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"first-task/internal/config"
+	order "first-task/internal/entities/Order"
+	"first-task/internal/storage"
+	"first-task/internal/storage/fieldcrypt"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	ManifestFile = "manifest.json"
+
+	DefaultBatchSize = 10000
+
+	dirPrefix  = "orders_"
+	timeLayout = "20060102T150405Z"
+)
+
+var ErrChecksum = errors.New("checksum mismatch")
+
+// Source returns orders created in [from, to) by pages ordered by id
+type Source interface {
+	OrdersBetween(
+		ctx context.Context, from, to time.Time, afterID int64, limit int,
+	) ([]*order.Order, int64, error)
+}
+
+type Manifest struct {
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+	CreatedAt time.Time `json:"created_at"`
+	Format    string    `json:"format"`
+	Orders    int       `json:"orders"`
+	Files     []File    `json:"files"`
+}
+
+type File struct {
+	Name   string `json:"name"`
+	Orders int    `json:"orders"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Archiver exports orders to <dir>/orders_<from>_<to>/ with one compressed
+// file per batch and manifest.json
+type Archiver struct {
+	src Source
+	cfg config.ArchiveConfig
+	now func() time.Time
+}
+
+// if format is unknown throw panic
+func NewArchiver(src Source, cfg config.ArchiveConfig) *Archiver {
+	if _, ok := formats[cfg.Format]; !ok {
+		panic("unknown archive format: " + cfg.Format)
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = 24 * time.Hour
+	}
+
+	return &Archiver{
+		src: src,
+		cfg: cfg,
+		now: time.Now,
+	}
+}
+
+// Export writes orders created in [from, to) to new archive directory and
+// returns its path. Files are written to temporary directory which is renamed
+// after manifest, so there is no archive without manifest.
+func (a *Archiver) Export(ctx context.Context, from, to time.Time) (string, *Manifest, error) {
+	const op = "internal.archive.Export"
+
+	f := formats[a.cfg.Format]
+	from, to = from.UTC(), to.UTC()
+	name := dirPrefix + from.Format(timeLayout) + "_" + to.Format(timeLayout)
+	dst := filepath.Join(a.cfg.Dir, name)
+	tmp := filepath.Join(a.cfg.Dir, "."+name+".tmp")
+
+	if err := os.RemoveAll(tmp); err != nil {
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := os.MkdirAll(tmp, 0o755); err != nil {
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer os.RemoveAll(tmp)
+
+	m := &Manifest{
+		From:   from,
+		To:     to,
+		Format: a.cfg.Format,
+		Files:  make([]File, 0),
+	}
+
+	var afterID int64
+	for {
+		ords, lastID, err := a.src.OrdersBetween(ctx, from, to, afterID, a.cfg.BatchSize)
+		if err != nil {
+			return "", nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if len(ords) == 0 {
+			break
+		}
+		afterID = lastID
+
+		fileName := fmt.Sprintf("part-%05d%s", len(m.Files), f.ext)
+		file, err := writeFile(filepath.Join(tmp, fileName), f, ords)
+		if err != nil {
+			return "", nil, fmt.Errorf("%s: %s: %w", op, fileName, err)
+		}
+		m.Files = append(m.Files, file)
+		m.Orders += file.Orders
+
+		if len(ords) < a.cfg.BatchSize {
+			break
+		}
+	}
+
+	m.CreatedAt = a.now().UTC()
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := os.WriteFile(filepath.Join(tmp, ManifestFile), data, 0o644); err != nil {
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return dst, m, nil
+}
+
+func writeFile(path string, f format, ords []*order.Order) (File, error) {
+	out, err := os.Create(path)
+	if err != nil {
+		return File{}, err
+	}
+	defer out.Close()
+
+	h := sha256.New()
+	cw := &countWriter{w: io.MultiWriter(out, h)}
+	gz := gzip.NewWriter(cw)
+	if err := f.encode(gz, ords); err != nil {
+		return File{}, err
+	}
+	if err := gz.Close(); err != nil {
+		return File{}, err
+	}
+	if err := out.Sync(); err != nil {
+		return File{}, err
+	}
+
+	return File{
+		Name:   filepath.Base(path),
+		Orders: len(ords),
+		Size:   cw.n,
+		SHA256: hex.EncodeToString(h.Sum(nil)),
+	}, nil
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// LastCutoff returns the latest `to` of archives in dir, zero time if there
+// are none
+func LastCutoff(dir string) (time.Time, error) {
+	const op = "internal.archive.LastCutoff"
+
+	paths, err := filepath.Glob(filepath.Join(dir, dirPrefix+"*", ManifestFile))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var last time.Time
+	for _, p := range paths {
+		m, err := readManifest(filepath.Dir(p))
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%s: %w", op, err)
+		}
+		if m.To.After(last) {
+			last = m.To
+		}
+	}
+
+	return last, nil
+}
+
+// ArchiveBefore exports orders created after the last archive in dir and
+// before cutoff
+func (a *Archiver) ArchiveBefore(ctx context.Context, cutoff time.Time) error {
+	const op = "internal.archive.ArchiveBefore"
+
+	from, err := LastCutoff(a.cfg.Dir)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if !cutoff.After(from) {
+		return nil
+	}
+
+	path, m, err := a.Export(ctx, from, cutoff)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	zap.L().Info(fmt.Sprintf(
+		"archived %d orders in %d files to %s", m.Orders, len(m.Files), path,
+	))
+
+	return nil
+}
+
+// Run archives orders older than older_than every interval
+func (a *Archiver) Run(ctx context.Context) {
+	zap.L().Info("start archiver")
+
+	ticker := time.NewTicker(a.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := a.ArchiveBefore(ctx, a.now().Add(-a.cfg.OlderThan)); err != nil {
+			zap.L().Error("archiver: " + err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Verify checks checksums of all files of archive in dir
+func Verify(dir string) (*Manifest, error) {
+	const op = "internal.archive.Verify"
+
+	m, err := readManifest(dir)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if _, ok := formats[m.Format]; !ok {
+		return nil, fmt.Errorf("%s: unknown format %s", op, m.Format)
+	}
+
+	for _, file := range m.Files {
+		sum, err := fileSHA256(filepath.Join(dir, file.Name))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if sum != file.SHA256 {
+			return nil, fmt.Errorf("%s: %s: %w", op, file.Name, ErrChecksum)
+		}
+	}
+
+	return m, nil
+}
+
+// Restore verifies archive in dir and adds its orders through dbs, orders
+// which are already in db are skipped. Archives keep values encrypted as in
+// db, so c decrypts them before dbs seals them again by own cipher.
+func Restore(
+	ctx context.Context, dir string, dbs storage.DataBaser, c *fieldcrypt.Cipher,
+) (restored, skipped int, err error) {
+	const op = "internal.archive.Restore"
+
+	m, err := Verify(dir)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s: %w", op, err)
+	}
+	f := formats[m.Format]
+
+	for _, file := range m.Files {
+		ords, err := readFile(filepath.Join(dir, file.Name), f)
+		if err != nil {
+			return restored, skipped, fmt.Errorf("%s: %s: %w", op, file.Name, err)
+		}
+
+		for _, ord := range ords {
+			_, err := dbs.Find(ctx, ord.OrderUID)
+			if err == nil {
+				skipped++
+				continue
+			}
+			if !errors.Is(err, storage.ErrNotFound) {
+				return restored, skipped, fmt.Errorf("%s: %w", op, err)
+			}
+
+			if err := c.Open(ord); err != nil {
+				return restored, skipped, fmt.Errorf("%s: %w", op, err)
+			}
+			if err := dbs.Add(ctx, ord); err != nil {
+				return restored, skipped, fmt.Errorf("%s: %s: %w", op, ord.OrderUID, err)
+			}
+			restored++
+		}
+	}
+
+	return restored, skipped, nil
+}
+
+func readManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, err
+	}
+
+	m := new(Manifest)
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Join(dir, ManifestFile), err)
+	}
+
+	return m, nil
+}
+
+func readFile(path string, f format) ([]*order.Order, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	gz, err := gzip.NewReader(in)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	return f.decode(gz)
+}
+
+func fileSHA256(path string) (string, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, in); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,265 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"first-task/internal/config"
+	delivery "first-task/internal/entities/Delivery"
+	item "first-task/internal/entities/Item"
+	order "first-task/internal/entities/Order"
+	payment "first-task/internal/entities/Payment"
+	"first-task/internal/storage"
+	"first-task/internal/storage/fieldcrypt"
+	"first-task/pkg/envelope"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testOrder(orderUID string) *order.Order {
+	return &order.Order{
+		OrderUID:    orderUID,
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery: delivery.Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "test@gmail.com",
+		},
+		Payment: payment.Payment{
+			Transaction:  "b563feb7b2b84b6test",
+			Currency:     "USD",
+			Provider:     "wbpay",
+			Amount:       1817,
+			PaymentDT:    1637907727,
+			Bank:         "alpha",
+			DeliveryCost: 1500,
+			GoodsTotal:   317,
+		},
+		Items: []item.Item{
+			{
+				ChrtID:      9934930,
+				TrackNumber: "WBILMTESTTRACK",
+				Price:       453,
+				RID:         "ab4219087a764ae0btest",
+				Name:        "Mascaras",
+				Sale:        30,
+				Size:        "0",
+				TotalPrice:  317,
+				NMID:        2389212,
+				Brand:       "Vivienne Sabo",
+				Status:      202,
+			},
+		},
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		ShardKey:        "9",
+		SMID:            99,
+		DateCreated:     "2021-11-26T06:22:19Z",
+		OOFShard:        "1",
+	}
+}
+
+// pages of orders by their index as id
+type SourceMock struct {
+	ords []*order.Order
+}
+
+func (sm *SourceMock) OrdersBetween(
+	_ context.Context, _, _ time.Time, afterID int64, limit int,
+) ([]*order.Order, int64, error) {
+	end := min(int(afterID)+limit, len(sm.ords))
+	if int(afterID) >= end {
+		return nil, afterID, nil
+	}
+	return sm.ords[afterID:end], int64(end), nil
+}
+
+type DataBaserMock struct {
+	data map[string]*order.Order
+}
+
+func (dm *DataBaserMock) Add(_ context.Context, ord *order.Order) error {
+	dm.data[ord.OrderUID] = ord
+	return nil
+}
+
+func (dm *DataBaserMock) Find(_ context.Context, orderUID string) (*order.Order, error) {
+	if ord, ok := dm.data[orderUID]; ok {
+		return ord, nil
+	}
+	return nil, storage.ErrNotFound
+}
+
+func (dm *DataBaserMock) GetInitialData(context.Context, int) ([]*order.Order, error) {
+	return nil, nil
+}
+
+func (dm *DataBaserMock) Delete(context.Context, string) error { return nil }
+
+func (dm *DataBaserMock) EraseCustomer(context.Context, *storage.Erasure) error { return nil }
+
+func (dm *DataBaserMock) Ping(context.Context) error { return nil }
+
+func (dm *DataBaserMock) Shutdown() {}
+
+func testOrders(n int) []*order.Order {
+	ords := make([]*order.Order, n)
+	for i := range ords {
+		ords[i] = testOrder(fmt.Sprintf("test%d", i))
+	}
+	return ords
+}
+
+func TestExportRestore(t *testing.T) {
+	from := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+	ords := testOrders(5)
+
+	for _, f := range []string{FormatNDJSON, FormatColumnar} {
+		dir := t.TempDir()
+		a := NewArchiver(&SourceMock{ords: ords}, config.ArchiveConfig{
+			Dir: dir, Format: f, BatchSize: 2,
+		})
+
+		path, m, err := a.Export(context.Background(), from, to)
+		if err != nil {
+			t.Fatalf("%s: %s", f, err.Error())
+		}
+		if m.Orders != len(ords) || len(m.Files) != 3 {
+			t.Errorf("%s: wrong manifest: %d orders in %d files", f, m.Orders, len(m.Files))
+		}
+
+		// one order is already in db
+		dbs := &DataBaserMock{data: map[string]*order.Order{"test0": ords[0]}}
+		restored, skipped, err := Restore(context.Background(), path, dbs, nil)
+		if err != nil {
+			t.Fatalf("%s: %s", f, err.Error())
+		}
+		if restored != len(ords)-1 || skipped != 1 {
+			t.Errorf("%s: restored %d, skipped %d", f, restored, skipped)
+		}
+		for _, ord := range ords {
+			if !reflect.DeepEqual(dbs.data[ord.OrderUID], ord) {
+				t.Errorf("%s: wrong restored order\nwait: %v\nget: %v", f, ord, dbs.data[ord.OrderUID])
+			}
+		}
+
+		last, err := LastCutoff(dir)
+		if err != nil || !last.Equal(to) {
+			t.Errorf("%s: wrong last cutoff %s: %v", f, last, err)
+		}
+	}
+}
+
+func TestRestoreSealed(t *testing.T) {
+	kf := new(envelope.KeyFile)
+	if err := kf.Rotate("k1"); err != nil {
+		t.Fatal(err)
+	}
+	kr, err := envelope.NewKeyring(kf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := fieldcrypt.New(kr, []string{"name", "phone"})
+
+	ords := testOrders(2)
+	sealed := make([]*order.Order, len(ords))
+	for i, ord := range ords {
+		if sealed[i], err = c.Seal(ord); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	a := NewArchiver(&SourceMock{ords: sealed}, config.ArchiveConfig{
+		Dir: t.TempDir(), Format: FormatNDJSON,
+	})
+	path, _, err := a.Export(context.Background(), time.Time{}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dbs := &DataBaserMock{data: map[string]*order.Order{}}
+	if _, _, err := Restore(context.Background(), path, dbs, c); err != nil {
+		t.Fatal(err)
+	}
+	for _, ord := range ords {
+		if !reflect.DeepEqual(dbs.data[ord.OrderUID], ord) {
+			t.Errorf("wrong restored order\nwait: %v\nget: %v", ord, dbs.data[ord.OrderUID])
+		}
+	}
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	a := NewArchiver(&SourceMock{ords: testOrders(3)}, config.ArchiveConfig{
+		Dir: t.TempDir(), Format: FormatNDJSON,
+	})
+
+	path, m, err := a.Export(context.Background(), time.Time{}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Verify(path); err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(path, m.Files[0].Name)
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)-1] ^= 0xff
+	if err := os.WriteFile(file, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Verify(path); !errors.Is(err, ErrChecksum) {
+		t.Errorf("waited checksum error, get %v", err)
+	}
+	dbs := &DataBaserMock{data: map[string]*order.Order{}}
+	if _, _, err := Restore(context.Background(), path, dbs, nil); err == nil || len(dbs.data) > 0 {
+		t.Error("broken archive is restored")
+	}
+}
+
+func TestArchiveBefore(t *testing.T) {
+	dir := t.TempDir()
+	a := NewArchiver(&SourceMock{}, config.ArchiveConfig{Dir: dir, Format: FormatColumnar})
+	cutoff := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	if err := a.ArchiveBefore(context.Background(), cutoff); err != nil {
+		t.Fatal(err)
+	}
+	// the same cutoff doesn't make new archive
+	if err := a.ArchiveBefore(context.Background(), cutoff); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.ArchiveBefore(context.Background(), cutoff.AddDate(0, 1, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("waited 2 archives, get %d", len(entries))
+	}
+}
+
+func TestUnknownFormat(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("archiver is created with unknown format")
+		}
+	}()
+	NewArchiver(&SourceMock{}, config.ArchiveConfig{Format: "parquet"})
+}
@@ -0,0 +1,181 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	order "first-task/internal/entities/Order"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const (
+	FormatNDJSON   = "ndjson"
+	FormatColumnar = "columnar"
+)
+
+type format struct {
+	ext    string
+	encode func(w io.Writer, ords []*order.Order) error
+	decode func(r io.Reader) ([]*order.Order, error)
+}
+
+var formats = map[string]format{
+	FormatNDJSON:   {ext: ".ndjson.gz", encode: encodeNDJSON, decode: decodeNDJSON},
+	FormatColumnar: {ext: ".columns.json.gz", encode: encodeColumnar, decode: decodeColumnar},
+}
+
+// one order per line, the same json as in kafka messages
+func encodeNDJSON(w io.Writer, ords []*order.Order) error {
+	enc := json.NewEncoder(w)
+	for _, ord := range ords {
+		if err := enc.Encode(ord); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeNDJSON(r io.Reader) ([]*order.Order, error) {
+	result := make([]*order.Order, 0)
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		ord := new(order.Order)
+		if err := json.Unmarshal(sc.Bytes(), ord); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		result = append(result, ord)
+	}
+
+	return result, sc.Err()
+}
+
+// columnar file keeps values of every field in its own array, nested objects
+// are flattened to "delivery.name" like columns and arrays (items) are kept
+// as one json value per row. Rows are restored by the same paths, so new
+// fields of order don't need changes here.
+type columnarFile struct {
+	Rows    int                          `json:"rows"`
+	Columns map[string][]json.RawMessage `json:"columns"`
+}
+
+func encodeColumnar(w io.Writer, ords []*order.Order) error {
+	cf := columnarFile{
+		Rows:    len(ords),
+		Columns: make(map[string][]json.RawMessage),
+	}
+
+	for i, ord := range ords {
+		data, err := json.Marshal(ord)
+		if err != nil {
+			return err
+		}
+		var row map[string]json.RawMessage
+		if err := json.Unmarshal(data, &row); err != nil {
+			return err
+		}
+
+		flat := make(map[string]json.RawMessage)
+		if err := flatten("", row, flat); err != nil {
+			return err
+		}
+		for name, v := range flat {
+			col, ok := cf.Columns[name]
+			if !ok {
+				col = make([]json.RawMessage, len(ords))
+			}
+			col[i] = v
+			cf.Columns[name] = col
+		}
+	}
+
+	// nil cells of rows without field
+	for _, col := range cf.Columns {
+		for i := range col {
+			if col[i] == nil {
+				col[i] = json.RawMessage("null")
+			}
+		}
+	}
+
+	return json.NewEncoder(w).Encode(cf)
+}
+
+func flatten(prefix string, obj map[string]json.RawMessage, dst map[string]json.RawMessage) error {
+	for k, v := range obj {
+		name := prefix + k
+		if len(v) > 0 && v[0] == '{' {
+			var nested map[string]json.RawMessage
+			if err := json.Unmarshal(v, &nested); err != nil {
+				return err
+			}
+			if err := flatten(name+".", nested, dst); err != nil {
+				return err
+			}
+			continue
+		}
+		dst[name] = v
+	}
+	return nil
+}
+
+func decodeColumnar(r io.Reader) ([]*order.Order, error) {
+	var cf columnarFile
+	if err := json.NewDecoder(r).Decode(&cf); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(cf.Columns))
+	for name, col := range cf.Columns {
+		if len(col) != cf.Rows {
+			return nil, fmt.Errorf(
+				"column %s has %d values, waited %d", name, len(col), cf.Rows,
+			)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := make([]*order.Order, 0, cf.Rows)
+	for i := 0; i < cf.Rows; i++ {
+		row := make(map[string]any)
+		for _, name := range names {
+			v := cf.Columns[name][i]
+			if string(v) == "null" {
+				continue
+			}
+			setPath(row, strings.Split(name, "."), v)
+		}
+
+		data, err := json.Marshal(row)
+		if err != nil {
+			return nil, err
+		}
+		ord := new(order.Order)
+		if err := json.Unmarshal(data, ord); err != nil {
+			return nil, fmt.Errorf("row %d: %w", i, err)
+		}
+		result = append(result, ord)
+	}
+
+	return result, nil
+}
+
+func setPath(obj map[string]any, path []string, v json.RawMessage) {
+	for _, p := range path[:len(path)-1] {
+		nested, ok := obj[p].(map[string]any)
+		if !ok {
+			nested = make(map[string]any)
+			obj[p] = nested
+		}
+		obj = nested
+	}
+	obj[path[len(path)-1]] = v
+}
@@ -2,96 +2,284 @@ package client
 
 import (
 	"context"
+	"errors"
+	"first-task/internal/archive"
 	"first-task/internal/config"
 	order "first-task/internal/entities/Order"
+	"first-task/internal/health"
+	"first-task/internal/lifecycle"
+	"first-task/internal/partitions"
 	"first-task/internal/service"
 	"first-task/internal/storage"
+	"first-task/internal/storage/fieldcrypt"
 	"first-task/internal/storage/postgres"
 	"first-task/internal/storage/redisStorage"
+	"first-task/internal/storage/sqlite"
+	"first-task/internal/tracing"
 	webapp "first-task/internal/web-app"
 	"first-task/internal/web-app/handlers"
+	"first-task/internal/web-app/ratelimit"
+	"fmt"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
-	"time"
 
 	"go.uber.org/zap"
 )
 
+const (
+	StoragePostgres = "postgres"
+	StorageSQLite   = "sqlite"
+)
+
+const (
+	RateLimitMemory = "memory"
+	RateLimitRedis  = "redis"
+)
+
+var ErrNotWarm = errors.New("initial data isn't loaded yet")
+
 type Client struct {
 	str Storager
 	wa  WebApper
 	srv Servicer
+	pm  *partitions.Manager
+	arc *archive.Archiver
+	rl  *service.Relay
+	lim ratelimit.Limiter
+	tr  *tracing.Provider
+	hc  *health.Checker
+
+	// partition manager, archiver and outbox relay
+	jobs       sync.WaitGroup
+	finishJobs context.CancelFunc
 
 	cfg *config.Config
 }
 
 type Storager interface {
-	AddOrder(ord *order.Order) error
-	FindOrder(orderUID string) (*order.Order, error)
-	LoadInitialData(size int) error
+	AddOrder(ctx context.Context, ord *order.Order) error
+	FindOrder(ctx context.Context, orderUID string) (*order.Order, error)
+	LoadInitialData(ctx context.Context, size int) error
+	DeleteOrder(ctx context.Context, orderUID string) error
+	EraseCustomer(
+		ctx context.Context, customerID, mode, actor, reason string,
+	) (*storage.Erasure, error)
+	PingDB(ctx context.Context) error
+	PingCache(ctx context.Context) error
+	Warm() bool
 	Shutdown()
 }
 
 type Servicer interface {
 	ListenMessages(context.Context)
-	Shutdown()
+	Ping(ctx context.Context) error
+	Paused(ctx context.Context) error
+	Shutdown(ctx context.Context) error
 }
 
 type WebApper interface {
-	CreateServer(str handlers.OrderGetter, cw config.WebConfig)
+	CreateServer(
+		str webapp.OrderStorage, cfg *config.Config, lim ratelimit.Limiter, rc handlers.ReadinessChecker,
+	)
 	StartServer()
-	Shutdown()
+	Shutdown(ctx context.Context) error
 }
 
 func NewClient(cfg *config.Config) *Client {
-	str := storage.NewStorage(
-		redisStorage.NewRedisStorage(cfg.RedisConfig),
-		postgres.NewPostgres(cfg.PostgresConfig),
-	)
+	// global provider and propagator are set before anything is traced
+	tr := tracing.NewProvider(cfg.TracingConfig)
+
+	// nil without key file, storages keep data as is then
+	fc := fieldcrypt.NewCipher(cfg.EncryptionConfig)
+
+	var dbs storage.DataBaser
+	var pg *postgres.Postgres
+	// config made in code has no default of cleanenv
+	storageName := cfg.Storage
+	switch storageName {
+	case "", StoragePostgres:
+		storageName = StoragePostgres
+		pg = postgres.NewPostgres(cfg.PostgresConfig)
+		pg.SetCipher(fc)
+		dbs = pg
+	case StorageSQLite:
+		sl := sqlite.NewSQLite(cfg.SQLiteConfig)
+		sl.SetCipher(fc)
+		dbs = sl
+	default:
+		panic("unknown storage: " + storageName)
+	}
+
+	rs := redisStorage.NewRedisStorage(cfg.RedisConfig)
+	rs.SetCipher(fc)
+
+	var lim ratelimit.Limiter
+	switch cfg.WebConfig.RateLimitStore {
+	case "", RateLimitMemory:
+		lim = ratelimit.NewMemory()
+	case RateLimitRedis:
+		lim = ratelimit.NewRedis(rs.Client(), cfg.RedisConfig.KeyPrefix)
+	default:
+		panic("unknown rate limit store: " + cfg.WebConfig.RateLimitStore)
+	}
+
+	// offsets are kept only by postgres
+	if cfg.KafkaOrdersConfig.OffsetStore == service.OffsetsPostgres && pg == nil {
+		panic("offset_store postgres needs storage postgres")
+	}
+
+	var rl *service.Relay
+	if pg != nil && cfg.OutboxConfig.Enabled {
+		pg.EnableOutbox()
+		rl = service.NewRelay(pg, cfg.KafkaOrdersConfig.Brokers, cfg.OutboxConfig)
+	}
+
+	str := storage.NewStorage(rs, dbs, cfg.TimeoutsConfig)
+	str.SetFileArchives(pg != nil && cfg.ArchiveConfig.Enabled)
 	srv := service.NewOrderReader(str, cfg.KafkaOrdersConfig)
 	wa := webapp.NewWebApp()
 
+	hc := health.NewChecker(cfg.TimeoutsConfig.HealthCheck)
+	hc.Add(storageName, str.PingDB)
+	hc.Add("redis", str.PingCache)
+	hc.Add("kafka", srv.Ping)
+	hc.Add("consumer", func(ctx context.Context) error {
+		err := srv.Paused(ctx)
+		if errors.Is(err, service.ErrStandby) {
+			// replica without lock of topic still serves http
+			return health.Standby(err)
+		}
+		return err
+	})
+	hc.Add("cache_warmup", func(context.Context) error {
+		if !str.Warm() {
+			return ErrNotWarm
+		}
+		return nil
+	})
+
+	// partitions, archive and outbox work with postgres only
+	if pg == nil && (cfg.ArchiveConfig.Enabled || cfg.PartitionsConfig.Enabled || cfg.OutboxConfig.Enabled) {
+		zap.L().Warn("partitions, archive and outbox are disabled for storage " + storageName)
+	}
+
+	var arc *archive.Archiver
+	if pg != nil && cfg.ArchiveConfig.Enabled {
+		arc = archive.NewArchiver(pg, cfg.ArchiveConfig)
+	}
+
+	var pm *partitions.Manager
+	if pg != nil && cfg.PartitionsConfig.Enabled {
+		var pa partitions.Archiver
+		if arc != nil {
+			pa = arc
+		}
+		pm = partitions.NewManager(pg, pa, cfg.PartitionsConfig)
+	}
+
 	return &Client{
 		str: str,
 		wa:  wa,
 		srv: srv,
+		pm:  pm,
+		arc: arc,
+		rl:  rl,
+		lim: lim,
+		tr:  tr,
+		hc:  hc,
 		cfg: cfg,
 	}
 }
 
 func (c *Client) Init() {
-	err := c.str.LoadInitialData(c.cfg.InitialDataSize)
+	serviceCtx, finishService := context.WithCancel(context.Background())
+	defer finishService()
+	// jobs are canceled on shutdown, they finish their work next time
+	jobsCtx, finishJobs := context.WithCancel(context.Background())
+	defer finishJobs()
+	c.finishJobs = finishJobs
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
+	// server is up during warmup, so probes see the replica as not ready
+	c.wa.CreateServer(c.str, c.cfg, c.lim, c.hc)
+	go c.wa.StartServer()
+
+	err := c.str.LoadInitialData(serviceCtx, c.cfg.InitialDataSize)
 	if err != nil {
 		zap.L().Warn(
 			"can't load initial data, skipping this | Err: " + err.Error(),
 		)
 	}
-
-	serviceCtx, finishService := context.WithCancel(context.Background())
-	defer finishService()
 	go c.srv.ListenMessages(serviceCtx)
 
-	c.wa.CreateServer(c.str, c.cfg.WebConfig)
-	go c.wa.StartServer()
+	if c.pm != nil {
+		c.jobs.Add(1)
+		go func() {
+			defer c.jobs.Done()
+			c.pm.Run(jobsCtx)
+		}()
+	}
+	// with partitions retention archiver is called by partition manager
+	if c.arc != nil && (c.pm == nil || c.cfg.PartitionsConfig.KeepMonths <= 0) {
+		c.jobs.Add(1)
+		go func() {
+			defer c.jobs.Done()
+			c.arc.Run(jobsCtx)
+		}()
+	}
+
+	if c.rl != nil {
+		c.jobs.Add(1)
+		go func() {
+			defer c.jobs.Done()
+			c.rl.Run(jobsCtx)
+		}()
+	}
 
 	// gracefull shutdown
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 	<-sigChan
 	zap.L().Info("stopping app")
-	finishService()
 	c.Shutdown()
-	time.Sleep(time.Second * 1)
 }
 
+// Shutdown stops components in order: http requests and current kafka
+// message are finished first, then jobs, storage is closed after all of them
 func (c *Client) Shutdown() {
-	c.wa.Shutdown()
-	zap.L().Info("web app is stopped")
+	cs := c.cfg.ShutdownConfig
 
-	c.srv.Shutdown()
-	zap.L().Info("kafkaReader is stopped")
+	lm := lifecycle.NewManager()
+	lm.Add("web app", cs.HTTP, c.wa.Shutdown)
+	lm.Add("kafka consumer", cs.Consumer, c.srv.Shutdown)
+	lm.Add("jobs", cs.Jobs, c.stopJobs)
+	lm.Add("storage", 0, lifecycle.Func(c.str.Shutdown))
+	lm.Add("tracing", cs.Tracing, c.tr.Shutdown)
 
-	c.str.Shutdown()
-	zap.L().Info("storage is stopped")
+	if err := lm.Shutdown(context.Background()); err != nil {
+		zap.L().Warn("app is stopped with abandoned work: " + err.Error())
+		return
+	}
+	zap.L().Info("app is stopped")
+}
+
+func (c *Client) stopJobs(ctx context.Context) error {
+	if c.finishJobs != nil {
+		c.finishJobs()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		c.jobs.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("jobs are abandoned: %w", ctx.Err())
+	}
 }
@@ -11,10 +11,32 @@ import (
 type Config struct {
 	WebConfig         `yaml:"web_config" env-required:"true"`
 	PostgresConfig    `yaml:"postgres_config"`
+	SQLiteConfig      `yaml:"sqlite"`
 	RedisConfig       `yaml:"redis"`
 	KafkaOrdersConfig `yaml:"kafka"`
+	TimeoutsConfig    `yaml:"timeouts"`
+	PartitionsConfig  `yaml:"partitions"`
+	ArchiveConfig     `yaml:"archive"`
+	PrivacyConfig     `yaml:"privacy"`
+	EncryptionConfig  `yaml:"encryption"`
+	AuthConfig        `yaml:"auth"`
+	TracingConfig     `yaml:"tracing"`
+	ShutdownConfig    `yaml:"shutdown"`
+	OutboxConfig      `yaml:"outbox"`
 
-	InitialDataSize int `yaml:"initial_data_size" env-default:"100"`
+	// postgres or sqlite
+	Storage         string `yaml:"storage" env-default:"postgres"`
+	InitialDataSize int    `yaml:"initial_data_size" env-default:"100"`
+}
+
+// deadlines of single storage operations, 0 means without deadline
+type TimeoutsConfig struct {
+	DBRead      time.Duration `yaml:"db_read" env-default:"3s"`
+	DBWrite     time.Duration `yaml:"db_write" env-default:"5s"`
+	Cache       time.Duration `yaml:"cache" env-default:"500ms"`
+	InitialLoad time.Duration `yaml:"initial_load" env-default:"1m"`
+	// every dependency check of readiness
+	HealthCheck time.Duration `yaml:"health_check" env-default:"2s"`
 }
 
 type WebConfig struct {
@@ -22,22 +44,107 @@ type WebConfig struct {
 	Port         string        `yaml:"port" env-required:"true"`
 	ReadTimeout  time.Duration `yaml:"read_timeout" env-default:"10s"`
 	WriteTimeout time.Duration `yaml:"write_timeout" env-default:"10s"`
+	// deprecated, the same as api key with admin scope
+	AdminToken string `yaml:"admin_token" env:"ADMIN_TOKEN"`
+
+	// routes without limit aren't limited
+	RateLimits []RateLimitConfig `yaml:"rate_limits"`
+	// memory or redis, buckets in redis are shared by replicas
+	RateLimitStore string `yaml:"rate_limit_store" env-default:"memory"`
+	// header with client ip set by trusted proxy, remote address is used if empty
+	ClientIPHeader string `yaml:"client_ip_header"`
+}
+
+// token bucket per client of route
+type RateLimitConfig struct {
+	// pattern of route as registered, "GET /order/{order_uid}"
+	Route string `yaml:"route"`
+	// tokens per second
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
 }
 
 type PostgresConfig struct {
-	Host     string `yaml:"host" env-required:"true"`
-	Port     string `yaml:"port" env-required:"true"`
-	User     string `yaml:"user" env-required:"true"`
-	Password string `yaml:"password" env-required:"true"`
-	DBName   string `yaml:"db_name" env-required:"true"`
-	SSLMode  bool   `yaml:"sslmode" env-default:"false"`
+	// if set, used as is instead of fields below
+	DSN string `yaml:"dsn"`
+
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	DBName   string `yaml:"db_name"`
+
+	// disable, require, verify-ca or verify-full
+	SSLMode     string `yaml:"sslmode" env-default:"disable"`
+	SSLRootCert string `yaml:"sslrootcert"`
+	SSLCert     string `yaml:"sslcert"`
+	SSLKey      string `yaml:"sslkey"`
+
+	MaxOpenConns     int           `yaml:"max_open_conns" env-default:"20"`
+	MaxIdleConns     int           `yaml:"max_idle_conns" env-default:"10"`
+	ConnMaxLifetime  time.Duration `yaml:"conn_max_lifetime" env-default:"30m"`
+	ConnMaxIdleTime  time.Duration `yaml:"conn_max_idle_time" env-default:"5m"`
+	StatementTimeout time.Duration `yaml:"statement_timeout" env-default:"0s"`
+
+	// db can start later than app, ping it with backoff before giving up
+	ConnectAttempts   int           `yaml:"connect_attempts" env-default:"5"`
+	ConnectBackoff    time.Duration `yaml:"connect_backoff" env-default:"1s"`
+	ConnectMaxBackoff time.Duration `yaml:"connect_max_backoff" env-default:"30s"`
+
+	// read only queries go to replicas, primary is used if none is healthy
+	Replicas             []ReplicaConfig `yaml:"replicas"`
+	ReplicaMaxLag        time.Duration   `yaml:"replica_max_lag" env-default:"10s"`
+	ReplicaCheckInterval time.Duration   `yaml:"replica_check_interval" env-default:"5s"`
+}
+
+// embedded db for local development, migrations are applied on start
+type SQLiteConfig struct {
+	Path        string        `yaml:"path" env-default:"./orders.db"`
+	BusyTimeout time.Duration `yaml:"busy_timeout" env-default:"5s"`
+}
+
+// not set fields are taken from primary config
+type ReplicaConfig struct {
+	DSN  string `yaml:"dsn"`
+	Host string `yaml:"host"`
+	Port string `yaml:"port"`
 }
 
 type RedisConfig struct {
-	Host     string `yaml:"host" env-required:"true"`
-	Port     string `yaml:"port" env-required:"true"`
-	Password string `yaml:"password"`
-	DBName   int    `yaml:"db_name"`
+	// standalone, sentinel or cluster
+	Mode     string   `yaml:"mode" env-default:"standalone"`
+	Host     string   `yaml:"host"`
+	Port     string   `yaml:"port"`
+	Addrs    []string `yaml:"addrs"`
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`
+	DBName   int      `yaml:"db_name"`
+
+	MasterName       string `yaml:"master_name"`
+	SentinelUsername string `yaml:"sentinel_username"`
+	SentinelPassword string `yaml:"sentinel_password"`
+
+	TLS TLSConfig `yaml:"tls"`
+
+	// keys look like <key_prefix>:v<key_version>:<order_uid>
+	KeyPrefix  string `yaml:"key_prefix" env-default:"orders"`
+	KeyVersion int    `yaml:"key_version" env-default:"1"`
+	// store sha256 of order_uid instead of raw value
+	HashKeys bool `yaml:"hash_keys" env-default:"false"`
+
+	TTL time.Duration `yaml:"ttl" env-default:"1h"`
+	// random part added to ttl of every key, so warmed keys don't expire at once
+	TTLJitter     time.Duration `yaml:"ttl_jitter" env-default:"5m"`
+	LoadChunkSize int           `yaml:"load_chunk_size" env-default:"500"`
+}
+
+type TLSConfig struct {
+	Enabled            bool   `yaml:"enabled" env-default:"false"`
+	CAFile             string `yaml:"ca_file"`
+	CertFile           string `yaml:"cert_file"`
+	KeyFile            string `yaml:"key_file"`
+	ServerName         string `yaml:"server_name"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify" env-default:"false"`
 }
 
 type KafkaOrdersConfig struct {
@@ -46,6 +153,129 @@ type KafkaOrdersConfig struct {
 	MinBytes int      `yaml:"min_bytes" env-default:"1"`
 	MaxBytes int      `yaml:"max_bytes" env-default:"10e6"`
 	GroupID  string   `yaml:"group_id" env-required:"true"`
+
+	// saving of order, reading and commit of message
+	DBRetry     RetryConfig `yaml:"db_retry"`
+	ReadRetry   RetryConfig `yaml:"read_retry"`
+	CommitRetry RetryConfig `yaml:"commit_retry"`
+	// dlq or pause, what happens with message which isn't saved after
+	// db_retry: it's written to dlq_topic and committed or consumer pauses
+	// for pause_interval and tries again
+	OnDBExhausted string        `yaml:"on_db_exhausted" env-default:"pause"`
+	DLQTopic      string        `yaml:"dlq_topic"`
+	PauseInterval time.Duration `yaml:"pause_interval" env-default:"1m"`
+	// while db is down consumer is paused and pings it with this interval
+	ProbeInterval time.Duration `yaml:"probe_interval" env-default:"5s"`
+	// kafka or postgres, postgres keeps offsets in the same transaction as
+	// orders and partitions are read without consumer group
+	OffsetStore string `yaml:"offset_store" env-default:"kafka"`
+}
+
+// exponential backoff with jitter, 0 max_attempts and max_elapsed mean
+// without limit
+type RetryConfig struct {
+	Initial     time.Duration `yaml:"initial" env-default:"500ms"`
+	Max         time.Duration `yaml:"max" env-default:"30s"`
+	Multiplier  float64       `yaml:"multiplier" env-default:"2"`
+	Jitter      float64       `yaml:"jitter" env-default:"0.2"`
+	MaxAttempts int           `yaml:"max_attempts"`
+	MaxElapsed  time.Duration `yaml:"max_elapsed" env-default:"2m"`
+}
+
+// monthly partitions of orders table
+type PartitionsConfig struct {
+	Enabled  bool          `yaml:"enabled" env-default:"false"`
+	Interval time.Duration `yaml:"interval" env-default:"1h"`
+	// how many future months have partitions
+	Ahead int `yaml:"ahead" env-default:"3"`
+	// full months kept besides current one, 0 - keep forever
+	KeepMonths int `yaml:"keep_months" env-default:"0"`
+	// archive moves old partitions to archive_schema, drop deletes them
+	RetentionMode string `yaml:"retention_mode" env-default:"archive"`
+	ArchiveSchema string `yaml:"archive_schema" env-default:"archive"`
+}
+
+// order.ingested events are saved with orders and published by relay to
+// topic on brokers of kafka config
+type OutboxConfig struct {
+	Enabled   bool          `yaml:"enabled" env-default:"false"`
+	Topic     string        `yaml:"topic" env-default:"orders.ingested"`
+	Interval  time.Duration `yaml:"interval" env-default:"1s"`
+	BatchSize int           `yaml:"batch_size" env-default:"100"`
+}
+
+// export of old orders to compressed files on local disk
+type ArchiveConfig struct {
+	Enabled  bool          `yaml:"enabled" env-default:"false"`
+	Interval time.Duration `yaml:"interval" env-default:"24h"`
+	Dir      string        `yaml:"dir" env-default:"./archive"`
+	// ndjson or columnar
+	Format string `yaml:"format" env-default:"ndjson"`
+	// orders created earlier are archived, with partitions retention
+	// archive is made by partition manager before removing partitions
+	OlderThan time.Duration `yaml:"older_than" env-default:"720h"`
+	BatchSize int           `yaml:"batch_size" env-default:"10000"`
+}
+
+// masking of customer data in api, pages and logs
+type PrivacyConfig struct {
+	// json names of delivery fields
+	SensitiveFields []string `yaml:"sensitive_fields" env-default:"name,phone,email,address"`
+	// roles which see data as is, others get masked values
+	UnmaskedRoles []string `yaml:"unmasked_roles" env-default:"admin"`
+}
+
+// envelope encryption of delivery fields in db and cache, off without key_file
+type EncryptionConfig struct {
+	KeyFile string `yaml:"key_file" env:"ENCRYPTION_KEY_FILE"`
+	// json names of delivery fields
+	Fields []string `yaml:"fields" env-default:"name,phone,email,address"`
+}
+
+// authentication of http api, without api keys and jwks_file api is open
+// and admin routes are off
+type AuthConfig struct {
+	APIKeys []APIKeyConfig `yaml:"api_keys"`
+	// local file with public keys of jwt issuer
+	JWKSFile string `yaml:"jwks_file"`
+	// checked if set
+	Issuer   string `yaml:"issuer"`
+	Audience string `yaml:"audience"`
+	// claim with scopes, space separated string or array
+	ScopeClaim string        `yaml:"scope_claim" env-default:"scope"`
+	Leeway     time.Duration `yaml:"leeway" env-default:"30s"`
+}
+
+type APIKeyConfig struct {
+	Name string `yaml:"name"`
+	// hex of sha256 of key, key itself isn't stored
+	Hash   string   `yaml:"hash"`
+	Scopes []string `yaml:"scopes"`
+}
+
+// deadlines of shutdown stages, stages are stopped one by one, so grace
+// period of orchestrator should be longer than their sum
+type ShutdownConfig struct {
+	// waiting for http requests in processing
+	HTTP time.Duration `yaml:"http" env-default:"10s"`
+	// processing and commit of current kafka message
+	Consumer time.Duration `yaml:"consumer" env-default:"15s"`
+	// partition manager and archiver
+	Jobs    time.Duration `yaml:"jobs" env-default:"5s"`
+	Tracing time.Duration `yaml:"tracing" env-default:"5s"`
+}
+
+// export of opentelemetry traces
+type TracingConfig struct {
+	// none, otlp or stdout
+	Exporter string `yaml:"exporter" env:"TRACING_EXPORTER" env-default:"none"`
+	// host:port of otlp http receiver
+	Endpoint string `yaml:"endpoint" env:"TRACING_ENDPOINT" env-default:"localhost:4318"`
+	// plain http to receiver
+	Insecure    bool   `yaml:"insecure"`
+	ServiceName string `yaml:"service_name" env-default:"orders"`
+	// share of traces started by the app, decision of remote parent is kept
+	SampleRatio float64 `yaml:"sample_ratio" env-default:"1"`
 }
 
 // if can't find config file throw panic
@@ -21,6 +21,19 @@ func (d *Delivery) GetDataForSQLString() []any {
 	}
 }
 
+// Fields returns pointers to fields by their json names
+func (d *Delivery) Fields() map[string]*string {
+	return map[string]*string{
+		"name":    &d.Name,
+		"phone":   &d.Phone,
+		"zip":     &d.Zip,
+		"city":    &d.City,
+		"address": &d.Address,
+		"region":  &d.Region,
+		"email":   &d.Email,
+	}
+}
+
 func (d *Delivery) MarshalBinary() ([]byte, error) {
 	buf := new(bytes.Buffer)
 
@@ -0,0 +1,27 @@
+package events
+
+import (
+	order "first-task/internal/entities/Order"
+)
+
+// types of events in outbox, they are sent in event-type header
+const TypeOrderIngested = "order.ingested"
+
+const StatusIngested = "ingested"
+
+// OrderIngested is published after order is saved, key of message is order_uid
+type OrderIngested struct {
+	OrderUID   string  `json:"order_uid"`
+	CustomerID string  `json:"customer_id"`
+	Amount     float64 `json:"amount"`
+	Status     string  `json:"status"`
+}
+
+func NewOrderIngested(ord *order.Order) OrderIngested {
+	return OrderIngested{
+		OrderUID:   ord.OrderUID,
+		CustomerID: ord.CustomerID,
+		Amount:     ord.Payment.Amount,
+		Status:     StatusIngested,
+	}
+}
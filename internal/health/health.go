@@ -0,0 +1,127 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+	// replica works, but waits for other one, it isn't failure
+	StatusStandby = "standby"
+)
+
+var ErrTimeout = errors.New("check timed out")
+
+type standbyError struct {
+	err error
+}
+
+func (e *standbyError) Error() string { return e.err.Error() }
+func (e *standbyError) Unwrap() error { return e.err }
+
+// Standby marks err of check as standby state, such check is reported with
+// StatusStandby and doesn't make replica unready
+func Standby(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &standbyError{err: err}
+}
+
+// Check returns nil if dependency is available
+type Check func(ctx context.Context) error
+
+type CheckResult struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type Report struct {
+	Ready  bool                   `json:"ready"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+type check struct {
+	name  string
+	check Check
+}
+
+// Checker runs all checks concurrently, every one with its own timeout
+type Checker struct {
+	checks  []check
+	timeout time.Duration
+}
+
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// Add must be called before first Check
+func (c *Checker) Add(name string, ch Check) {
+	c.checks = append(c.checks, check{name: name, check: ch})
+}
+
+func (c *Checker) Check(ctx context.Context) Report {
+	rep := Report{Ready: true, Checks: make(map[string]CheckResult, len(c.checks))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, ch := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := c.run(ctx, ch.check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			rep.Checks[ch.name] = res
+			if res.Status == StatusDown {
+				rep.Ready = false
+			}
+		}()
+	}
+	wg.Wait()
+
+	return rep
+}
+
+// check which ignores ctx can't hold response longer than timeout
+func (c *Checker) run(ctx context.Context, ch Check) CheckResult {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- ch(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ErrTimeout
+	}
+
+	res := CheckResult{
+		Status:    StatusUp,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	var serr *standbyError
+	switch {
+	case errors.As(err, &serr):
+		res.Status = StatusStandby
+		res.Error = err.Error()
+	case err != nil:
+		res.Status = StatusDown
+		res.Error = err.Error()
+	}
+
+	return res
+}
@@ -0,0 +1,51 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestChecker(t *testing.T) {
+	c := NewChecker(50 * time.Millisecond)
+	c.Add("ok", func(context.Context) error { return nil })
+	c.Add("waits", func(context.Context) error { return Standby(errors.New("waits for lock")) })
+
+	rep := c.Check(context.Background())
+	if !rep.Ready || rep.Checks["ok"].Status != StatusUp {
+		t.Fatalf("not ready with working dependency: %+v", rep)
+	}
+	if rep.Checks["waits"].Status != StatusStandby {
+		t.Fatalf("standby isn't reported: %+v", rep)
+	}
+
+	c.Add("down", func(context.Context) error { return errors.New("connection refused") })
+	// ignores ctx
+	c.Add("stuck", func(context.Context) error { time.Sleep(time.Second); return nil })
+
+	start := time.Now()
+	rep = c.Check(context.Background())
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("stuck check holds report for %s", d)
+	}
+	if rep.Ready {
+		t.Error("ready with failed dependencies")
+	}
+
+	tests := map[string]CheckResult{
+		"ok":    {Status: StatusUp},
+		"waits": {Status: StatusStandby, Error: "waits for lock"},
+		"down":  {Status: StatusDown, Error: "connection refused"},
+		"stuck": {Status: StatusDown, Error: ErrTimeout.Error()},
+	}
+	for name, wait := range tests {
+		get := rep.Checks[name]
+		if get.Status != wait.Status || get.Error != wait.Error {
+			t.Errorf("%s: wrong result\nwait: %+v\nget: %+v", name, wait, get)
+		}
+	}
+	if rep.Checks["stuck"].LatencyMS < 50 {
+		t.Errorf("wrong latency of stuck check: %v", rep.Checks["stuck"].LatencyMS)
+	}
+}
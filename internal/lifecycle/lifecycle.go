@@ -0,0 +1,69 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// StopFunc stops component, when ctx is done it should give up and return
+// error which tells what is abandoned
+type StopFunc func(ctx context.Context) error
+
+type stage struct {
+	name    string
+	timeout time.Duration
+	stop    StopFunc
+}
+
+// Manager stops components one by one in order they were added, so
+// producers of work are stopped before things they write to
+type Manager struct {
+	stages []stage
+}
+
+func NewManager() *Manager {
+	return &Manager{}
+}
+
+// Add appends stage, timeout 0 means stage isn't limited
+func (m *Manager) Add(name string, timeout time.Duration, stop StopFunc) {
+	m.stages = append(m.stages, stage{name: name, timeout: timeout, stop: stop})
+}
+
+// Shutdown runs every stage even if previous ones fail, each one gets its own
+// deadline inside ctx. Returns errors of all failed stages.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	errs := make([]error, 0)
+	for _, s := range m.stages {
+		start := time.Now()
+		if err := m.run(ctx, s); err != nil {
+			zap.L().Error(fmt.Sprintf("%s is stopped after %s with error: %s", s.name, time.Since(start), err.Error()))
+			errs = append(errs, fmt.Errorf("%s: %w", s.name, err))
+			continue
+		}
+		zap.L().Info(fmt.Sprintf("%s is stopped in %s", s.name, time.Since(start)))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (m *Manager) run(ctx context.Context, s stage) error {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+	return s.stop(ctx)
+}
+
+// Func makes stage of Shutdown without context and error
+func Func(f func()) StopFunc {
+	return func(context.Context) error {
+		f()
+		return nil
+	}
+}
@@ -0,0 +1,45 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestShutdown(t *testing.T) {
+	m := NewManager()
+	order := make([]string, 0)
+	stop := func(name string, work time.Duration) StopFunc {
+		return func(ctx context.Context) error {
+			order = append(order, name)
+			select {
+			case <-time.After(work):
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+
+	m.Add("http", 10*time.Millisecond, stop("http", time.Second))
+	m.Add("consumer", time.Second, stop("consumer", 0))
+	m.Add("storage", 0, Func(func() { order = append(order, "storage") }))
+
+	start := time.Now()
+	err := m.Shutdown(context.Background())
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("stage isn't stopped by its timeout: %s", d)
+	}
+
+	if !reflect.DeepEqual(order, []string{"http", "consumer", "storage"}) {
+		t.Errorf("wrong order of stages: %v", order)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("abandoned stage isn't reported: %v", err)
+	}
+	if err == nil || err.Error() != "http: "+context.DeadlineExceeded.Error() {
+		t.Errorf("wrong error: %v", err)
+	}
+}
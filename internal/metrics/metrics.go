@@ -0,0 +1,151 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "orders"
+
+// reasons of rejected kafka messages
+const (
+	ReasonWrongData   = "wrong_data"
+	ReasonNotValid    = "not_valid"
+	ReasonDBExhausted = "db_exhausted"
+	// schema version of message is newer than known one or can't be upcasted
+	ReasonUnknownSchema = "unknown_schema"
+)
+
+// cache tiers are checked by storage one by one
+const (
+	TierCache = "cache"
+	TierDB    = "db"
+)
+
+const (
+	ResultHit  = "hit"
+	ResultMiss = "miss"
+)
+
+// Registry keeps all metrics of the app, it's served by Handler
+var Registry = prometheus.NewRegistry()
+
+var (
+	MessagesConsumed = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "messages_consumed_total",
+		Help:      "Messages read from kafka.",
+	})
+	MessagesCommitted = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "messages_committed_total",
+		Help:      "Messages committed to kafka.",
+	})
+	MessagesRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "messages_rejected_total",
+		Help:      "Messages committed without saving order, by reason.",
+	}, []string{"reason"})
+	ConsumerPaused = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "consumer_paused",
+		Help:      "1 while consumer waits after exhausted retries.",
+	})
+	ConsumerLag = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "consumer_lag",
+		Help:      "Messages of partition after last read one.",
+	}, []string{"partition"})
+
+	DBQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "postgres",
+		Name:      "query_duration_seconds",
+		Help:      "Latency of postgres operations.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"op"})
+	DBQueryErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "postgres",
+		Name:      "query_errors_total",
+		Help:      "Failed postgres operations.",
+	}, []string{"op"})
+
+	CacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "cache_requests_total",
+		Help:      "Lookups of orders by tier and result.",
+	}, []string{"tier", "result"})
+
+	OutboxPublished = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "outbox",
+		Name:      "events_published_total",
+		Help:      "Outbox events published to kafka.",
+	})
+
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of http requests by route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "status"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		MessagesConsumed,
+		MessagesCommitted,
+		MessagesRejected,
+		ConsumerPaused,
+		ConsumerLag,
+		DBQueryDuration,
+		DBQueryErrors,
+		CacheRequests,
+		OutboxPublished,
+		HTTPRequestDuration,
+	)
+}
+
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// ObserveDBQuery records latency of op started at start and its error if any
+func ObserveDBQuery(op string, start time.Time, err error) {
+	DBQueryDuration.WithLabelValues(op).Observe(time.Since(start).Seconds())
+	if err != nil {
+		DBQueryErrors.WithLabelValues(op).Inc()
+	}
+}
+
+func ObserveCache(tier string, hit bool) {
+	result := ResultMiss
+	if hit {
+		result = ResultHit
+	}
+	CacheRequests.WithLabelValues(tier, result).Inc()
+}
+
+// SetConsumerLag sets lag of partition by high water mark of read message
+func SetConsumerLag(partition int, offset, highWaterMark int64) {
+	ConsumerLag.WithLabelValues(strconv.Itoa(partition)).Set(float64(max(highWaterMark-offset-1, 0)))
+}
+
+func ObserveHTTPRequest(route string, status int, d time.Duration) {
+	HTTPRequestDuration.WithLabelValues(route, strconv.Itoa(status)).Observe(d.Seconds())
+}
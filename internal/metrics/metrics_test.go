@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestObserve(t *testing.T) {
+	ObserveCache(TierCache, true)
+	ObserveCache(TierCache, false)
+	ObserveCache(TierCache, false)
+	if v := testutil.ToFloat64(CacheRequests.WithLabelValues(TierCache, ResultMiss)); v != 2 {
+		t.Errorf("wrong cache misses: %v", v)
+	}
+	if v := testutil.ToFloat64(CacheRequests.WithLabelValues(TierCache, ResultHit)); v != 1 {
+		t.Errorf("wrong cache hits: %v", v)
+	}
+
+	ObserveDBQuery("find", time.Now(), nil)
+	ObserveDBQuery("find", time.Now(), errors.New("db is down"))
+	if v := testutil.ToFloat64(DBQueryErrors.WithLabelValues("find")); v != 1 {
+		t.Errorf("wrong db errors: %v", v)
+	}
+
+	SetConsumerLag(3, 10, 15)
+	if v := testutil.ToFloat64(ConsumerLag.WithLabelValues("3")); v != 4 {
+		t.Errorf("wrong lag: %v", v)
+	}
+	// high water mark is unknown
+	SetConsumerLag(3, 10, 0)
+	if v := testutil.ToFloat64(ConsumerLag.WithLabelValues("3")); v != 0 {
+		t.Errorf("negative lag: %v", v)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	MessagesConsumed.Inc()
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("wrong status %d", w.Code)
+	}
+	for _, name := range []string{
+		"orders_kafka_messages_consumed_total",
+		"go_goroutines",
+	} {
+		if !strings.Contains(w.Body.String(), name) {
+			t.Errorf("%s isn't exposed", name)
+		}
+	}
+}
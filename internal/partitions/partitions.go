@@ -0,0 +1,120 @@
+package partitions
+
+import (
+	"context"
+	"first-task/internal/config"
+	"first-task/internal/storage/postgres"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	ModeArchive = "archive"
+	ModeDrop    = "drop"
+)
+
+type PartitionStorage interface {
+	EnsurePartitions(ctx context.Context, now time.Time, ahead int) ([]string, error)
+	RemoveOldPartitions(ctx context.Context, before time.Time, archiveSchema string) ([]string, error)
+}
+
+// Archiver saves orders created before cutoff somewhere else
+type Archiver interface {
+	ArchiveBefore(ctx context.Context, cutoff time.Time) error
+}
+
+// Manager creates future partitions of orders and removes old ones
+// according to retention policy
+type Manager struct {
+	str  PartitionStorage
+	arch Archiver
+	cfg  config.PartitionsConfig
+	now  func() time.Time
+}
+
+// arch can be nil, then partitions are removed without export.
+// If retention mode is unknown throw panic
+func NewManager(str PartitionStorage, arch Archiver, cfg config.PartitionsConfig) *Manager {
+	if cfg.RetentionMode != ModeArchive && cfg.RetentionMode != ModeDrop {
+		panic("unknown partitions retention mode: " + cfg.RetentionMode)
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Hour
+	}
+
+	return &Manager{
+		str:  str,
+		arch: arch,
+		cfg:  cfg,
+		now:  time.Now,
+	}
+}
+
+func (m *Manager) Run(ctx context.Context) {
+	zap.L().Info("start partition manager")
+
+	ticker := time.NewTicker(m.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.Tick(ctx); err != nil {
+			zap.L().Error("partition manager: " + err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Manager) Tick(ctx context.Context) error {
+	const op = "internal.partitions.Tick"
+
+	now := m.now()
+
+	created, err := m.str.EnsurePartitions(ctx, now, m.cfg.Ahead)
+	if len(created) > 0 {
+		zap.L().Info("created partitions: " + strings.Join(created, ", "))
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if m.cfg.KeepMonths <= 0 {
+		return nil
+	}
+
+	// rows of partitions must be exported before they are removed
+	if m.arch != nil {
+		if err := m.arch.ArchiveBefore(ctx, m.Cutoff(now)); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	archiveSchema := m.cfg.ArchiveSchema
+	if m.cfg.RetentionMode == ModeDrop {
+		archiveSchema = ""
+	}
+
+	removed, err := m.str.RemoveOldPartitions(ctx, m.Cutoff(now), archiveSchema)
+	if len(removed) > 0 {
+		zap.L().Info(fmt.Sprintf(
+			"%s old partitions: %s", m.cfg.RetentionMode, strings.Join(removed, ", "),
+		))
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Cutoff returns moment before which partitions are not kept
+func (m *Manager) Cutoff(now time.Time) time.Time {
+	return postgres.MonthStart(now).AddDate(0, -m.cfg.KeepMonths, 0)
+}
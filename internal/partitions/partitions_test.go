@@ -0,0 +1,143 @@
+package partitions
+
+import (
+	"context"
+	"errors"
+	"first-task/internal/config"
+	"testing"
+	"time"
+)
+
+type PartitionStorageMock struct {
+	now           time.Time
+	ahead         int
+	before        time.Time
+	archiveSchema string
+	removeCalled  bool
+}
+
+func (ps *PartitionStorageMock) EnsurePartitions(_ context.Context, now time.Time, ahead int) ([]string, error) {
+	ps.now, ps.ahead = now, ahead
+	return nil, nil
+}
+
+func (ps *PartitionStorageMock) RemoveOldPartitions(_ context.Context, before time.Time, archiveSchema string) ([]string, error) {
+	ps.removeCalled = true
+	ps.before, ps.archiveSchema = before, archiveSchema
+	return nil, nil
+}
+
+type ArchiverMock struct {
+	cutoff time.Time
+	err    error
+}
+
+func (a *ArchiverMock) ArchiveBefore(_ context.Context, cutoff time.Time) error {
+	a.cutoff = cutoff
+	return a.err
+}
+
+type TestCase struct {
+	Name       string
+	Cfg        config.PartitionsConfig
+	Remove     bool
+	WaitBefore time.Time
+	WaitSchema string
+}
+
+func TestTick(t *testing.T) {
+	now := time.Date(2026, time.March, 15, 12, 0, 0, 0, time.UTC)
+
+	tests := []TestCase{
+		{
+			Name: "keep forever",
+			Cfg: config.PartitionsConfig{
+				Ahead: 3, RetentionMode: ModeArchive, ArchiveSchema: "archive",
+			},
+		},
+		{
+			Name: "archive",
+			Cfg: config.PartitionsConfig{
+				Ahead: 3, KeepMonths: 2,
+				RetentionMode: ModeArchive, ArchiveSchema: "archive",
+			},
+			Remove:     true,
+			WaitBefore: time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC),
+			WaitSchema: "archive",
+		},
+		{
+			Name: "drop over year boundary",
+			Cfg: config.PartitionsConfig{
+				Ahead: 1, KeepMonths: 4,
+				RetentionMode: ModeDrop, ArchiveSchema: "archive",
+			},
+			Remove:     true,
+			WaitBefore: time.Date(2025, time.November, 1, 0, 0, 0, 0, time.UTC),
+			WaitSchema: "",
+		},
+	}
+
+	for _, v := range tests {
+		str := &PartitionStorageMock{}
+		m := NewManager(str, nil, v.Cfg)
+		m.now = func() time.Time { return now }
+
+		if err := m.Tick(context.Background()); err != nil {
+			t.Errorf("%s: %s", v.Name, err.Error())
+			continue
+		}
+
+		if !str.now.Equal(now) || str.ahead != v.Cfg.Ahead {
+			t.Errorf("%s: partitions are ensured with wrong args", v.Name)
+		}
+		if str.removeCalled != v.Remove {
+			t.Errorf("%s: wrong retention\nwait: %t\nget: %t", v.Name, v.Remove, str.removeCalled)
+			continue
+		}
+		if !v.Remove {
+			continue
+		}
+		if !str.before.Equal(v.WaitBefore) {
+			t.Errorf("%s: wrong cutoff\nwait: %s\nget: %s", v.Name, v.WaitBefore, str.before)
+		}
+		if str.archiveSchema != v.WaitSchema {
+			t.Errorf("%s: wrong archive schema\nwait: %q\nget: %q", v.Name, v.WaitSchema, str.archiveSchema)
+		}
+	}
+}
+
+func TestUnknownMode(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("manager is created with unknown retention mode")
+		}
+	}()
+	NewManager(&PartitionStorageMock{}, nil, config.PartitionsConfig{RetentionMode: "delete"})
+}
+
+func TestArchiveBeforeRemove(t *testing.T) {
+	now := time.Date(2026, time.March, 15, 12, 0, 0, 0, time.UTC)
+	cfg := config.PartitionsConfig{KeepMonths: 1, RetentionMode: ModeDrop}
+
+	str, arch := &PartitionStorageMock{}, &ArchiverMock{}
+	m := NewManager(str, arch, cfg)
+	m.now = func() time.Time { return now }
+
+	if err := m.Tick(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !arch.cutoff.Equal(m.Cutoff(now)) || !str.removeCalled {
+		t.Errorf("wrong archive cutoff %s or partitions aren't removed", arch.cutoff)
+	}
+
+	str, arch = &PartitionStorageMock{}, &ArchiverMock{err: errors.New("disk is full")}
+	m = NewManager(str, arch, cfg)
+	m.now = func() time.Time { return now }
+
+	if err := m.Tick(context.Background()); err == nil {
+		t.Error("waited error of archiver, get nil")
+	}
+	if str.removeCalled {
+		t.Error("partitions are removed after failed archiving")
+	}
+}
@@ -0,0 +1,132 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// version is sent in header or in field of payload, header is checked first
+const (
+	Header = "schema_version"
+	Field  = "schema_version"
+)
+
+const (
+	// version of messages without header and field, format of order.Order
+	// before versioning
+	DefaultVersion = 1
+	// format of order.Order json
+	CurrentVersion = 1
+)
+
+var ErrUnknownVersion = errors.New("unknown schema version")
+
+// Upcaster changes json object of its version to the next version in place
+type Upcaster func(obj map[string]json.RawMessage) error
+
+// Registry keeps upcasters from every old version to the next one. Nil
+// Registry knows only current version.
+type Registry struct {
+	current   int
+	upcasters map[int]Upcaster
+}
+
+// NewRegistry returns registry with upcasters of all known versions
+func NewRegistry() *Registry {
+	r := newRegistry(CurrentVersion)
+	// after CurrentVersion is bumped upcaster of the previous one is
+	// registered here
+	return r
+}
+
+func newRegistry(current int) *Registry {
+	return &Registry{current: current, upcasters: map[int]Upcaster{}}
+}
+
+// Register adds upcaster from version from to from+1, panics if version is
+// current or newer or it's registered already
+func (r *Registry) Register(from int, up Upcaster) {
+	if from < 1 || from >= r.current {
+		panic(fmt.Sprintf("upcaster from version %d, current is %d", from, r.current))
+	}
+	if _, ok := r.upcasters[from]; ok {
+		panic(fmt.Sprintf("upcaster from version %d is registered twice", from))
+	}
+	r.upcasters[from] = up
+}
+
+func (r *Registry) Current() int {
+	if r == nil {
+		return CurrentVersion
+	}
+	return r.current
+}
+
+// Upcast returns payload of current version and version of message, header is
+// value of version header, empty if there isn't one. ErrUnknownVersion if
+// version is newer than current or can't be upcasted, other errors mean
+// payload isn't json object.
+func (r *Registry) Upcast(header string, payload []byte) ([]byte, int, error) {
+	const op = "internal.schema.Upcast"
+
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &obj); err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	v, err := version(header, obj)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+	current := r.Current()
+	if v < 1 || v > current {
+		return nil, v, fmt.Errorf("%s: %w %d", op, ErrUnknownVersion, v)
+	}
+	if v == current {
+		return payload, v, nil
+	}
+
+	for from := v; from < current; from++ {
+		var up Upcaster
+		if r != nil {
+			up = r.upcasters[from]
+		}
+		if up == nil {
+			return nil, v, fmt.Errorf("%s: %w %d: no upcaster to %d", op, ErrUnknownVersion, v, from+1)
+		}
+		if err := up(obj); err != nil {
+			return nil, v, fmt.Errorf("%s: from version %d: %w", op, from, err)
+		}
+	}
+	obj[Field] = json.RawMessage(strconv.Itoa(current))
+
+	payload, err = json.Marshal(obj)
+	if err != nil {
+		return nil, v, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return payload, v, nil
+}
+
+func version(header string, obj map[string]json.RawMessage) (int, error) {
+	if header != "" {
+		v, err := strconv.Atoi(header)
+		if err != nil {
+			return 0, fmt.Errorf("%w %q", ErrUnknownVersion, header)
+		}
+		return v, nil
+	}
+
+	raw, ok := obj[Field]
+	if !ok {
+		return DefaultVersion, nil
+	}
+	var v int
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return 0, fmt.Errorf("%w %s", ErrUnknownVersion, raw)
+	}
+
+	return v, nil
+}
@@ -0,0 +1,101 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestUpcast(t *testing.T) {
+	// amount was string in version 1 and total in version 2
+	r := newRegistry(3)
+	r.Register(1, func(obj map[string]json.RawMessage) error {
+		var amount string
+		if err := json.Unmarshal(obj["amount"], &amount); err != nil {
+			return err
+		}
+		obj["amount"] = json.RawMessage(amount)
+		return nil
+	})
+	r.Register(2, func(obj map[string]json.RawMessage) error {
+		obj["total"] = obj["amount"]
+		delete(obj, "amount")
+		return nil
+	})
+
+	tests := []struct {
+		name    string
+		header  string
+		payload string
+		wait    string
+		version int
+	}{
+		{"without version", "", `{"amount":"10"}`, `{"schema_version":3,"total":10}`, 1},
+		{"field", "", `{"schema_version":2,"amount":10}`, `{"schema_version":3,"total":10}`, 2},
+		{"header", "2", `{"amount":10}`, `{"schema_version":3,"total":10}`, 2},
+		{"header over field", "3", `{"schema_version":1,"total":10}`, `{"schema_version":1,"total":10}`, 3},
+		{"current", "", `{"schema_version":3,"total":10}`, `{"schema_version":3,"total":10}`, 3},
+	}
+	for _, tt := range tests {
+		payload, v, err := r.Upcast(tt.header, []byte(tt.payload))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if string(payload) != tt.wait || v != tt.version {
+			t.Errorf("%s: wrong result\nwait: %s %d\nget: %s %d", tt.name, tt.wait, tt.version, payload, v)
+		}
+	}
+
+	for _, tt := range []struct{ header, payload string }{
+		{"4", `{}`},
+		{"", `{"schema_version":0}`},
+		{"v2", `{}`},
+		{"", `{"schema_version":"2"}`},
+	} {
+		_, _, err := r.Upcast(tt.header, []byte(tt.payload))
+		if !errors.Is(err, ErrUnknownVersion) {
+			t.Errorf("%q %s: wrong error: %v", tt.header, tt.payload, err)
+		}
+	}
+
+	_, _, err := r.Upcast("", []byte("test"))
+	if err == nil || errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("wrong error of not json: %v", err)
+	}
+}
+
+func TestUpcastWithoutUpcaster(t *testing.T) {
+	r := newRegistry(3)
+	r.Register(2, func(map[string]json.RawMessage) error { return nil })
+
+	_, _, err := r.Upcast("1", []byte(`{}`))
+	if !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("wrong error: %v", err)
+	}
+
+	// nil registry knows only current version
+	var nr *Registry
+	if _, v, err := nr.Upcast("", []byte(`{}`)); err != nil || v != CurrentVersion {
+		t.Errorf("current version isn't accepted: %d %v", v, err)
+	}
+	if _, _, err := nr.Upcast("", []byte(`{"schema_version":99}`)); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("wrong error: %v", err)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	up := func(map[string]json.RawMessage) error { return nil }
+	for _, from := range []int{0, 3, 2} {
+		func() {
+			r := newRegistry(3)
+			r.Register(2, up)
+			defer func() {
+				if recover() == nil {
+					t.Errorf("no panic on upcaster from %d", from)
+				}
+			}()
+			r.Register(from, up)
+		}()
+	}
+}
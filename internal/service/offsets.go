@@ -0,0 +1,257 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"first-task/internal/config"
+	"first-task/internal/storage"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"go.uber.org/zap"
+)
+
+// partitionReader reads every partition of topic by its own reader without
+// consumer group, partitions start from offsets kept in db. Readers are
+// started on first FetchMessage, so db and kafka may be down on start. One
+// replica reads all partitions: readers are started after lock of topic is
+// taken in db, other replicas wait for it. New partitions are read after
+// restart.
+type partitionReader struct {
+	cfg     config.KafkaOrdersConfig
+	offsets OffsetStore
+
+	mu      sync.Mutex
+	lock    storage.TopicLock
+	locked  atomic.Bool
+	readers []*kafka.Reader
+	cancel  context.CancelFunc
+
+	msgs chan kafka.Message
+	errs chan error
+}
+
+func newPartitionReader(cfg config.KafkaOrdersConfig, offsets OffsetStore) *partitionReader {
+	return &partitionReader{
+		cfg:     cfg,
+		offsets: offsets,
+		msgs:    make(chan kafka.Message),
+		errs:    make(chan error, 1),
+	}
+}
+
+func (pr *partitionReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	if err := pr.start(ctx); err != nil {
+		return kafka.Message{}, err
+	}
+
+	select {
+	case msg := <-pr.msgs:
+		return msg, nil
+	case err := <-pr.errs:
+		return kafka.Message{}, err
+	case <-ctx.Done():
+		return kafka.Message{}, ctx.Err()
+	}
+}
+
+// CommitMessages saves offsets after msgs, order of saved message has
+// its offset already
+func (pr *partitionReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	for _, msg := range msgs {
+		if err := pr.offsets.SaveOffset(ctx, nextOffset(msg)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (pr *partitionReader) Close() error {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	return pr.stop()
+}
+
+// stop closes readers and releases lock, must be called under mu
+func (pr *partitionReader) stop() error {
+	if pr.cancel != nil {
+		pr.cancel()
+		pr.cancel = nil
+	}
+	errs := make([]error, 0, len(pr.readers)+1)
+	for _, r := range pr.readers {
+		errs = append(errs, r.Close())
+	}
+	pr.readers = nil
+
+	if pr.lock != nil {
+		pr.locked.Store(false)
+		errs = append(errs, pr.lock.Unlock())
+		pr.lock = nil
+	}
+
+	return errors.Join(errs...)
+}
+
+func (pr *partitionReader) start(ctx context.Context) error {
+	const op = "internal.service.partitionReader.start"
+
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	if pr.readers != nil {
+		return nil
+	}
+
+	if pr.lock == nil {
+		lock, err := pr.lockTopic(ctx)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		pr.lock = lock
+		pr.locked.Store(true)
+		zap.L().Info("lock of topic " + pr.cfg.Topic + " is taken")
+	}
+
+	parts, err := readPartitions(ctx, pr.cfg.Brokers, pr.cfg.Topic)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	stored, err := pr.offsets.Offsets(ctx, pr.cfg.Topic)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	next := make(map[int]int64, len(stored))
+	for _, off := range stored {
+		next[off.Partition] = off.Offset
+	}
+
+	readers := make([]*kafka.Reader, 0, len(parts))
+	for _, p := range parts {
+		r := kafka.NewReader(kafka.ReaderConfig{
+			Brokers:   pr.cfg.Brokers,
+			Topic:     pr.cfg.Topic,
+			Partition: p.ID,
+			MinBytes:  pr.cfg.MinBytes,
+			MaxBytes:  pr.cfg.MaxBytes,
+		})
+		// partition without stored offset is read from start, like new group
+		off, ok := next[p.ID]
+		if !ok {
+			off = kafka.FirstOffset
+		}
+		if err := r.SetOffset(off); err != nil {
+			r.Close()
+			for _, r := range readers {
+				r.Close()
+			}
+			return fmt.Errorf("%s: partition %d: %w", op, p.ID, err)
+		}
+		readers = append(readers, r)
+	}
+
+	fetchCtx, cancel := context.WithCancel(context.Background())
+	pr.readers, pr.cancel = readers, cancel
+	for _, r := range readers {
+		go pr.fetch(fetchCtx, r)
+	}
+	go pr.watchLock(fetchCtx, pr.lock)
+	zap.L().Info(fmt.Sprintf(
+		"reading %d partitions of %s from offsets in db", len(readers), pr.cfg.Topic,
+	))
+
+	return nil
+}
+
+// lockTopic waits while topic is read by other replica, it's standby and
+// not failure of reading, so it isn't retried and logged as one
+func (pr *partitionReader) lockTopic(ctx context.Context) (storage.TopicLock, error) {
+	logged := false
+	for {
+		lock, err := pr.offsets.LockTopic(ctx, pr.cfg.Topic)
+		if !errors.Is(err, storage.ErrTopicLocked) {
+			return lock, err
+		}
+		if !logged {
+			logged = true
+			zap.L().Info("topic " + pr.cfg.Topic + " is read by other replica, waiting for its lock")
+		}
+		if err := sleep(ctx, max(pr.cfg.ProbeInterval, time.Second)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// fetch passes messages of partition one by one, so order of partition is
+// kept, errors are passed to FetchMessage
+func (pr *partitionReader) fetch(ctx context.Context, r *kafka.Reader) {
+	for {
+		msg, err := r.FetchMessage(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			select {
+			case pr.errs <- err:
+			default:
+			}
+			if sleep(ctx, time.Second) != nil {
+				return
+			}
+			continue
+		}
+
+		select {
+		case pr.msgs <- msg:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// watchLock stops readers when lock may be lost, e.g. connection to db is
+// broken, so other replica doesn't read partitions together with this one.
+// Readers are started again with new lock by next FetchMessage.
+func (pr *partitionReader) watchLock(ctx context.Context, lock storage.TopicLock) {
+	interval := max(pr.cfg.ProbeInterval, time.Second)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		pctx, cancel := context.WithTimeout(ctx, interval)
+		err := lock.Ping(pctx)
+		cancel()
+		if err == nil || ctx.Err() != nil {
+			continue
+		}
+
+		pr.mu.Lock()
+		if pr.lock == lock {
+			zap.L().Warn("lock of topic " + pr.cfg.Topic + " may be lost, readers are stopped: " + err.Error())
+			if serr := pr.stop(); serr != nil {
+				zap.L().Error("on stopping partition readers: " + serr.Error())
+			}
+		}
+		pr.mu.Unlock()
+		// waiting FetchMessage returns and starts readers again
+		select {
+		case pr.errs <- fmt.Errorf("lock of topic is lost: %w", err):
+		default:
+		}
+		return
+	}
+}
+
+// offset which is read after msg
+func nextOffset(msg kafka.Message) storage.KafkaOffset {
+	return storage.KafkaOffset{Topic: msg.Topic, Partition: msg.Partition, Offset: msg.Offset + 1}
+}
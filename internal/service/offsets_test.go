@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"first-task/internal/config"
+	order "first-task/internal/entities/Order"
+	"first-task/internal/storage"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/segmentio/kafka-go"
+)
+
+type offsetStoreMock struct {
+	OrderAdderMock
+	orders  []string
+	offsets map[int]int64
+	// lock of topic is held by other replica
+	lockedByOther atomic.Bool
+	lockCalls     atomic.Int32
+	unlocked      bool
+}
+
+func (om *offsetStoreMock) LockTopic(context.Context, string) (storage.TopicLock, error) {
+	om.lockCalls.Add(1)
+	if om.lockedByOther.Load() {
+		return nil, storage.ErrTopicLocked
+	}
+	return om, nil
+}
+
+func (om *offsetStoreMock) Ping(context.Context) error { return nil }
+
+func (om *offsetStoreMock) Unlock() error {
+	om.unlocked = true
+	return nil
+}
+
+func (om *offsetStoreMock) AddOrderAt(_ context.Context, ord *order.Order, off storage.KafkaOffset) error {
+	if slices.Contains(om.orders, ord.OrderUID) {
+		return storage.ErrDuplicate
+	}
+	om.orders = append(om.orders, ord.OrderUID)
+	om.offsets[off.Partition] = off.Offset
+	return nil
+}
+
+func (om *offsetStoreMock) SaveOffset(_ context.Context, off storage.KafkaOffset) error {
+	om.offsets[off.Partition] = off.Offset
+	return nil
+}
+
+func (om *offsetStoreMock) Offsets(context.Context, string) ([]storage.KafkaOffset, error) {
+	return nil, nil
+}
+
+func TestProcessWithOffsetStore(t *testing.T) {
+	om := &offsetStoreMock{offsets: map[int]int64{}}
+	pr := newPartitionReader(config.KafkaOrdersConfig{}, om)
+	srv := Service{
+		reader:   pr,
+		str:      om,
+		offsets:  om,
+		validate: validator.New(),
+	}
+
+	err := srv.process(context.Background(), kafka.Message{Topic: "orders", Partition: 1, Offset: 4, Value: testJSON})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(om.orders) != 1 || om.offsets[1] != 5 {
+		t.Errorf("order isn't saved with next offset: %v %v", om.orders, om.offsets)
+	}
+
+	// rejected message moves offset without order
+	err = srv.process(context.Background(), kafka.Message{Topic: "orders", Partition: 2, Offset: 9, Value: []byte("test")})
+	if !errors.Is(err, ErrWrongData) {
+		t.Fatalf("wrong error: %v", err)
+	}
+	if len(om.orders) != 1 || om.offsets[2] != 10 {
+		t.Errorf("offset of rejected message isn't saved: %v %v", om.orders, om.offsets)
+	}
+
+	// redelivered order isn't saved twice, but its offset is
+	err = srv.process(context.Background(), kafka.Message{Topic: "orders", Partition: 1, Offset: 7, Value: testJSON})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(om.orders) != 1 || om.offsets[1] != 8 {
+		t.Errorf("offset of redelivered order isn't saved: %v %v", om.orders, om.offsets)
+	}
+}
+
+func TestNewOrderReaderOffsetStore(t *testing.T) {
+	cfg := config.KafkaOrdersConfig{
+		Brokers:     []string{"localhost:9092"},
+		Topic:       "orders",
+		OffsetStore: OffsetsPostgres,
+	}
+
+	srv := NewOrderReader(&offsetStoreMock{}, cfg)
+	if _, ok := srv.reader.(*partitionReader); !ok || srv.offsets == nil {
+		t.Error("offsets aren't kept by storage")
+	}
+
+	// storage without offsets
+	defer func() {
+		if recover() == nil {
+			t.Error("no panic with storage which doesn't keep offsets")
+		}
+	}()
+	NewOrderReader(&OrderAdderMock{}, cfg)
+}
+
+func TestPartitionReaderLock(t *testing.T) {
+	om := &offsetStoreMock{offsets: map[int]int64{}}
+	om.lockedByOther.Store(true)
+	// kafka isn't asked while topic is locked
+	pr := newPartitionReader(config.KafkaOrdersConfig{Brokers: []string{"127.0.0.1:1"}, Topic: "orders"}, om)
+	srv := Service{reader: pr, offsets: om}
+
+	if err := srv.Paused(context.Background()); !errors.Is(err, ErrStandby) {
+		t.Errorf("replica without lock is ready: %v", err)
+	}
+	// standby replica waits for lock, it isn't error of reading
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	_, err := pr.FetchMessage(ctx)
+	cancel()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("wrong error: %v", err)
+	}
+	if err := srv.Paused(context.Background()); !errors.Is(err, ErrStandby) {
+		t.Errorf("replica without lock is ready: %v", err)
+	}
+
+	// lock is taken when other replica releases it, it's kept while kafka
+	// is down and released on close
+	time.AfterFunc(100*time.Millisecond, func() { om.lockedByOther.Store(false) })
+	ctx, cancel = context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if _, err := pr.FetchMessage(ctx); err == nil || ctx.Err() != nil {
+		t.Fatalf("wrong error: %v", err)
+	}
+	if n := om.lockCalls.Load(); n < 3 {
+		t.Errorf("lock is taken after %d attempts", n)
+	}
+	if err := srv.Paused(context.Background()); err != nil {
+		t.Errorf("replica with lock isn't ready: %v", err)
+	}
+	if err := pr.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !om.unlocked || srv.Paused(context.Background()) == nil {
+		t.Error("lock isn't released on close")
+	}
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"first-task/internal/config"
+	"first-task/internal/metrics"
+	"first-task/internal/storage"
+	"first-task/internal/tracing"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"go.uber.org/zap"
+)
+
+type OutboxStore interface {
+	// events are deleted only if publish succeeds
+	PublishOutbox(
+		ctx context.Context, limit int,
+		publish func(context.Context, []storage.OutboxEvent) error,
+	) (int, error)
+}
+
+// Relay publishes events of outbox to kafka at least once, consumers tell
+// duplicates by event-id header
+type Relay struct {
+	store  OutboxStore
+	writer MessageWriter
+	cfg    config.OutboxConfig
+}
+
+// If config is wrong throw panic
+func NewRelay(store OutboxStore, brokers []string, cfg config.OutboxConfig) *Relay {
+	if cfg.Topic == "" || cfg.BatchSize <= 0 || cfg.Interval <= 0 {
+		panic(fmt.Sprintf("wrong outbox config: %+v", cfg))
+	}
+
+	return &Relay{
+		store: store,
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Topic:        cfg.Topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+		},
+		cfg: cfg,
+	}
+}
+
+// Run publishes events every Interval until ctx is done, events of canceled
+// batch are published next time
+func (r *Relay) Run(ctx context.Context) {
+	zap.L().Info("start outbox relay")
+	defer func() {
+		if err := r.writer.Close(); err != nil {
+			zap.L().Error("error on closing outbox writer")
+		}
+	}()
+
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.PublishPending(ctx); err != nil && ctx.Err() == nil {
+			zap.L().Error("outbox relay: " + err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PublishPending publishes batches until outbox is empty
+func (r *Relay) PublishPending(ctx context.Context) error {
+	const op = "internal.service.Relay.PublishPending"
+
+	for {
+		n, err := r.store.PublishOutbox(ctx, r.cfg.BatchSize, r.publish)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if n < r.cfg.BatchSize {
+			return nil
+		}
+	}
+}
+
+func (r *Relay) publish(ctx context.Context, evs []storage.OutboxEvent) error {
+	msgs := make([]kafka.Message, 0, len(evs))
+	for _, ev := range evs {
+		msg := kafka.Message{
+			Key:   []byte(ev.Key),
+			Value: ev.Payload,
+			Headers: []kafka.Header{
+				{Key: "event-type", Value: []byte(ev.Type)},
+				{Key: "event-id", Value: []byte(strconv.FormatInt(ev.ID, 10))},
+			},
+		}
+		// event without trace context is published without it
+		var carrier map[string]string
+		if err := json.Unmarshal(ev.Trace, &carrier); err == nil {
+			tracing.InjectKafka(tracing.FromCarrier(ctx, carrier), &msg)
+		}
+		msgs = append(msgs, msg)
+	}
+
+	if err := r.writer.WriteMessages(ctx, msgs...); err != nil {
+		return err
+	}
+	metrics.OutboxPublished.Add(float64(len(msgs)))
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"first-task/internal/config"
+	"first-task/internal/storage"
+	"first-task/internal/tracing"
+	"testing"
+)
+
+// events are removed like by postgres: only if publish succeeds
+type outboxStoreMock struct {
+	evs []storage.OutboxEvent
+}
+
+func (om *outboxStoreMock) PublishOutbox(
+	ctx context.Context, limit int,
+	publish func(context.Context, []storage.OutboxEvent) error,
+) (int, error) {
+	batch := om.evs[:min(limit, len(om.evs))]
+	if len(batch) == 0 {
+		return 0, nil
+	}
+	if err := publish(ctx, batch); err != nil {
+		return 0, err
+	}
+	om.evs = om.evs[len(batch):]
+	return len(batch), nil
+}
+
+func TestRelay(t *testing.T) {
+	// w3c propagator
+	tracing.NewProvider(config.TracingConfig{Exporter: tracing.ExporterNone})
+
+	store := &outboxStoreMock{}
+	for i := 1; i <= 5; i++ {
+		store.evs = append(store.evs, storage.OutboxEvent{
+			ID:      int64(i),
+			Type:    "order.ingested",
+			Key:     "b563feb7b2b84b6test",
+			Payload: []byte(`{"status":"ingested"}`),
+			Trace:   []byte(`{"traceparent":"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"}`),
+		})
+	}
+	writer := &dlqWriterMock{err: errors.New("kafka is down")}
+	rl := &Relay{store: store, writer: writer, cfg: config.OutboxConfig{BatchSize: 2}}
+
+	if err := rl.PublishPending(context.Background()); err == nil {
+		t.Fatal("no error with kafka down")
+	}
+	if len(store.evs) != 5 {
+		t.Fatal("not published events are removed")
+	}
+
+	writer.err = nil
+	if err := rl.PublishPending(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.evs) != 0 || len(writer.msgs) != 5 {
+		t.Fatalf("outbox isn't published: left %d, published %d", len(store.evs), len(writer.msgs))
+	}
+
+	headers := map[string]string{}
+	for _, h := range writer.msgs[0].Headers {
+		headers[h.Key] = string(h.Value)
+	}
+	msg := writer.msgs[0]
+	if string(msg.Key) != "b563feb7b2b84b6test" || headers["event-type"] != "order.ingested" ||
+		headers["event-id"] != "1" || headers["traceparent"] != "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01" {
+		t.Errorf("wrong message: %s %v", msg.Key, headers)
+	}
+}
+
+func TestNewRelay(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("no panic with wrong config")
+		}
+	}()
+	NewRelay(&outboxStoreMock{}, []string{"localhost:9092"}, config.OutboxConfig{Topic: "orders.ingested"})
+}
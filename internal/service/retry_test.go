@@ -0,0 +1,290 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"first-task/internal/config"
+	order "first-task/internal/entities/Order"
+	"first-task/internal/metrics"
+	"first-task/internal/storage"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/segmentio/kafka-go"
+)
+
+var errDBDown = errors.New("db is down")
+
+// db answers pings, but doesn't save orders
+type downAdderMock struct {
+	calls atomic.Int32
+}
+
+func (da *downAdderMock) AddOrder(context.Context, *order.Order) error {
+	da.calls.Add(1)
+	return errDBDown
+}
+
+func (da *downAdderMock) PingDB(context.Context) error { return nil }
+
+// db is unreachable until up is set
+type outageAdderMock struct {
+	up    atomic.Bool
+	calls atomic.Int32
+	pings atomic.Int32
+	saved atomic.Int32
+}
+
+func (oa *outageAdderMock) AddOrder(context.Context, *order.Order) error {
+	oa.calls.Add(1)
+	if !oa.up.Load() {
+		return errDBDown
+	}
+	oa.saved.Add(1)
+	return nil
+}
+
+func (oa *outageAdderMock) PingDB(context.Context) error {
+	oa.pings.Add(1)
+	if !oa.up.Load() {
+		return errDBDown
+	}
+	return nil
+}
+
+type dlqWriterMock struct {
+	err  error
+	msgs []kafka.Message
+}
+
+// fails test if message is committed before it's written to dlq
+type deadLetteredFirstReaderMock struct {
+	*queueReaderMock
+	t   *testing.T
+	dlq *dlqWriterMock
+}
+
+func (dr deadLetteredFirstReaderMock) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if len(dr.dlq.msgs) < len(dr.committed)+len(msgs) {
+		dr.t.Errorf("message %d is committed before it's dead lettered", msgs[0].Offset)
+	}
+	return dr.queueReaderMock.CommitMessages(ctx, msgs...)
+}
+
+func (dw *dlqWriterMock) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	if dw.err != nil {
+		return dw.err
+	}
+	dw.msgs = append(dw.msgs, msgs...)
+	return nil
+}
+
+func (dw *dlqWriterMock) Close() error { return nil }
+
+func fastRetry(attempts int) config.RetryConfig {
+	return config.RetryConfig{Initial: time.Millisecond, Max: time.Millisecond, MaxAttempts: attempts}
+}
+
+func TestDBExhaustedDLQ(t *testing.T) {
+	qr := &queueReaderMock{}
+	da := &downAdderMock{}
+	dw := &dlqWriterMock{}
+	srv := Service{
+		reader:   deadLetteredFirstReaderMock{queueReaderMock: qr, t: t, dlq: dw},
+		dlq:      dw,
+		str:      da,
+		validate: validator.New(),
+		cfg:      config.KafkaOrdersConfig{DBRetry: fastRetry(3), OnDBExhausted: OnExhaustedDLQ},
+	}
+	rejected := testutil.ToFloat64(metrics.MessagesRejected.WithLabelValues(metrics.ReasonDBExhausted))
+
+	err := srv.process(context.Background(), kafka.Message{Topic: "orders", Partition: 2, Offset: 5, Value: testJSON})
+	if !errors.Is(err, ErrDeadLettered) || !errors.Is(err, errDBDown) {
+		t.Fatalf("wrong error: %v", err)
+	}
+	if n := da.calls.Load(); n != 3 {
+		t.Errorf("wrong count of attempts: %d", n)
+	}
+	if len(dw.msgs) != 1 || string(dw.msgs[0].Value) != string(testJSON) {
+		t.Fatalf("message isn't sent to dlq: %+v", dw.msgs)
+	}
+	headers := map[string]string{}
+	for _, h := range dw.msgs[0].Headers {
+		headers[h.Key] = string(h.Value)
+	}
+	if headers["x-original-topic"] != "orders" || headers["x-original-partition"] != "2" ||
+		headers["x-original-offset"] != "5" || headers["x-error"] == "" {
+		t.Errorf("wrong headers of dead letter: %v", headers)
+	}
+	if len(qr.committed) != 1 || qr.committed[0] != 5 {
+		t.Errorf("dead letter isn't committed: %v", qr.committed)
+	}
+	if v := testutil.ToFloat64(metrics.MessagesRejected.WithLabelValues(metrics.ReasonDBExhausted)); v != rejected+1 {
+		t.Errorf("dead letter isn't counted: %v", v-rejected)
+	}
+}
+
+func TestDBExhaustedPause(t *testing.T) {
+	tests := map[string]config.KafkaOrdersConfig{
+		"pause": {DBRetry: fastRetry(2), PauseInterval: 20 * time.Millisecond},
+		// paused when dlq is down
+		"dlq down": {DBRetry: fastRetry(2), PauseInterval: 20 * time.Millisecond, OnDBExhausted: OnExhaustedDLQ},
+	}
+	for name, cfg := range tests {
+		qr := &queueReaderMock{}
+		da := &downAdderMock{}
+		srv := Service{
+			// nothing is saved, so any commit fails test
+			reader:   savedFirstReaderMock{queueReaderMock: qr, t: t, saved: &atomic.Int32{}},
+			dlq:      &dlqWriterMock{err: errors.New("kafka is down")},
+			str:      da,
+			validate: validator.New(),
+			cfg:      cfg,
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		start := time.Now()
+		err := srv.process(ctx, kafka.Message{Value: testJSON})
+		cancel()
+
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%s: wrong error: %v", name, err)
+		}
+		if d := time.Since(start); d > 500*time.Millisecond {
+			t.Errorf("%s: canceled ctx isn't honored for %s", name, d)
+		}
+		// attempts are repeated after pause
+		if n := da.calls.Load(); n < 4 {
+			t.Errorf("%s: wrong count of attempts: %d", name, n)
+		}
+		if len(qr.committed) != 0 {
+			t.Errorf("%s: not saved order is committed", name)
+		}
+		if v := testutil.ToFloat64(metrics.ConsumerPaused); v != 0 {
+			t.Errorf("%s: consumer is still paused", name)
+		}
+	}
+}
+
+func TestStorageDownPauses(t *testing.T) {
+	qr := &queueReaderMock{msgs: make(chan kafka.Message, 2)}
+	oa := &outageAdderMock{}
+	srv := &Service{
+		// held message is committed only after storage is up and order is saved
+		reader:   savedFirstReaderMock{queueReaderMock: qr, t: t, saved: &oa.saved},
+		str:      oa,
+		validate: validator.New(),
+		// retries aren't exhausted by outage
+		cfg: config.KafkaOrdersConfig{
+			DBRetry:       fastRetry(2),
+			OnDBExhausted: OnExhaustedDLQ,
+			ProbeInterval: 5 * time.Millisecond,
+		},
+		stop:  make(chan struct{}),
+		abort: make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	qr.msgs <- kafka.Message{Offset: 1, Value: testJSON}
+	qr.msgs <- kafka.Message{Offset: 2, Value: testJSON}
+	go srv.ListenMessages(context.Background())
+
+	deadline := time.Now().Add(time.Second)
+	for srv.Paused(context.Background()) == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("consumer isn't paused while storage is down")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if v := testutil.ToFloat64(metrics.ConsumerPaused); v != 1 {
+		t.Errorf("paused state isn't exposed by metric: %v", v)
+	}
+
+	// second message isn't read while storage is probed
+	for oa.pings.Load() < 4 {
+		time.Sleep(time.Millisecond)
+	}
+	if n := oa.calls.Load(); n != 1 {
+		t.Errorf("order is saved %d times while storage is down", n)
+	}
+	if len(qr.msgs) != 1 {
+		t.Error("message is read while consumer is paused")
+	}
+
+	oa.up.Store(true)
+	deadline = time.Now().Add(time.Second)
+	for len(qr.msgs) > 0 || srv.Paused(context.Background()) != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("consumer isn't resumed after storage is up")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := srv.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	qr.mu.Lock()
+	defer qr.mu.Unlock()
+	if len(qr.committed) != 2 || qr.committed[0] != 1 || qr.committed[1] != 2 {
+		t.Errorf("wrong committed offsets: %v", qr.committed)
+	}
+}
+
+func TestNewDLQWriter(t *testing.T) {
+	if w := newDLQWriter(config.KafkaOrdersConfig{OnDBExhausted: OnExhaustedPause}); w != nil {
+		t.Error("dlq writer is made without dlq")
+	}
+	if w := newDLQWriter(config.KafkaOrdersConfig{OnDBExhausted: OnExhaustedDLQ, DLQTopic: "orders.dlq"}); w == nil {
+		t.Error("dlq writer isn't made")
+	}
+
+	for _, cfg := range []config.KafkaOrdersConfig{
+		{OnDBExhausted: OnExhaustedDLQ},
+		{OnDBExhausted: "drop"},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("no panic with wrong config: %+v", cfg)
+				}
+			}()
+			newDLQWriter(cfg)
+		}()
+	}
+}
+
+// order is saved already
+type dupAdderMock struct {
+	calls atomic.Int32
+}
+
+func (dm *dupAdderMock) AddOrder(context.Context, *order.Order) error {
+	dm.calls.Add(1)
+	return fmt.Errorf("internal.storage.AddOrder: %w", storage.ErrDuplicate)
+}
+
+func (dm *dupAdderMock) PingDB(context.Context) error { return nil }
+
+func TestDuplicateCommitted(t *testing.T) {
+	qr := &queueReaderMock{}
+	dm := &dupAdderMock{}
+	srv := Service{
+		reader:   qr,
+		str:      dm,
+		validate: validator.New(),
+		cfg:      config.KafkaOrdersConfig{DBRetry: fastRetry(3)},
+	}
+
+	if err := srv.process(context.Background(), kafka.Message{Offset: 3, Value: testJSON}); err != nil {
+		t.Fatalf("redelivered order is failure: %v", err)
+	}
+	if n := dm.calls.Load(); n != 1 {
+		t.Errorf("duplicate is retried %d times", n)
+	}
+	if len(qr.committed) != 1 || qr.committed[0] != 3 {
+		t.Errorf("redelivered message isn't committed: %v", qr.committed)
+	}
+}
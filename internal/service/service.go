@@ -6,59 +6,151 @@ import (
 	"errors"
 	"first-task/internal/config"
 	order "first-task/internal/entities/Order"
+	"first-task/internal/metrics"
+	"first-task/internal/schema"
+	"first-task/internal/storage"
+	"first-task/internal/tracing"
+	"first-task/pkg/retry"
 	"fmt"
+	"slices"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/segmentio/kafka-go"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
 var ErrWrongData = errors.New("can't unmarshal json from kafka msg")
 var ErrNotValidData = errors.New("not valid data")
+var ErrDeadLettered = errors.New("order isn't saved, message is sent to dlq")
+var ErrStorageDown = errors.New("storage is down")
+var ErrPaused = errors.New("consumer is paused")
+var ErrStandby = errors.New("consumer waits for lock of topic")
 
+// where consumer keeps offsets
+const (
+	OffsetsKafka    = "kafka"
+	OffsetsPostgres = "postgres"
+)
+
+// what consumer does with message when db retries are exhausted
+const (
+	OnExhaustedPause = "pause"
+	OnExhaustedDLQ   = "dlq"
+)
+
+// OrderReader doesn't commit fetched messages by itself, so message is
+// committed only by CommitMessages after it's handled
 type OrderReader interface {
-	ReadMessage(context.Context) (kafka.Message, error)
+	FetchMessage(context.Context) (kafka.Message, error)
 	Close() error
 	CommitMessages(context.Context, ...kafka.Message) error
 }
 
+// OffsetStore keeps offsets of consumer in db, order and offset after its
+// message are saved in one transaction. Topic is read by consumer which
+// holds its lock.
+type OffsetStore interface {
+	AddOrderAt(ctx context.Context, ord *order.Order, off storage.KafkaOffset) error
+	SaveOffset(ctx context.Context, off storage.KafkaOffset) error
+	Offsets(ctx context.Context, topic string) ([]storage.KafkaOffset, error)
+	LockTopic(ctx context.Context, topic string) (storage.TopicLock, error)
+}
+
+type MessageWriter interface {
+	WriteMessages(context.Context, ...kafka.Message) error
+	Close() error
+}
+
 type Service struct {
 	reader   OrderReader
+	dlq      MessageWriter
 	str      OrderAdder
 	validate *validator.Validate
+	schemas  *schema.Registry
 	cfg      config.KafkaOrdersConfig
+	// nil if offsets are committed to kafka
+	offsets OffsetStore
+
+	// closed by Shutdown: stop stops reading, abort cancels processing
+	// of current message, done is closed when ListenMessages returns
+	stop  chan struct{}
+	abort chan struct{}
+	done  chan struct{}
+	// message in processing
+	current atomic.Pointer[kafka.Message]
+	// nothing is read while consumer is paused
+	paused atomic.Bool
 }
 
 type OrderAdder interface {
-	AddOrder(*order.Order) error
+	AddOrder(context.Context, *order.Order) error
+	PingDB(context.Context) error
 }
 
+// With offset store postgres str must implement OffsetStore. If config is
+// wrong throw panic
 func NewOrderReader(str OrderAdder, cfg config.KafkaOrdersConfig) *Service {
-	return &Service{
+	s := &Service{
 		validate: validator.New(),
+		schemas:  schema.NewRegistry(),
 		str:      str,
 		cfg:      cfg,
 
-		reader: newReader(cfg),
+		dlq: newDLQWriter(cfg),
+
+		stop:  make(chan struct{}),
+		abort: make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+
+	switch cfg.OffsetStore {
+	case "", OffsetsKafka:
+		s.reader = newReader(cfg)
+	case OffsetsPostgres:
+		offsets, ok := str.(OffsetStore)
+		if !ok {
+			panic("storage doesn't keep kafka offsets")
+		}
+		s.offsets = offsets
+		s.reader = newPartitionReader(cfg, offsets)
+	default:
+		panic("unknown offset_store: " + cfg.OffsetStore)
 	}
+
+	return s
 }
 
+// ListenMessages reads messages until ctx is done or Shutdown is called,
+// after Shutdown current message is still processed and committed
 func (s *Service) ListenMessages(ctx context.Context) {
+	defer close(s.done)
+
+	ctx, cancel := cancelOn(ctx, s.abort)
+	defer cancel()
+	readCtx, stopReading := cancelOn(ctx, s.stop)
+	defer stopReading()
+
 	zap.L().Info("start listening kafka messages")
 	for {
 		select {
-		case <-ctx.Done():
+		case <-readCtx.Done():
 			return
 		default:
-			msg, err := s.reader.ReadMessage(context.Background())
+			msg, err := s.read(readCtx)
 			if err != nil {
-				zap.L().Error("kafka down: " + err.Error())
-				msg = s.retryKafka(ctx)
-				zap.L().Info("kafka is up")
+				return
 			}
+			metrics.MessagesConsumed.Inc()
+			metrics.SetConsumerLag(msg.Partition, msg.Offset, msg.HighWaterMark)
 
+			s.current.Store(&msg)
 			err = s.process(ctx, msg)
+			s.current.Store(nil)
 			if err != nil {
 				zap.L().Error("on processing new order: " + err.Error())
 			} else {
@@ -68,35 +160,76 @@ func (s *Service) ListenMessages(ctx context.Context) {
 	}
 }
 
-func (s *Service) process(ctx context.Context, msg kafka.Message) error {
+func (s *Service) process(ctx context.Context, msg kafka.Message) (err error) {
 	const op = "internal.service.process"
 
+	// spans of storage are children of producer's span
+	ctx, span := tracing.Tracer().Start(
+		tracing.ExtractKafka(ctx, msg), "kafka.process "+msg.Topic,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingDestinationName(msg.Topic),
+			semconv.MessagingDestinationPartitionID(strconv.Itoa(msg.Partition)),
+			semconv.MessagingKafkaOffset(int(msg.Offset)),
+		),
+	)
+	defer func() { tracing.End(span, err) }()
+
 	for {
 		select {
 		case <-ctx.Done():
 			return nil
 		default:
-			jsonValue := msg.Value
+			// old versions are upcasted to format of order.Order
+			jsonValue, _, err := s.schemas.Upcast(header(msg, schema.Header), msg.Value)
+			if errors.Is(err, schema.ErrUnknownVersion) {
+				metrics.MessagesRejected.WithLabelValues(metrics.ReasonUnknownSchema).Inc()
+				s.commitMSG(ctx, msg)
+				return fmt.Errorf("%s: %w", op, err)
+			}
+
 			var ord order.Order
-			err := json.Unmarshal(jsonValue, &ord)
+			if err == nil {
+				err = json.Unmarshal(jsonValue, &ord)
+			}
 			if err != nil {
-				s.commitMSG(msg)
+				metrics.MessagesRejected.WithLabelValues(metrics.ReasonWrongData).Inc()
+				s.commitMSG(ctx, msg)
 				return fmt.Errorf("%s: %w", op, errors.Join(ErrWrongData, err))
 			}
 
 			err = s.validate.Struct(ord)
 			if err != nil {
-				s.commitMSG(msg)
+				metrics.MessagesRejected.WithLabelValues(metrics.ReasonNotValid).Inc()
+				s.commitMSG(ctx, msg)
 				return fmt.Errorf("%s: %w", op, errors.Join(ErrNotValidData, err))
 			}
 
-			err = s.str.AddOrder(&ord)
+			err = s.save(ctx, &ord, msg)
+			if errors.Is(err, storage.ErrDuplicate) {
+				// redelivered message, offset wasn't saved with order
+				zap.L().Info("order " + ord.OrderUID + " is saved already")
+				s.commitMSG(ctx, msg)
+				return nil
+			}
+			if errors.Is(err, ErrDeadLettered) {
+				metrics.MessagesRejected.WithLabelValues(metrics.ReasonDBExhausted).Inc()
+				s.commitMSG(ctx, msg)
+				return fmt.Errorf("%s: %w", op, err)
+			}
 			if err != nil {
-				zap.L().Error("err on adding new order to db" + err.Error())
-				s.retryDB(&ord)
+				// order isn't saved and message isn't committed, it's read
+				// again after restart
+				return fmt.Errorf("%s: %w", op, err)
 			}
 
-			s.commitMSG(msg)
+			if s.offsets != nil {
+				// offset is saved with order
+				metrics.MessagesCommitted.Inc()
+				return nil
+			}
+			s.commitMSG(ctx, msg)
 			return nil
 		}
 	}
@@ -114,70 +247,325 @@ func newReader(cfg config.KafkaOrdersConfig) *kafka.Reader {
 	)
 }
 
-func (s *Service) commitMSG(msg kafka.Message) {
-	err := s.reader.CommitMessages(context.Background(), msg)
-	if err != nil {
-		for {
-			for i := 0; i < 5; i++ {
-				// s.reader.Close()
-
-				// r := newReader(s.cfg)
-
-				time.Sleep(time.Second * 10)
-
-				// s.reader = r
+// dlq writer is needed only with OnExhaustedDLQ
+func newDLQWriter(cfg config.KafkaOrdersConfig) MessageWriter {
+	switch cfg.OnDBExhausted {
+	case "", OnExhaustedPause:
+		return nil
+	case OnExhaustedDLQ:
+		if cfg.DLQTopic == "" {
+			panic("dlq_topic is required with on_db_exhausted " + OnExhaustedDLQ)
+		}
+		return &kafka.Writer{
+			Addr:         kafka.TCP(cfg.Brokers...),
+			Topic:        cfg.DLQTopic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+		}
+	}
+	panic("unknown on_db_exhausted: " + cfg.OnDBExhausted)
+}
 
-				err := s.reader.CommitMessages(context.Background(), msg)
-				if err == nil {
-					return
-				}
+func policy(cfg config.RetryConfig) retry.Policy {
+	return retry.Policy{
+		Initial:     cfg.Initial,
+		Max:         cfg.Max,
+		Multiplier:  cfg.Multiplier,
+		Jitter:      cfg.Jitter,
+		MaxAttempts: cfg.MaxAttempts,
+		MaxElapsed:  cfg.MaxElapsed,
+	}
+}
 
-				zap.L().Error("can't commit message(kafka): " + err.Error())
+// read pauses consumer every time retries are exhausted, so it returns
+// error only if ctx is done
+func (s *Service) read(ctx context.Context) (kafka.Message, error) {
+	var msg kafka.Message
+	for {
+		err := policy(s.cfg.ReadRetry).Do(ctx, func(ctx context.Context) error {
+			var err error
+			msg, err = s.reader.FetchMessage(ctx)
+			if err != nil && ctx.Err() == nil {
+				zap.L().Error("can't read message(kafka): " + err.Error())
 			}
+			return err
+		})
+		if err == nil || ctx.Err() != nil {
+			return msg, err
+		}
+		if err := s.pause(ctx, err); err != nil {
+			return msg, err
 		}
 	}
 }
 
-func (s *Service) retryDB(ord *order.Order) {
+// save returns ErrDeadLettered if retries are exhausted and msg is written
+// to dlq, otherwise consumer is paused and order is saved again. While
+// storage is down consumer waits for it without retries, msg is held and
+// isn't committed. Other errors are returned only if ctx is done.
+func (s *Service) save(ctx context.Context, ord *order.Order, msg kafka.Message) error {
 	for {
-		for i := 0; i < 5; i++ {
-			err := s.str.AddOrder(ord)
-			if err == nil {
-				zap.L().Info("DB retrying success")
-				return
+		err := policy(s.cfg.DBRetry).Do(ctx, func(ctx context.Context) error {
+			err := s.addOrder(ctx, ord, msg)
+			if err == nil || ctx.Err() != nil {
+				return err
 			}
-			time.Sleep(time.Second * 10)
-			zap.L().Error(
-				"DB still down, retrying again...\n" + err.Error(),
-			)
+			if errors.Is(err, storage.ErrDuplicate) {
+				return retry.Permanent(err)
+			}
+			zap.L().Error("can't add order to db: " + err.Error())
+			if perr := s.str.PingDB(ctx); perr != nil && ctx.Err() == nil {
+				return retry.Permanent(fmt.Errorf("%w: %w", ErrStorageDown, perr))
+			}
+			return err
+		})
+		if err == nil || ctx.Err() != nil || errors.Is(err, storage.ErrDuplicate) {
+			return err
+		}
+		if errors.Is(err, ErrStorageDown) {
+			if err := s.waitStorage(ctx, err); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if s.cfg.OnDBExhausted == OnExhaustedDLQ {
+			derr := s.deadLetter(ctx, msg, err)
+			if derr == nil {
+				return fmt.Errorf("%w: %w", ErrDeadLettered, err)
+			}
+			zap.L().Error("can't send message to dlq: " + derr.Error())
 		}
-		zap.L().Error("So much attemps retry DB. Waiting 5 minutes and try again.")
-		time.Sleep(time.Minute * 5)
+		if err := s.pause(ctx, err); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Service) addOrder(ctx context.Context, ord *order.Order, msg kafka.Message) error {
+	if s.offsets != nil {
+		return s.offsets.AddOrderAt(ctx, ord, nextOffset(msg))
+	}
+	return s.str.AddOrder(ctx, ord)
+}
+
+// dead letter keeps key, value and headers of msg, where it's from and why
+func (s *Service) deadLetter(ctx context.Context, msg kafka.Message, cause error) error {
+	const op = "internal.service.deadLetter"
+
+	if s.dlq == nil {
+		return fmt.Errorf("%s: dlq isn't configured", op)
+	}
+
+	dl := kafka.Message{
+		Key:   msg.Key,
+		Value: msg.Value,
+		Headers: append(
+			slices.Clone(msg.Headers),
+			kafka.Header{Key: "x-original-topic", Value: []byte(msg.Topic)},
+			kafka.Header{Key: "x-original-partition", Value: []byte(strconv.Itoa(msg.Partition))},
+			kafka.Header{Key: "x-original-offset", Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+			kafka.Header{Key: "x-error", Value: []byte(cause.Error())},
+		),
 	}
+	tracing.InjectKafka(ctx, &dl)
+
+	if err := s.dlq.WriteMessages(ctx, dl); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// pause holds consumer for PauseInterval, returns error only if ctx is done
+func (s *Service) pause(ctx context.Context, cause error) error {
+	s.setPaused(true)
+	defer s.setPaused(false)
+
+	zap.L().Warn(fmt.Sprintf("consumer is paused for %s: %s", s.cfg.PauseInterval, cause))
+	return sleep(ctx, s.cfg.PauseInterval)
 }
 
-func (s *Service) retryKafka(ctx context.Context) kafka.Message {
+// waitStorage holds consumer and pings storage every ProbeInterval until it's
+// up, returns error only if ctx is done
+func (s *Service) waitStorage(ctx context.Context, cause error) error {
+	s.setPaused(true)
+	defer s.setPaused(false)
+
+	zap.L().Warn("consumer is paused until storage is up: " + cause.Error())
 	for {
-		select {
-		case <-ctx.Done():
-			return kafka.Message{}
-		default:
-			// s.reader.Close()
-			// s.reader := newReader(s.cfg)
+		if err := sleep(ctx, s.cfg.ProbeInterval); err != nil {
+			return err
+		}
 
-			time.Sleep(time.Second * 10)
+		pctx, cancel := withTimeout(ctx, s.cfg.ProbeInterval)
+		err := s.str.PingDB(pctx)
+		cancel()
+		if err == nil {
+			zap.L().Info("storage is up, consumer is resumed")
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		zap.L().Warn("storage is still down: " + err.Error())
+	}
+}
 
-			msg, err := s.reader.ReadMessage(context.Background())
-			if err == nil {
-				return msg
-			}
-			zap.L().Error("kafka still down, retry again... | Err: " + err.Error())
+func (s *Service) setPaused(paused bool) {
+	s.paused.Store(paused)
+	if paused {
+		metrics.ConsumerPaused.Set(1)
+	} else {
+		metrics.ConsumerPaused.Set(0)
+	}
+}
+
+// Paused returns ErrPaused while consumer waits for storage or kafka and
+// ErrStandby while offsets are kept in db and topic is read by other replica
+func (s *Service) Paused(context.Context) error {
+	if s.paused.Load() {
+		return ErrPaused
+	}
+	if pr, ok := s.reader.(*partitionReader); ok && !pr.locked.Load() {
+		return ErrStandby
+	}
+	return nil
+}
+
+// message which isn't committed after retries is committed with next message
+// of partition or read again after restart
+func (s *Service) commitMSG(ctx context.Context, msg kafka.Message) {
+	err := policy(s.cfg.CommitRetry).Do(ctx, func(ctx context.Context) error {
+		err := s.reader.CommitMessages(ctx, msg)
+		if err != nil && ctx.Err() == nil {
+			zap.L().Error("can't commit message(kafka): " + err.Error())
+		}
+		return err
+	})
+	if err != nil {
+		zap.L().Error("message isn't committed: " + err.Error())
+		return
+	}
+	metrics.MessagesCommitted.Inc()
+}
+
+// value of header key of msg, empty if there isn't one
+func header(msg kafka.Message, key string) string {
+	for _, h := range msg.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// 0 - without deadline
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// Ping checks that one of brokers answers with partitions of topic,
+// reader reconnects by itself, so it's the same brokers it would use
+func (s *Service) Ping(ctx context.Context) error {
+	const op = "internal.service.Ping"
+
+	if _, err := readPartitions(ctx, s.cfg.Brokers, s.cfg.Topic); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// readPartitions returns partitions of topic from the first broker which answers
+func readPartitions(ctx context.Context, brokers []string, topic string) ([]kafka.Partition, error) {
+	errs := make([]error, 0, len(brokers))
+	for _, b := range brokers {
+		parts, err := readBrokerPartitions(ctx, b, topic)
+		if err == nil {
+			return parts, nil
 		}
+		errs = append(errs, fmt.Errorf("%s: %w", b, err))
+	}
+	if len(errs) == 0 {
+		return nil, errors.New("no brokers")
+	}
+
+	return nil, errors.Join(errs...)
+}
+
+func readBrokerPartitions(ctx context.Context, broker, topic string) ([]kafka.Partition, error) {
+	conn, err := kafka.DialContext(ctx, "tcp", broker)
+	if err != nil {
+		return nil, err
 	}
+	defer conn.Close()
+
+	if d, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(d)
+	}
+	return conn.ReadPartitions(topic)
 }
 
-func (s *Service) Shutdown() {
-	if err := s.reader.Close(); err != nil {
+// Shutdown stops reading and waits for processing of current message until
+// ctx is done, then aborts it. Messages are fetched without auto commit, so
+// aborted message isn't committed and is read again after restart. Must be
+// called after ListenMessages is started.
+func (s *Service) Shutdown(ctx context.Context) error {
+	const op = "internal.service.Shutdown"
+
+	close(s.stop)
+
+	var err error
+	select {
+	case <-s.done:
+	case <-ctx.Done():
+		if msg := s.current.Load(); msg != nil {
+			err = fmt.Errorf(
+				"%s: message %s/%d/%d is abandoned: %w",
+				op, msg.Topic, msg.Partition, msg.Offset, ctx.Err(),
+			)
+		}
+		close(s.abort)
+		<-s.done
+	}
+
+	if cerr := s.reader.Close(); cerr != nil {
 		zap.L().Error("error on closing reader")
 	}
+	if s.dlq != nil {
+		if cerr := s.dlq.Close(); cerr != nil {
+			zap.L().Error("error on closing dlq writer")
+		}
+	}
+
+	return err
+}
+
+// returned ctx is canceled when ch is closed
+func cancelOn(ctx context.Context, ch <-chan struct{}) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-ch:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
 }
@@ -3,11 +3,22 @@ package service
 import (
 	"context"
 	"errors"
+	"first-task/internal/config"
 	order "first-task/internal/entities/Order"
+	"first-task/internal/metrics"
+	"first-task/internal/schema"
+	"first-task/internal/tracing"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 type KafkaReaderMock struct{}
@@ -20,7 +31,7 @@ func (frm *KafkaReaderMock) Close() error {
 	return nil
 }
 
-func (frm *KafkaReaderMock) ReadMessage(context.Context) (kafka.Message, error) {
+func (frm *KafkaReaderMock) FetchMessage(context.Context) (kafka.Message, error) {
 	return kafka.Message{}, nil
 }
 
@@ -32,7 +43,11 @@ type TestCase struct {
 
 type OrderAdderMock struct{}
 
-func (oa *OrderAdderMock) AddOrder(ord *order.Order) error {
+func (oa *OrderAdderMock) AddOrder(_ context.Context, ord *order.Order) error {
+	return nil
+}
+
+func (oa *OrderAdderMock) PingDB(context.Context) error {
 	return nil
 }
 
@@ -86,6 +101,155 @@ func TestMSGProcess(t *testing.T) {
 	}
 }
 
+// fails first calls, then saves orders
+type flakyAdderMock struct {
+	fails int32
+	calls atomic.Int32
+	saved atomic.Int32
+}
+
+func (fa *flakyAdderMock) AddOrder(context.Context, *order.Order) error {
+	if fa.calls.Add(1) <= fa.fails {
+		return errors.New("deadlock detected")
+	}
+	fa.saved.Add(1)
+	return nil
+}
+
+func (fa *flakyAdderMock) PingDB(context.Context) error { return nil }
+
+func TestCommitAfterSave(t *testing.T) {
+	qr := &queueReaderMock{msgs: make(chan kafka.Message, 3)}
+	fa := &flakyAdderMock{fails: 2}
+	srv := &Service{
+		reader:   savedFirstReaderMock{queueReaderMock: qr, t: t, saved: &fa.saved},
+		str:      fa,
+		validate: validator.New(),
+		cfg:      config.KafkaOrdersConfig{DBRetry: fastRetry(5)},
+		stop:     make(chan struct{}),
+		abort:    make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	for i := int64(1); i <= 3; i++ {
+		qr.msgs <- kafka.Message{Offset: i, Value: testJSON}
+	}
+	go srv.ListenMessages(context.Background())
+
+	deadline := time.Now().Add(time.Second)
+	for fa.saved.Load() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("orders aren't saved")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := srv.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	qr.mu.Lock()
+	defer qr.mu.Unlock()
+	if len(qr.committed) != 3 || qr.committed[0] != 1 || qr.committed[2] != 3 {
+		t.Errorf("wrong committed offsets: %v", qr.committed)
+	}
+}
+
+func TestProcessMetrics(t *testing.T) {
+	srv := Service{
+		reader:   &KafkaReaderMock{},
+		str:      &OrderAdderMock{},
+		validate: validator.New(),
+	}
+	rejected := func(reason string) float64 {
+		return testutil.ToFloat64(metrics.MessagesRejected.WithLabelValues(reason))
+	}
+	wrong, notValid := rejected(metrics.ReasonWrongData), rejected(metrics.ReasonNotValid)
+	committed := testutil.ToFloat64(metrics.MessagesCommitted)
+
+	ctx := context.Background()
+	srv.process(ctx, kafka.Message{Value: []byte("test")})
+	srv.process(ctx, kafka.Message{Value: testErrorJSONWrongFields})
+	srv.process(ctx, kafka.Message{Value: testJSON})
+
+	if v := rejected(metrics.ReasonWrongData); v != wrong+1 {
+		t.Errorf("wrong data isn't counted: %v", v)
+	}
+	if v := rejected(metrics.ReasonNotValid); v != notValid+1 {
+		t.Errorf("not valid data isn't counted: %v", v)
+	}
+	// rejected messages are committed too
+	if v := testutil.ToFloat64(metrics.MessagesCommitted); v != committed+3 {
+		t.Errorf("wrong committed count: %v", v-committed)
+	}
+}
+
+func TestProcessSchemaVersion(t *testing.T) {
+	srv := Service{
+		reader:   &KafkaReaderMock{},
+		str:      &OrderAdderMock{},
+		validate: validator.New(),
+		schemas:  schema.NewRegistry(),
+	}
+	unknown := testutil.ToFloat64(metrics.MessagesRejected.WithLabelValues(metrics.ReasonUnknownSchema))
+	committed := testutil.ToFloat64(metrics.MessagesCommitted)
+
+	current := kafka.Message{
+		Value:   testJSON,
+		Headers: []kafka.Header{{Key: schema.Header, Value: []byte("1")}},
+	}
+	if err := srv.process(context.Background(), current); err != nil {
+		t.Errorf("message of current version isn't saved: %v", err)
+	}
+
+	newer := kafka.Message{
+		Value:   testJSON,
+		Headers: []kafka.Header{{Key: schema.Header, Value: []byte("99")}},
+	}
+	if err := srv.process(context.Background(), newer); !errors.Is(err, schema.ErrUnknownVersion) {
+		t.Errorf("wrong error: %v", err)
+	}
+	if v := testutil.ToFloat64(metrics.MessagesRejected.WithLabelValues(metrics.ReasonUnknownSchema)); v != unknown+1 {
+		t.Errorf("unknown version isn't counted: %v", v-unknown)
+	}
+	if v := testutil.ToFloat64(metrics.MessagesCommitted); v != committed+2 {
+		t.Errorf("wrong committed count: %v", v-committed)
+	}
+}
+
+func TestProcessTrace(t *testing.T) {
+	tracing.NewProvider(config.TracingConfig{Exporter: tracing.ExporterNone})
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	otel.SetTracerProvider(tp)
+
+	srv := Service{
+		reader:   &KafkaReaderMock{},
+		str:      &OrderAdderMock{},
+		validate: validator.New(),
+	}
+
+	ctx, producer := tp.Tracer("test").Start(context.Background(), "produce")
+	msg := kafka.Message{Topic: "orders", Value: testJSON}
+	tracing.InjectKafka(ctx, &msg)
+	producer.End()
+
+	srv.process(context.Background(), msg)
+	srv.process(context.Background(), kafka.Message{Topic: "orders", Value: []byte("test")})
+
+	spans := rec.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("wrong count of spans: %d", len(spans))
+	}
+	if spans[1].Name() != "kafka.process orders" {
+		t.Errorf("wrong name of span: %s", spans[1].Name())
+	}
+	if spans[1].Parent().SpanID() != producer.SpanContext().SpanID() {
+		t.Error("span of processing isn't child of producer's span")
+	}
+	if spans[2].Parent().IsValid() || spans[2].Status().Code != codes.Error {
+		t.Error("message without trace context and with wrong data isn't root failed span")
+	}
+}
+
 var testJSON = []byte(`{
    "order_uid": "test",
    "track_number": "hi how are you",
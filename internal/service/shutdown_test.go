@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"errors"
+	order "first-task/internal/entities/Order"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/segmentio/kafka-go"
+)
+
+// gives messages one by one, then blocks until ctx is done
+type queueReaderMock struct {
+	mu        sync.Mutex
+	msgs      chan kafka.Message
+	committed []int64
+}
+
+func (qr *queueReaderMock) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	select {
+	case msg := <-qr.msgs:
+		return msg, nil
+	case <-ctx.Done():
+		return kafka.Message{}, ctx.Err()
+	}
+}
+
+func (qr *queueReaderMock) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	qr.mu.Lock()
+	defer qr.mu.Unlock()
+	for _, m := range msgs {
+		qr.committed = append(qr.committed, m.Offset)
+	}
+	return nil
+}
+
+func (qr *queueReaderMock) Close() error { return nil }
+
+// fails test if message is committed before its order is saved, every
+// message of test must bring an order
+type savedFirstReaderMock struct {
+	*queueReaderMock
+	t     *testing.T
+	saved *atomic.Int32
+}
+
+func (sr savedFirstReaderMock) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	sr.mu.Lock()
+	n := len(sr.committed)
+	sr.mu.Unlock()
+	if int(sr.saved.Load()) < n+len(msgs) {
+		sr.t.Errorf("message %d is committed before its order is saved", msgs[0].Offset)
+	}
+	return sr.queueReaderMock.CommitMessages(ctx, msgs...)
+}
+
+// saves order after delay, started is closed on first call
+type slowAdderMock struct {
+	delay   time.Duration
+	started chan struct{}
+	once    sync.Once
+}
+
+func (sa *slowAdderMock) AddOrder(ctx context.Context, _ *order.Order) error {
+	sa.once.Do(func() { close(sa.started) })
+	select {
+	case <-time.After(sa.delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (sa *slowAdderMock) PingDB(context.Context) error { return nil }
+
+func newDrainService(delay time.Duration) (*Service, *queueReaderMock, *slowAdderMock) {
+	qr := &queueReaderMock{msgs: make(chan kafka.Message, 1)}
+	sa := &slowAdderMock{delay: delay, started: make(chan struct{})}
+	srv := &Service{
+		reader:   qr,
+		str:      sa,
+		validate: validator.New(),
+		stop:     make(chan struct{}),
+		abort:    make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	qr.msgs <- kafka.Message{Topic: "orders", Partition: 1, Offset: 7, Value: testJSON}
+	return srv, qr, sa
+}
+
+func TestShutdownDrains(t *testing.T) {
+	srv, qr, sa := newDrainService(50 * time.Millisecond)
+	go srv.ListenMessages(context.Background())
+	<-sa.started
+
+	if err := srv.Shutdown(context.Background()); err != nil {
+		t.Fatalf("drained consumer returns error: %v", err)
+	}
+	if len(qr.committed) != 1 || qr.committed[0] != 7 {
+		t.Errorf("message in processing isn't committed: %v", qr.committed)
+	}
+}
+
+func TestShutdownAbandons(t *testing.T) {
+	srv, qr, sa := newDrainService(time.Hour)
+	go srv.ListenMessages(context.Background())
+	<-sa.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := srv.Shutdown(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "orders/1/7") {
+		t.Errorf("abandoned message isn't reported: %v", err)
+	}
+	if len(qr.committed) != 0 {
+		t.Errorf("abandoned message is committed: %v", qr.committed)
+	}
+}
@@ -1,36 +1,72 @@
 package mapcache
 
 import (
+	"context"
 	order "first-task/internal/entities/Order"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
 )
 
+const (
+	DefaultTTL    = time.Second * 10
+	cleanInterval = time.Minute * 1
+)
+
 type MAPData struct {
+	// unix nano
 	Expired int64
 	Data    *order.Order
 }
 
 type MAPStorage struct {
+	mu    sync.RWMutex
 	Cache map[string]MAPData
+
+	ttl  time.Duration
+	stop chan struct{}
 }
 
 func NewMapStorage() *MAPStorage {
+	return NewMapStorageWithTTL(DefaultTTL)
+}
+
+// ttl is prolonged on every Find
+func NewMapStorageWithTTL(ttl time.Duration) *MAPStorage {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
 	r := &MAPStorage{
-		make(map[string]MAPData),
+		Cache: make(map[string]MAPData),
+		ttl:   ttl,
+		stop:  make(chan struct{}),
 	}
 	go func() {
-		ticker := time.NewTicker(time.Minute * 1)
+		ticker := time.NewTicker(cleanInterval)
+		defer ticker.Stop()
 
-		for dt := range ticker.C {
-			zap.L().Info("checking cache for expired values " + dt.String())
-			go r.clean()
+		for {
+			select {
+			case <-r.stop:
+				return
+			case dt := <-ticker.C:
+				zap.L().Info("checking cache for expired values " + dt.String())
+				r.clean()
+			}
 		}
 	}()
 	return r
 }
 
-func (ms MAPStorage) Shutdown() {
+// Ping is always ok for map in memory
+func (ms *MAPStorage) Ping(context.Context) error {
+	return nil
+}
 
+func (ms *MAPStorage) Shutdown() {
+	if ms.stop != nil {
+		close(ms.stop)
+	}
 }
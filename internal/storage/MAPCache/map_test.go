@@ -1,17 +1,19 @@
 package mapcache
 
 import (
+	"context"
 	delivery "first-task/internal/entities/Delivery"
 	item "first-task/internal/entities/Item"
 	order "first-task/internal/entities/Order"
 	payment "first-task/internal/entities/Payment"
+	"first-task/internal/storage/storagetest"
 	"testing"
 	"time"
 )
 
 func TestAddOrder(t *testing.T) {
 	str := NewMapStorage()
-	str.Add(testOrder("test"))
+	str.Add(context.Background(), testOrder("test"))
 
 	if _, ok := str.Cache[orderUID]; !ok {
 		t.Error("value doesn't added")
@@ -21,11 +23,11 @@ func TestAddOrder(t *testing.T) {
 func TestGetOrder(t *testing.T) {
 	str := NewMapStorage()
 	str.Cache[orderUID] = MAPData{
-		Expired: time.Now().Add(time.Hour).Unix(),
+		Expired: time.Now().Add(time.Hour).UnixNano(),
 		Data:    testOrder("test"),
 	}
 
-	ord := str.Find(orderUID)
+	ord := str.Find(context.Background(), orderUID)
 	if ord == nil {
 		t.Error("can't find added order")
 	}
@@ -34,11 +36,11 @@ func TestGetOrder(t *testing.T) {
 func TestDeleteOrder(t *testing.T) {
 	str := NewMapStorage()
 	str.Cache[orderUID] = MAPData{
-		Expired: time.Now().Add(time.Hour).Unix(),
+		Expired: time.Now().Add(time.Hour).UnixNano(),
 		Data:    testOrder("test"),
 	}
 
-	str.Delete(orderUID)
+	str.Delete(context.Background(), orderUID)
 
 	if _, ok := str.Cache[orderUID]; ok {
 		t.Error("can't delete value from cache")
@@ -53,7 +55,7 @@ func TestLoadInitialCache(t *testing.T) {
 		testOrder("test3"), testOrder("test4"),
 	}
 
-	str.LoadInitialCache(v)
+	str.LoadInitialCache(context.Background(), v)
 
 	if len(v) != len(str.Cache) {
 		t.Error("can't load all initial values")
@@ -65,7 +67,7 @@ func TestClean(t *testing.T) {
 		Cache: make(map[string]MAPData),
 	}
 	str.Cache[orderUID] = MAPData{
-		Expired: time.Now().Add(time.Second).Unix(),
+		Expired: time.Now().Add(time.Second).UnixNano(),
 		Data:    testOrder("test"),
 	}
 	time.Sleep(time.Second)
@@ -128,3 +130,10 @@ func testOrder(orderUID string) *order.Order {
 		OOFShard:          "1",
 	}
 }
+
+func TestConformance(t *testing.T) {
+	str := NewMapStorageWithTTL(time.Second)
+	defer str.Shutdown()
+
+	storagetest.RunCacher(t, str, time.Second)
+}
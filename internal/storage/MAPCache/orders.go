@@ -1,48 +1,73 @@
 package mapcache
 
 import (
+	"context"
 	order "first-task/internal/entities/Order"
 	"time"
 )
 
 func (s *MAPStorage) clean() {
-	stamp := time.Now().Unix()
-	for _, v := range s.Cache {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stamp := time.Now().UnixNano()
+	for k, v := range s.Cache {
 		if (v.Expired - stamp) <= 0 {
-			delete(s.Cache, v.Data.OrderUID)
+			delete(s.Cache, k)
 		}
 	}
 }
 
-func (s *MAPStorage) LoadInitialCache(ords []*order.Order) {
+func (s *MAPStorage) LoadInitialCache(ctx context.Context, ords []*order.Order) error {
 	for _, v := range ords {
 		if v == nil {
 			continue
 		}
-		s.Add(v)
+		s.Add(ctx, v)
 	}
+	return nil
 }
 
-func (s *MAPStorage) Add(ord *order.Order) {
-	if _, ok := s.Cache[ord.OrderUID]; !ok {
-		s.Cache[ord.OrderUID] = MAPData{
-			Expired: time.Now().Add(time.Second * 10).Unix(),
-			Data:    ord,
-		}
+func (s *MAPStorage) expiration() int64 {
+	ttl := s.ttl
+	if ttl <= 0 {
+		ttl = DefaultTTL
 	}
+	return time.Now().Add(ttl).UnixNano()
 }
 
-func (s *MAPStorage) Find(orderUID string) *order.Order {
-	if v, ok := s.Cache[orderUID]; ok {
-		tmp := s.Cache[orderUID]
-		tmp.Expired = time.Now().Add(time.Second * 10).Unix()
-		s.Cache[orderUID] = tmp
-		return v.Data
+func (s *MAPStorage) Add(_ context.Context, ord *order.Order) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Cache[ord.OrderUID] = MAPData{
+		Expired: s.expiration(),
+		Data:    ord,
 	}
+}
 
-	return nil
+// expired values are not returned even if cleaner hasn't removed them yet
+func (s *MAPStorage) Find(_ context.Context, orderUID string) *order.Order {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, ok := s.Cache[orderUID]
+	if !ok {
+		return nil
+	}
+	if v.Expired <= time.Now().UnixNano() {
+		delete(s.Cache, orderUID)
+		return nil
+	}
+
+	v.Expired = s.expiration()
+	s.Cache[orderUID] = v
+	return v.Data
 }
 
-func (s *MAPStorage) Delete(orderUID string) {
+func (s *MAPStorage) Delete(_ context.Context, orderUID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	delete(s.Cache, orderUID)
 }
@@ -0,0 +1,130 @@
+package fieldcrypt
+
+import (
+	"errors"
+	"first-task/internal/config"
+	delivery "first-task/internal/entities/Delivery"
+	order "first-task/internal/entities/Order"
+	"first-task/pkg/envelope"
+	"first-task/pkg/mask"
+	"fmt"
+)
+
+var ErrNoKeys = errors.New("encryption key file isn't set")
+
+// Cipher encrypts configured delivery fields of orders, nil Cipher keeps
+// orders as is
+type Cipher struct {
+	kr     *envelope.Keyring
+	fields map[string]bool
+}
+
+// returns nil without key file, if key file or fields are wrong throw panic
+func NewCipher(ce config.EncryptionConfig) *Cipher {
+	if ce.KeyFile == "" {
+		return nil
+	}
+
+	kr, err := envelope.LoadKeyring(ce.KeyFile)
+	if err != nil {
+		panic(err)
+	}
+	return New(kr, ce.Fields)
+}
+
+// if field is unknown throw panic
+func New(kr *envelope.Keyring, fields []string) *Cipher {
+	c := &Cipher{kr: kr, fields: make(map[string]bool, len(fields))}
+	for _, f := range fields {
+		if _, ok := new(delivery.Delivery).Fields()[f]; !ok {
+			panic(fmt.Sprintf("unknown encrypted field %q, known are %v", f, mask.Fields))
+		}
+		c.fields[f] = true
+	}
+	return c
+}
+
+// Seal returns copy of ord with encrypted fields, ord isn't changed. Values
+// of ord are plain, so configured fields are always encrypted and value of
+// other field which looks encrypted is encrypted too, otherwise Open couldn't
+// read it back.
+func (c *Cipher) Seal(ord *order.Order) (*order.Order, error) {
+	const op = "internal.storage.fieldcrypt.Seal"
+
+	if c == nil || ord == nil {
+		return ord, nil
+	}
+
+	sealed := *ord
+	for f, v := range sealed.Delivery.Fields() {
+		if !c.fields[f] && !envelope.IsEncrypted(*v) {
+			continue
+		}
+		enc, err := c.kr.Encrypt(*v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", op, f, err)
+		}
+		*v = enc
+	}
+
+	return &sealed, nil
+}
+
+// Open decrypts every encrypted field of ord in place
+func (c *Cipher) Open(ord *order.Order) error {
+	const op = "internal.storage.fieldcrypt.Open"
+
+	if c == nil || ord == nil {
+		return nil
+	}
+
+	for f, v := range ord.Delivery.Fields() {
+		dec, err := c.kr.Decrypt(*v)
+		if err != nil {
+			return fmt.Errorf("%s: %s of %s: %w", op, f, ord.OrderUID, err)
+		}
+		*v = dec
+	}
+
+	return nil
+}
+
+// Reseal brings d to configured state: configured fields are encrypted by
+// primary key, other ones are plain except values which look encrypted, see
+// Seal. Returns false if d is already there.
+func (c *Cipher) Reseal(d *delivery.Delivery) (bool, error) {
+	const op = "internal.storage.fieldcrypt.Reseal"
+
+	if c == nil {
+		return false, fmt.Errorf("%s: %w", op, ErrNoKeys)
+	}
+
+	var changed bool
+	for f, v := range d.Fields() {
+		encrypted := envelope.IsEncrypted(*v)
+		if c.fields[f] && encrypted && envelope.KeyID(*v) == c.kr.Primary() {
+			continue
+		}
+		if !c.fields[f] && !encrypted {
+			continue
+		}
+
+		plain, err := c.kr.Decrypt(*v)
+		if err != nil {
+			return false, fmt.Errorf("%s: %s: %w", op, f, err)
+		}
+		seal := c.fields[f] || envelope.IsEncrypted(plain)
+		if seal && envelope.KeyID(*v) == c.kr.Primary() {
+			continue
+		}
+		if seal {
+			if plain, err = c.kr.Encrypt(plain); err != nil {
+				return false, fmt.Errorf("%s: %s: %w", op, f, err)
+			}
+		}
+		*v = plain
+		changed = true
+	}
+
+	return changed, nil
+}
@@ -0,0 +1,120 @@
+package fieldcrypt
+
+import (
+	"errors"
+	delivery "first-task/internal/entities/Delivery"
+	order "first-task/internal/entities/Order"
+	"first-task/pkg/envelope"
+	"testing"
+)
+
+var plain = delivery.Delivery{
+	Name:    "Test Testov",
+	Phone:   "+9720000000",
+	Zip:     "2639809",
+	City:    "Kiryat Mozkin",
+	Address: "Ploshad Mira 15",
+	Region:  "Kraiot",
+	Email:   "test@gmail.com",
+}
+
+func newKeyring(t *testing.T, kf *envelope.KeyFile, id string) *envelope.Keyring {
+	t.Helper()
+	if err := kf.Rotate(id); err != nil {
+		t.Fatal(err)
+	}
+	kr, err := envelope.NewKeyring(kf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return kr
+}
+
+func TestSealOpen(t *testing.T) {
+	c := New(newKeyring(t, new(envelope.KeyFile), "k1"), []string{"name", "phone", "email", "address"})
+	ord := &order.Order{OrderUID: "test", Delivery: plain}
+
+	sealed, err := c.Seal(ord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ord.Delivery != plain {
+		t.Error("Seal changed original order")
+	}
+
+	d := sealed.Delivery
+	for _, v := range []string{d.Name, d.Phone, d.Email, d.Address} {
+		if !envelope.IsEncrypted(v) {
+			t.Errorf("field isn't encrypted: %s", v)
+		}
+	}
+	if d.City != plain.City || d.Zip != plain.Zip || d.Region != plain.Region {
+		t.Errorf("not configured fields are encrypted: %+v", d)
+	}
+
+	if err := c.Open(sealed); err != nil {
+		t.Fatal(err)
+	}
+	if sealed.Delivery != plain {
+		t.Errorf("wrong decrypted delivery\nwait: %+v\nget: %+v", plain, sealed.Delivery)
+	}
+
+	// input which looks encrypted is encrypted in any field and read as is
+	tricky := &order.Order{OrderUID: "tricky", Delivery: plain}
+	tricky.Delivery.Name = "enc:v1:x"
+	tricky.Delivery.City = "enc:v1:k1:a:b"
+	sealed, err = c.Seal(tricky)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sealed.Delivery.Name == tricky.Delivery.Name || sealed.Delivery.City == tricky.Delivery.City {
+		t.Errorf("value which looks encrypted is kept plain: %+v", sealed.Delivery)
+	}
+	if err := c.Open(sealed); err != nil {
+		t.Fatal(err)
+	}
+	if sealed.Delivery != tricky.Delivery {
+		t.Errorf("wrong decrypted delivery\nwait: %+v\nget: %+v", tricky.Delivery, sealed.Delivery)
+	}
+}
+
+func TestReseal(t *testing.T) {
+	kf := new(envelope.KeyFile)
+	old := New(newKeyring(t, kf, "k1"), []string{"name", "phone"})
+	d := plain
+	// not configured field which looks encrypted
+	d.City = "enc:v1:x"
+	ord, err := old.Seal(&order.Order{Delivery: d})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := New(newKeyring(t, kf, "k2"), []string{"phone", "email"})
+	d = ord.Delivery
+	changed, err := c.Reseal(&d)
+	if err != nil || !changed {
+		t.Fatalf("delivery isn't resealed: %v", err)
+	}
+	if d.Name != plain.Name {
+		t.Errorf("not configured field isn't decrypted: %s", d.Name)
+	}
+	if envelope.KeyID(d.Phone) != "k2" || envelope.KeyID(d.Email) != "k2" || envelope.KeyID(d.City) != "k2" {
+		t.Errorf("fields aren't encrypted by primary key: %+v", d)
+	}
+	opened := &order.Order{Delivery: d}
+	if err := c.Open(opened); err != nil || opened.Delivery.City != "enc:v1:x" {
+		t.Errorf("wrong decrypted city %q: %v", opened.Delivery.City, err)
+	}
+
+	if changed, err := c.Reseal(&d); err != nil || changed {
+		t.Errorf("resealed delivery is changed again: %v", err)
+	}
+
+	var nilCipher *Cipher
+	if _, err := nilCipher.Reseal(&d); !errors.Is(err, ErrNoKeys) {
+		t.Errorf("wrong error without keys: %v", err)
+	}
+	if sealed, err := nilCipher.Seal(ord); err != nil || sealed != ord {
+		t.Error("nil cipher changed order")
+	}
+}
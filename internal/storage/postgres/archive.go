@@ -0,0 +1,35 @@
+package postgres
+
+import (
+	"context"
+	order "first-task/internal/entities/Order"
+	"fmt"
+	"time"
+)
+
+// OrdersBetween returns page of orders created in [from, to) with id greater
+// than afterID and id of the last one for next page, pages are read from
+// primary because replica may lag behind cutoff. Encrypted fields aren't
+// decrypted, so archive files don't hold plain personal data.
+func (p *Postgres) OrdersBetween(
+	ctx context.Context, from, to time.Time, afterID int64, limit int,
+) (_ []*order.Order, _ int64, err error) {
+	const op = "internal.storage.postgres.OrdersBetween"
+	ctx, done := instrument(ctx, "orders_between")
+	defer done(&err)
+
+	var result []*order.Order
+	var ids []int64
+	result, ids, err = selectOrders(ctx, p.conn, `
+	where o.created_at >= $1 and o.created_at < $2 and o.id > $3
+	order by o.id limit $4`, from, to, afterID, limit,
+	)
+	if err != nil {
+		return nil, afterID, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(ids) > 0 {
+		afterID = ids[len(ids)-1]
+	}
+
+	return result, afterID, nil
+}
@@ -0,0 +1,75 @@
+package postgres
+
+import (
+	"context"
+	delivery "first-task/internal/entities/Delivery"
+	"fmt"
+)
+
+type deliveryRow struct {
+	ID int64 `db:"id"`
+	delivery.Delivery
+}
+
+// ResealDeliveries re-encrypts delivery rows by batches after rotation of
+// keys or change of encrypted fields, returns count of changed rows
+func (p *Postgres) ResealDeliveries(ctx context.Context, batchSize int) (int, error) {
+	const op = "internal.storage.postgres.ResealDeliveries"
+
+	var changed int
+	var lastID int64
+	for {
+		n, last, err := p.resealBatch(ctx, lastID, batchSize)
+		changed += n
+		if err != nil {
+			return changed, fmt.Errorf("%s: %w", op, err)
+		}
+		if last == lastID {
+			return changed, nil
+		}
+		lastID = last
+	}
+}
+
+// rows are locked, so erasure can't be overwritten by old values
+func (p *Postgres) resealBatch(ctx context.Context, afterID int64, limit int) (int, int64, error) {
+	tx, err := p.conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, afterID, err
+	}
+
+	rows := make([]deliveryRow, 0, limit)
+	err = tx.SelectContext(ctx, &rows, fmt.Sprintf(`
+		select id, name, phone, zip, city, address, region, email from %s
+		where id > $1 order by id limit $2 for update;`, DeliveryInfoTable,
+	), afterID, limit)
+	if err != nil || len(rows) == 0 {
+		return 0, afterID, HandleTxErr(tx, err)
+	}
+
+	var changed int
+	for i := range rows {
+		ok, err := p.cipher.Reseal(&rows[i].Delivery)
+		if err != nil {
+			return 0, afterID, HandleTxErr(tx, err)
+		}
+		if !ok {
+			continue
+		}
+
+		_, err = tx.ExecContext(ctx, fmt.Sprintf(`
+			update %s set name = $1, phone = $2, zip = $3, city = $4,
+			address = $5, region = $6, email = $7 where id = $8;`, DeliveryInfoTable,
+		), append(rows[i].GetDataForSQLString(), rows[i].ID)...)
+		if err != nil {
+			return 0, afterID, HandleTxErr(tx, err)
+		}
+		changed++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, afterID, err
+	}
+
+	return changed, rows[len(rows)-1].ID, nil
+}
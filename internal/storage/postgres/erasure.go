@@ -0,0 +1,230 @@
+package postgres
+
+import (
+	"context"
+	"first-task/internal/storage"
+	"fmt"
+	"slices"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ids of order rows, order_uid can be in several partitions
+type orderRef struct {
+	ID         int64  `db:"id"`
+	OrderUID   string `db:"order_uid"`
+	DeliveryID int64  `db:"delivery_id"`
+	PaymentID  int64  `db:"payment_id"`
+}
+
+// tables with rows of orders, archived partition keeps its rows in own
+// tables of archive schema
+type orderTables struct {
+	orders      string
+	ordersItems string
+	delivery    string
+	payment     string
+}
+
+var liveTables = orderTables{
+	orders:      OrdersTable,
+	ordersItems: OrdersItemsTable,
+	delivery:    DeliveryInfoTable,
+	payment:     PaymentInfoTable,
+}
+
+// archived partitions are tables named like partitions outside of current
+// schema, tables of their rows are made by RemoveOldPartitions
+const archivedPartitionsSQL = `
+	select n.nspname, c.relname from pg_class c
+	join pg_namespace n on n.oid = c.relnamespace
+	where c.relkind = 'r' and not c.relispartition
+		and n.nspname <> current_schema() and c.relname ~ $1
+	order by n.nspname, c.relname;`
+
+// live tables and tables of every archived partition, erasure goes through
+// all of them, otherwise archive keeps personal data
+func erasedTables(ctx context.Context, tx *sqlx.Tx) ([]orderTables, error) {
+	rows, err := tx.QueryContext(ctx, archivedPartitionsSQL, "^"+PartitionPrefix+"[0-9]{6}$")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tables := []orderTables{liveTables}
+	for rows.Next() {
+		var schema, name string
+		if err := rows.Scan(&schema, &name); err != nil {
+			return nil, err
+		}
+		qualified := func(table string) string {
+			return pq.QuoteIdentifier(schema) + "." + pq.QuoteIdentifier(table)
+		}
+		tables = append(tables, orderTables{
+			orders:      qualified(name),
+			ordersItems: qualified(name + "_" + OrdersItemsTable),
+			delivery:    qualified(name + "_" + DeliveryInfoTable),
+			payment:     qualified(name + "_" + PaymentInfoTable),
+		})
+	}
+
+	return tables, rows.Err()
+}
+
+func (p *Postgres) Delete(ctx context.Context, orderUID string) (err error) {
+	const op = "internal.storage.postgres.Delete"
+	ctx, done := instrument(ctx, "delete")
+	defer done(&err)
+
+	tx, err := p.conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	tables, err := erasedTables(ctx, tx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, HandleTxErr(tx, err))
+	}
+
+	found := false
+	for _, t := range tables {
+		refs, err := lockOrders(ctx, tx, t, "order_uid", orderUID)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, HandleTxErr(tx, err))
+		}
+		if err := deleteOrders(ctx, tx, t, refs); err != nil {
+			return fmt.Errorf("%s: %w", op, HandleTxErr(tx, err))
+		}
+		found = found || len(refs) > 0
+	}
+	if !found {
+		return fmt.Errorf("%s: %w", op, HandleTxErr(tx, storage.ErrNotFound))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (p *Postgres) EraseCustomer(ctx context.Context, er *storage.Erasure) (err error) {
+	const op = "internal.storage.postgres.EraseCustomer"
+	ctx, done := instrument(ctx, "erase_customer")
+	defer done(&err)
+
+	tx, err := p.conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var erase func(context.Context, *sqlx.Tx, orderTables, []orderRef) error
+	switch er.Mode {
+	case storage.ErasureDelete:
+		erase = deleteOrders
+	case storage.ErasureAnonymize:
+		erase = anonymizeOrders
+	default:
+		err = fmt.Errorf("%w: %s", storage.ErrUnknownErasureMode, er.Mode)
+		return fmt.Errorf("%s: %w", op, HandleTxErr(tx, err))
+	}
+
+	tables, err := erasedTables(ctx, tx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, HandleTxErr(tx, err))
+	}
+
+	// order restored from archive is both in live and archived tables
+	er.OrderUIDs = make([]string, 0)
+	for _, t := range tables {
+		refs, err := lockOrders(ctx, tx, t, "customer_id", er.CustomerID)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, HandleTxErr(tx, err))
+		}
+		if err := erase(ctx, tx, t, refs); err != nil {
+			return fmt.Errorf("%s: %w", op, HandleTxErr(tx, err))
+		}
+		for _, r := range refs {
+			if !slices.Contains(er.OrderUIDs, r.OrderUID) {
+				er.OrderUIDs = append(er.OrderUIDs, r.OrderUID)
+			}
+		}
+	}
+
+	err = tx.QueryRowxContext(ctx, fmt.Sprintf(`
+		insert into %s (customer_id, mode, actor, reason, order_uids, archive_files_kept)
+		values ($1, $2, $3, $4, $5, $6) returning id, created_at;`, ErasuresTable),
+		er.CustomerID, er.Mode, er.Actor, er.Reason, pq.Array(er.OrderUIDs),
+		er.ArchiveFilesKept,
+	).Scan(&er.ID, &er.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, HandleTxErr(tx, err))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// column is order_uid or customer_id
+func lockOrders(ctx context.Context, tx *sqlx.Tx, t orderTables, column, value string) ([]orderRef, error) {
+	refs := make([]orderRef, 0)
+	err := tx.SelectContext(ctx, &refs, fmt.Sprintf(`
+		select id, order_uid, delivery_id, payment_id from %s
+		where %s = $1 order by id for update;`, t.orders, column,
+	), value)
+
+	return refs, err
+}
+
+func deleteOrders(ctx context.Context, tx *sqlx.Tx, t orderTables, refs []orderRef) error {
+	if len(refs) == 0 {
+		return nil
+	}
+
+	ids, deliveryIDs, paymentIDs := splitRefs(refs)
+	queries := []struct {
+		query string
+		ids   []int64
+	}{
+		{fmt.Sprintf(`delete from %s where order_id = any($1);`, t.ordersItems), ids},
+		{fmt.Sprintf(`delete from %s where id = any($1);`, t.orders), ids},
+		{fmt.Sprintf(`delete from %s where id = any($1);`, t.delivery), deliveryIDs},
+		{fmt.Sprintf(`delete from %s where id = any($1);`, t.payment), paymentIDs},
+	}
+	for _, q := range queries {
+		if _, err := tx.ExecContext(ctx, q.query, pq.Array(q.ids)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// personal data of delivery is replaced, payments and items are kept
+func anonymizeOrders(ctx context.Context, tx *sqlx.Tx, t orderTables, refs []orderRef) error {
+	if len(refs) == 0 {
+		return nil
+	}
+
+	_, deliveryIDs, _ := splitRefs(refs)
+	_, err := tx.ExecContext(ctx, fmt.Sprintf(`
+		update %s set name = $2, phone = $2, address = $2, email = $2
+		where id = any($1);`, t.delivery,
+	), pq.Array(deliveryIDs), storage.Anonymized)
+
+	return err
+}
+
+func splitRefs(refs []orderRef) (ids, deliveryIDs, paymentIDs []int64) {
+	ids = make([]int64, len(refs))
+	deliveryIDs = make([]int64, len(refs))
+	paymentIDs = make([]int64, len(refs))
+	for i, r := range refs {
+		ids[i], deliveryIDs[i], paymentIDs[i] = r.ID, r.DeliveryID, r.PaymentID
+	}
+	return ids, deliveryIDs, paymentIDs
+}
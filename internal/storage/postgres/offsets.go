@@ -0,0 +1,119 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	order "first-task/internal/entities/Order"
+	"first-task/internal/storage"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// AddWithOffset saves order and position of consumer after its message in
+// one transaction, so message is either saved with offset or read again
+func (p *Postgres) AddWithOffset(ctx context.Context, ord *order.Order, off storage.KafkaOffset) (err error) {
+	ctx, done := instrument(ctx, "add_with_offset")
+	defer done(&err)
+
+	return p.add(ctx, ord, &off)
+}
+
+// SaveOffset moves position of consumer without order, e.g. after rejected
+// message
+func (p *Postgres) SaveOffset(ctx context.Context, off storage.KafkaOffset) (err error) {
+	const op = "internal.storage.postgres.SaveOffset"
+	ctx, done := instrument(ctx, "save_offset")
+	defer done(&err)
+
+	if err := saveOffset(ctx, p.conn, off); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Offsets are read from primary, replica may keep old ones
+func (p *Postgres) Offsets(ctx context.Context, topic string) (_ []storage.KafkaOffset, err error) {
+	const op = "internal.storage.postgres.Offsets"
+	ctx, done := instrument(ctx, "offsets")
+	defer done(&err)
+
+	offs := make([]storage.KafkaOffset, 0)
+	err = p.conn.SelectContext(ctx, &offs, fmt.Sprintf(`
+		select topic, partition_id, next_offset from %s
+		where topic = $1 order by partition_id;`, KafkaOffsetsTable),
+		topic,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return offs, nil
+}
+
+// offset never goes back, message processed twice doesn't rewind consumer
+func saveOffset(ctx context.Context, ext sqlx.ExecerContext, off storage.KafkaOffset) error {
+	_, err := ext.ExecContext(ctx, fmt.Sprintf(`
+		insert into %[1]s (topic, partition_id, next_offset) values ($1, $2, $3)
+		on conflict (topic, partition_id) do update
+		set next_offset = greatest(%[1]s.next_offset, excluded.next_offset),
+			updated_at = now();`, KafkaOffsetsTable),
+		off.Topic, off.Partition, off.Offset,
+	)
+	return err
+}
+
+// session locks are held by connection, $1 - topic
+const (
+	lockTopicSQL   = `select pg_try_advisory_lock(hashtext('kafka_offsets'), hashtext($1));`
+	unlockTopicSQL = `select pg_advisory_unlock(hashtext('kafka_offsets'), hashtext($1));`
+)
+
+type topicLock struct {
+	conn  *sql.Conn
+	topic string
+}
+
+// LockTopic takes advisory lock of topic on its own connection, the lock is
+// held until Unlock or until connection is lost, so consumers of several
+// replicas don't read the same partitions
+func (p *Postgres) LockTopic(ctx context.Context, topic string) (_ storage.TopicLock, err error) {
+	const op = "internal.storage.postgres.LockTopic"
+	ctx, done := instrument(ctx, "lock_topic")
+	defer done(&err)
+
+	conn, err := p.conn.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, lockTopicSQL, topic).Scan(&locked); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if !locked {
+		conn.Close()
+		return nil, fmt.Errorf("%s: %w", op, storage.ErrTopicLocked)
+	}
+
+	return &topicLock{conn: conn, topic: topic}, nil
+}
+
+func (l *topicLock) Ping(ctx context.Context) error {
+	return l.conn.PingContext(ctx)
+}
+
+// lock of broken connection is released by postgres
+func (l *topicLock) Unlock() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := l.conn.ExecContext(ctx, unlockTopicSQL, l.topic)
+	if cerr := l.conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -1,62 +1,102 @@
 package postgres
 
 import (
-	"database/sql"
-	"encoding/json"
+	"context"
 	"errors"
 	order "first-task/internal/entities/Order"
+	"first-task/internal/events"
 	"first-task/internal/storage"
 	"fmt"
-	"sync"
 
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
 )
 
-func (p *Postgres) Add(ord *order.Order) error {
+func (p *Postgres) Add(ctx context.Context, ord *order.Order) (err error) {
+	ctx, done := instrument(ctx, "add")
+	defer done(&err)
+
+	return p.add(ctx, ord, nil)
+}
+
+// add saves off in the same transaction as order if it isn't nil
+func (p *Postgres) add(ctx context.Context, ord *order.Order, off *storage.KafkaOffset) error {
 	const op = "internal.storage.postgres.AddOrder"
 
+	// event keeps plain values
+	ingested := events.NewOrderIngested(ord)
+
+	ord, err := p.cipher.Seal(ord)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
 	var lastInsertDeliverID int64
 	var lastInsertPaymentID int64
 	var lastInsertIDOrder int64
 
-	transaction, err := p.conn.Beginx()
+	transaction, err := p.conn.BeginTxx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	err = sqlx.Get(
-		transaction, &lastInsertDeliverID, GetInsertDeliverySQLString(),
+	if _, err := transaction.ExecContext(ctx, lockOrderUIDSQL, ord.OrderUID); err != nil {
+		return fmt.Errorf("%s: %w", op, HandleTxErr(transaction, err))
+	}
+	var exists bool
+	err = sqlx.GetContext(ctx, transaction, &exists, GetOrderExistsSQLString(), ord.OrderUID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, HandleTxErr(transaction, err))
+	}
+	if exists {
+		return fmt.Errorf("%s: %w", op, HandleTxErr(transaction, storage.ErrDuplicate))
+	}
+
+	err = sqlx.GetContext(
+		ctx, transaction, &lastInsertDeliverID, GetInsertDeliverySQLString(),
 		ord.Delivery.GetDataForSQLString()...,
 	)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, HandleTxErr(transaction, err))
 	}
 
-	err = sqlx.Get(
-		transaction, &lastInsertPaymentID, GetInsertPaymentSQLString(),
+	err = sqlx.GetContext(
+		ctx, transaction, &lastInsertPaymentID, GetInsertPaymentSQLString(),
 		ord.Payment.GetDataForSQLString()...,
 	)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, HandleTxErr(transaction, err))
 	}
 
-	err = sqlx.Get(
-		transaction, &lastInsertIDOrder, GetInsertOrderSQLString(),
+	err = sqlx.GetContext(
+		ctx, transaction, &lastInsertIDOrder, GetInsertOrderSQLString(),
 		ord.GetDataForSQLString(lastInsertDeliverID, lastInsertPaymentID)...,
 	)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, HandleTxErr(transaction, err))
 	}
 
-	_, err = transaction.Exec(
-		GetInsertOrdersItemsSQLString(len(ord.Items)),
+	_, err = transaction.ExecContext(
+		ctx, GetInsertOrdersItemsSQLString(len(ord.Items)),
 		ord.GetDataForSQLStringOrdersItems(lastInsertIDOrder)...,
 	)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, HandleTxErr(transaction, err))
 	}
 
+	if off != nil {
+		if err := saveOffset(ctx, transaction, *off); err != nil {
+			return fmt.Errorf("%s: %w", op, HandleTxErr(transaction, err))
+		}
+	}
+
+	if p.outbox {
+		err := addEvent(ctx, transaction, events.TypeOrderIngested, ingested.OrderUID, ingested)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, HandleTxErr(transaction, err))
+		}
+	}
+
 	err = transaction.Commit()
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, HandleTxErr(transaction, err))
@@ -65,57 +105,55 @@ func (p *Postgres) Add(ord *order.Order) error {
 	return nil
 }
 
-func (p *Postgres) Find(orderUID string) (*order.Order, error) {
+func (p *Postgres) Find(ctx context.Context, orderUID string) (_ *order.Order, err error) {
 	const op = "internal.storage.postgres.FindOrder"
-
-	var tmp []byte
-	err := p.conn.Get(&tmp, GetOrderJSONFromDataBase, orderUID)
-	if errors.Is(err, sql.ErrNoRows) {
+	ctx, done := instrument(ctx, "find")
+	defer done(&err)
+
+	var result []*order.Order
+	err = p.read(ctx, func(db *sqlx.DB) error {
+		var err error
+		result, _, err = selectOrders(
+			ctx, db, "where o.order_uid=$1 order by o.id desc limit 1", orderUID,
+		)
+		if err == nil && len(result) == 0 {
+			return storage.ErrNotFound
+		}
+		return err
+	})
+	if errors.Is(err, storage.ErrNotFound) {
 		return nil, storage.ErrNotFound
-	} else if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
 	}
-
-	var result *order.Order
-	err = json.Unmarshal(tmp, &result)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+	if err := p.open(result); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
 
-	return result, nil
+	return result[0], nil
 }
 
-func (p *Postgres) GetInitialData(size int) ([]*order.Order, error) {
+func (p *Postgres) GetInitialData(ctx context.Context, size int) (_ []*order.Order, err error) {
 	const op = "internal.storage.postgres.GetInitialData"
-
-	tmpData := make([]string, 0, size)
-	result := make([]*order.Order, size)
-
-	err := p.conn.Select(&tmpData, GetLastOrdersJSONFromDataBase(size))
+	ctx, done := instrument(ctx, "initial_data")
+	defer done(&err)
+
+	var result []*order.Order
+	err = p.read(ctx, func(db *sqlx.DB) error {
+		var err error
+		result, _, err = selectOrders(ctx, db, "order by o.id desc limit $1", size)
+		return err
+	})
 	if err != nil {
 		return []*order.Order{}, fmt.Errorf("%s: %w", op, err)
 	}
-
-	wg := &sync.WaitGroup{}
-	wg.Add(len(tmpData))
-	for i, v := range tmpData {
-		go func(wg *sync.WaitGroup, i int, str string) {
-			defer wg.Done()
-			newOrd := new(order.Order)
-			if err := json.Unmarshal([]byte(str), newOrd); err == nil {
-				result[i] = newOrd
-			}
-		}(wg, i, v)
+	if result == nil {
+		result = []*order.Order{}
 	}
-	wg.Wait()
-
-	filteredResult := make([]*order.Order, 0, size)
-	for _, v := range result {
-		if v == nil {
-			continue
-		}
-		filteredResult = append(filteredResult, v)
+	if err := p.open(result); err != nil {
+		return []*order.Order{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return filteredResult, nil
+	return result, nil
 }
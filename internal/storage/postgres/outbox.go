@@ -0,0 +1,84 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"first-task/internal/storage"
+	"first-task/internal/tracing"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// PublishOutbox locks up to limit oldest events, passes them to publish and
+// deletes them if publish succeeds. Event is deleted only after it's
+// published, so it may be published twice, but isn't lost. Locked events are
+// skipped, so relays of several replicas don't wait for each other.
+func (p *Postgres) PublishOutbox(
+	ctx context.Context, limit int,
+	publish func(context.Context, []storage.OutboxEvent) error,
+) (_ int, err error) {
+	const op = "internal.storage.postgres.PublishOutbox"
+	ctx, done := instrument(ctx, "publish_outbox")
+	defer done(&err)
+
+	tx, err := p.conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	evs := make([]storage.OutboxEvent, 0, limit)
+	err = tx.SelectContext(ctx, &evs, fmt.Sprintf(`
+		select id, event_type, event_key, payload, trace_context from %s
+		order by id limit $1 for update skip locked;`, OutboxTable),
+		limit,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, HandleTxErr(tx, err))
+	}
+	if len(evs) == 0 {
+		return 0, tx.Rollback()
+	}
+
+	if err := publish(ctx, evs); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, HandleTxErr(tx, err))
+	}
+
+	ids := make([]int64, 0, len(evs))
+	for _, ev := range evs {
+		ids = append(ids, ev.ID)
+	}
+	_, err = tx.ExecContext(ctx, fmt.Sprintf(
+		`delete from %s where id = any($1);`, OutboxTable,
+	), pq.Array(ids))
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, HandleTxErr(tx, err))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return len(evs), nil
+}
+
+// trace context of ctx is kept with event, so consumer's spans are in the
+// trace of message which brought order
+func addEvent(ctx context.Context, tx *sqlx.Tx, typ, key string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	trace, err := json.Marshal(tracing.Carrier(ctx))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, fmt.Sprintf(`
+		insert into %s (event_type, event_key, payload, trace_context)
+		values ($1, $2, $3, $4);`, OutboxTable),
+		typ, key, data, trace,
+	)
+	return err
+}
@@ -0,0 +1,266 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const (
+	PartitionPrefix  = OrdersTable + "_p"
+	DefaultPartition = OrdersTable + "_default"
+
+	partitionLayout = "200601"
+)
+
+// MonthStart returns first moment of month of t in UTC
+func MonthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func PartitionName(month time.Time) string {
+	return PartitionPrefix + MonthStart(month).Format(partitionLayout)
+}
+
+// ParsePartitionName returns month of partition, ok is false for default
+// partition and other tables
+func ParsePartitionName(name string) (time.Time, bool) {
+	suffix, found := strings.CutPrefix(name, PartitionPrefix)
+	if !found {
+		return time.Time{}, false
+	}
+	month, err := time.Parse(partitionLayout, suffix)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return month, true
+}
+
+// EnsurePartitions creates monthly partitions of orders from month of now
+// to ahead months later, returns names of created ones
+func (p *Postgres) EnsurePartitions(ctx context.Context, now time.Time, ahead int) ([]string, error) {
+	const op = "internal.storage.postgres.EnsurePartitions"
+
+	created := make([]string, 0, ahead+1)
+	month := MonthStart(now)
+	for i := 0; i <= ahead; i++ {
+		ok, err := p.createPartition(ctx, month)
+		if err != nil {
+			return created, fmt.Errorf("%s: %w", op, err)
+		}
+		if ok {
+			created = append(created, PartitionName(month))
+		}
+		month = month.AddDate(0, 1, 0)
+	}
+
+	return created, nil
+}
+
+func (p *Postgres) createPartition(ctx context.Context, month time.Time) (bool, error) {
+	name := PartitionName(month)
+	from, to := MonthStart(month), MonthStart(month).AddDate(0, 1, 0)
+
+	tx, err := p.conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+
+	var exists bool
+	err = tx.GetContext(ctx, &exists, `select to_regclass($1) is not null;`, name)
+	if err != nil || exists {
+		return false, HandleTxErr(tx, err)
+	}
+
+	var inDefault int
+	err = tx.GetContext(ctx, &inDefault, fmt.Sprintf(
+		`select count(*) from %s where created_at >= $1 and created_at < $2;`,
+		DefaultPartition,
+	), from, to)
+	if err != nil {
+		return false, HandleTxErr(tx, err)
+	}
+
+	create := fmt.Sprintf(
+		`create table %s partition of %s for values from (%s) to (%s);`,
+		pq.QuoteIdentifier(name), OrdersTable,
+		pq.QuoteLiteral(from.Format(time.RFC3339)),
+		pq.QuoteLiteral(to.Format(time.RFC3339)),
+	)
+	queries := []string{create}
+	if inDefault > 0 {
+		// postgres refuses to create partition while default one has its rows,
+		// so rows are moved out of detached default partition
+		queries = []string{
+			fmt.Sprintf(`alter table %s detach partition %s;`, OrdersTable, DefaultPartition),
+			create,
+			fmt.Sprintf(
+				`insert into %s select * from %s where created_at >= $1 and created_at < $2;`,
+				OrdersTable, DefaultPartition,
+			),
+			fmt.Sprintf(
+				`delete from %s where created_at >= $1 and created_at < $2;`,
+				DefaultPartition,
+			),
+			fmt.Sprintf(`alter table %s attach partition %s default;`, OrdersTable, DefaultPartition),
+		}
+	}
+
+	for _, q := range queries {
+		var args []any
+		if strings.Contains(q, "$1") {
+			args = []any{from, to}
+		}
+		if _, err := tx.ExecContext(ctx, q, args...); err != nil {
+			return false, HandleTxErr(tx, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Partitions returns months of existing monthly partitions in order
+func (p *Postgres) Partitions(ctx context.Context) ([]time.Time, error) {
+	const op = "internal.storage.postgres.Partitions"
+
+	names := make([]string, 0)
+	err := p.conn.SelectContext(ctx, &names, `
+		select c.relname from pg_inherits as i
+		join pg_class as c on c.oid = i.inhrelid
+		where i.inhparent = $1::regclass;`, OrdersTable,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	months := make([]time.Time, 0, len(names))
+	for _, n := range names {
+		if m, ok := ParsePartitionName(n); ok {
+			months = append(months, m)
+		}
+	}
+	sort.Slice(months, func(i, j int) bool { return months[i].Before(months[j]) })
+
+	return months, nil
+}
+
+// RemoveOldPartitions detaches partitions which end before `before` and
+// removes their delivery, payment and items rows from live tables.
+// With archiveSchema partition and removed rows are moved to this schema,
+// with empty archiveSchema everything is dropped.
+func (p *Postgres) RemoveOldPartitions(
+	ctx context.Context, before time.Time, archiveSchema string,
+) ([]string, error) {
+	const op = "internal.storage.postgres.RemoveOldPartitions"
+
+	months, err := p.Partitions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	removed := make([]string, 0)
+	for _, m := range months {
+		if m.AddDate(0, 1, 0).After(before) {
+			break
+		}
+		if err := p.removePartition(ctx, PartitionName(m), archiveSchema); err != nil {
+			return removed, fmt.Errorf("%s: %s: %w", op, PartitionName(m), err)
+		}
+		removed = append(removed, PartitionName(m))
+	}
+
+	return removed, nil
+}
+
+func (p *Postgres) removePartition(ctx context.Context, name, archiveSchema string) error {
+	tx, err := p.conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	part := pq.QuoteIdentifier(name)
+	queries := []string{
+		fmt.Sprintf(`alter table %s detach partition %s;`, OrdersTable, part),
+	}
+	if _, err := tx.ExecContext(ctx, queries[0]); err != nil {
+		return HandleTxErr(tx, err)
+	}
+
+	// detached table keeps foreign keys with "on delete cascade", it would
+	// lose its rows when delivery and payment rows are deleted below
+	if err := dropForeignKeys(ctx, tx, name); err != nil {
+		return HandleTxErr(tx, err)
+	}
+
+	dependent := []struct {
+		table string
+		where string
+	}{
+		{OrdersItemsTable, fmt.Sprintf(`order_id in (select id from %s)`, part)},
+		{DeliveryInfoTable, fmt.Sprintf(`id in (select delivery_id from %s)`, part)},
+		{PaymentInfoTable, fmt.Sprintf(`id in (select payment_id from %s)`, part)},
+	}
+
+	queries = queries[:0]
+	if archiveSchema != "" {
+		schema := pq.QuoteIdentifier(archiveSchema)
+		queries = append(queries, fmt.Sprintf(`create schema if not exists %s;`, schema))
+		for _, d := range dependent {
+			queries = append(queries, fmt.Sprintf(
+				`create table %s.%s as select * from %s where %s;`,
+				schema, pq.QuoteIdentifier(name+"_"+d.table), d.table, d.where,
+			))
+		}
+	}
+	for _, d := range dependent {
+		queries = append(queries, fmt.Sprintf(`delete from %s where %s;`, d.table, d.where))
+	}
+	if archiveSchema != "" {
+		queries = append(queries, fmt.Sprintf(
+			`alter table %s set schema %s;`, part, pq.QuoteIdentifier(archiveSchema),
+		))
+	} else {
+		queries = append(queries, fmt.Sprintf(`drop table %s;`, part))
+	}
+
+	for _, q := range queries {
+		if _, err := tx.ExecContext(ctx, q); err != nil {
+			return HandleTxErr(tx, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func dropForeignKeys(ctx context.Context, tx *sqlx.Tx, table string) error {
+	names := make([]string, 0)
+	err := tx.SelectContext(ctx, &names, `
+		select conname from pg_constraint
+		where conrelid = $1::regclass and contype = 'f';`, table,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, n := range names {
+		_, err := tx.ExecContext(ctx, fmt.Sprintf(
+			`alter table %s drop constraint %s;`,
+			pq.QuoteIdentifier(table), pq.QuoteIdentifier(n),
+		))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
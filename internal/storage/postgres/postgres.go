@@ -1,10 +1,21 @@
 package postgres
 
 import (
+	"context"
+	"errors"
 	"first-task/internal/config"
+	order "first-task/internal/entities/Order"
+	"first-task/internal/metrics"
+	"first-task/internal/storage"
+	"first-task/internal/storage/fieldcrypt"
+	"first-task/internal/tracing"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/jmoiron/sqlx"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -14,32 +25,181 @@ const (
 	ItemsTable        = "items"
 	OrdersTable       = "orders"
 	OrdersItemsTable  = "orders_items"
+	ErasuresTable     = "erasures"
+	KafkaOffsetsTable = "kafka_offsets"
+	OutboxTable       = "outbox"
 )
 
 type Postgres struct {
-	conn *sqlx.DB
+	conn     *sqlx.DB
+	replicas *replicaSet
+	cipher   *fieldcrypt.Cipher
+	// Add writes order.ingested event to outbox
+	outbox bool
 }
 
+// if db is still unavailable after all connect attempts throw panic
 func NewPostgres(cp config.PostgresConfig) *Postgres {
-	connString := fmt.Sprintf(
-		`host=%s port=%s user=%s password=%s dbname=%s sslmode=disable`,
-		cp.Host, cp.Port, cp.User, cp.Password, cp.DBName,
-	)
-
-	db, err := sqlx.Open("postgres", connString)
+	db, err := sqlx.Open("postgres", ConnString(cp))
 	if err != nil {
 		panic(err)
 	}
 
-	err = db.Ping()
+	setPool(db, cp)
+
+	err = pingWithBackoff(db.Ping, cp)
 	if err != nil {
 		panic(err)
 	}
 
-	return &Postgres{db}
+	replicas := newReplicaSet(cp, db)
+	replicas.checkAll(context.Background())
+	replicas.startChecking()
+
+	return &Postgres{
+		conn:     db,
+		replicas: replicas,
+	}
+}
+
+// SetCipher makes Add encrypt delivery fields and reads decrypt them,
+// must be called before first use
+func (p *Postgres) SetCipher(c *fieldcrypt.Cipher) {
+	p.cipher = c
+}
+
+// EnableOutbox makes Add write order.ingested event in the same transaction as
+// order, must be called before first use
+func (p *Postgres) EnableOutbox() {
+	p.outbox = true
+}
+
+func (p *Postgres) open(ords []*order.Order) error {
+	for _, ord := range ords {
+		if err := p.cipher.Open(ord); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// instrument starts span of operation op, returned func ends it and records
+// latency and error, missing order isn't error of db
+func instrument(ctx context.Context, op string) (context.Context, func(*error)) {
+	start := time.Now()
+	ctx, span := tracing.Tracer().Start(
+		ctx, "postgres."+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemNamePostgreSQL, semconv.DBOperationName(op)),
+	)
+
+	return ctx, func(err *error) {
+		e := *err
+		if errors.Is(e, storage.ErrNotFound) {
+			e = nil
+		}
+		metrics.ObserveDBQuery(op, start, e)
+		tracing.End(span, e)
+	}
+}
+
+func setPool(db *sqlx.DB, cp config.PostgresConfig) {
+	db.SetMaxOpenConns(cp.MaxOpenConns)
+	if cp.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(cp.MaxIdleConns)
+	}
+	db.SetConnMaxLifetime(cp.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cp.ConnMaxIdleTime)
+}
+
+func pingWithBackoff(ping func() error, cp config.PostgresConfig) error {
+	const op = "internal.storage.postgres.pingWithBackoff"
+
+	attempts := max(cp.ConnectAttempts, 1)
+	backoff := cp.ConnectBackoff
+
+	var err error
+	for i := 1; i <= attempts; i++ {
+		if err = ping(); err == nil {
+			return nil
+		}
+		if i == attempts {
+			break
+		}
+
+		zap.L().Warn(fmt.Sprintf(
+			"postgres is unavailable (attempt %d/%d), retry in %s: %s",
+			i, attempts, backoff, err.Error(),
+		))
+		time.Sleep(backoff)
+
+		backoff *= 2
+		if cp.ConnectMaxBackoff > 0 && backoff > cp.ConnectMaxBackoff {
+			backoff = cp.ConnectMaxBackoff
+		}
+	}
+
+	return fmt.Errorf("%s: %d attempts: %w", op, attempts, err)
+}
+
+// ConnString returns cp.DSN if set, otherwise builds key=value string for lib/pq
+func ConnString(cp config.PostgresConfig) string {
+	if cp.DSN != "" {
+		return cp.DSN
+	}
+
+	sslMode := cp.SSLMode
+	if sslMode == "" || sslMode == "false" {
+		sslMode = "disable"
+	} else if sslMode == "true" {
+		sslMode = "require"
+	}
+
+	params := [][2]string{
+		{"host", cp.Host},
+		{"port", cp.Port},
+		{"user", cp.User},
+		{"password", cp.Password},
+		{"dbname", cp.DBName},
+		{"sslmode", sslMode},
+		{"sslrootcert", cp.SSLRootCert},
+		{"sslcert", cp.SSLCert},
+		{"sslkey", cp.SSLKey},
+	}
+	if cp.StatementTimeout > 0 {
+		// unknown keys are sent by lib/pq as run-time parameters
+		params = append(params, [2]string{
+			"statement_timeout",
+			fmt.Sprint(cp.StatementTimeout.Milliseconds()),
+		})
+	}
+
+	parts := make([]string, 0, len(params))
+	for _, p := range params {
+		if p[1] == "" {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s=%s", p[0], quoteValue(p[1])))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func quoteValue(v string) string {
+	if v != "" && !strings.ContainsAny(v, ` '\`) {
+		return v
+	}
+	r := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	return "'" + r.Replace(v) + "'"
+}
+
+// Ping checks primary, replicas are optional
+func (p *Postgres) Ping(ctx context.Context) error {
+	return p.conn.PingContext(ctx)
 }
 
 func (p *Postgres) Shutdown() {
+	p.replicas.shutdown()
 	if err := p.conn.Close(); err != nil {
 		zap.L().Error(err.Error())
 	}
@@ -0,0 +1,210 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"first-task/internal/config"
+	"first-task/internal/storage"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// wal position of primary, replica is compared with it and not with wal it
+// received, so replica with disconnected wal receiver doesn't look fresh
+const primaryLSNSQL = `select pg_current_wal_lsn()::text;`
+
+// replay lag in seconds, 0 if replica has replayed wal of primary up to $1
+// (on idle primary last replay timestamp gets old without real lag), null
+// if replica is behind and has never replayed a transaction
+const replicaLagSQL = `
+	select case
+		when not pg_is_in_recovery() then 0
+		when pg_last_wal_replay_lsn() >= $1::pg_lsn then 0
+		else extract(epoch from now() - pg_last_xact_replay_timestamp())
+	end;`
+
+type replica struct {
+	name    string
+	conn    *sqlx.DB
+	healthy atomic.Bool
+}
+
+type replicaSet struct {
+	primary  *sqlx.DB
+	replicas []*replica
+	next     atomic.Uint64
+	maxLag   time.Duration
+	interval time.Duration
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newReplicaSet(cp config.PostgresConfig, primary *sqlx.DB) *replicaSet {
+	rs := &replicaSet{
+		primary:  primary,
+		replicas: make([]*replica, 0, len(cp.Replicas)),
+		maxLag:   cp.ReplicaMaxLag,
+		interval: cp.ReplicaCheckInterval,
+		stop:     make(chan struct{}),
+	}
+
+	for i, rc := range cp.Replicas {
+		rcp := cp
+		rcp.DSN = rc.DSN
+		if rc.Host != "" {
+			rcp.Host = rc.Host
+		}
+		if rc.Port != "" {
+			rcp.Port = rc.Port
+		}
+
+		db, err := sqlx.Open("postgres", ConnString(rcp))
+		if err != nil {
+			// replica is optional, app works with primary only
+			zap.L().Error(fmt.Sprintf("can't open replica %d: %s", i, err.Error()))
+			continue
+		}
+		setPool(db, rcp)
+
+		name := rc.DSN
+		if name == "" {
+			name = fmt.Sprintf("%s:%s", rcp.Host, rcp.Port)
+		}
+		rs.replicas = append(rs.replicas, &replica{name: name, conn: db})
+	}
+
+	return rs
+}
+
+// pick returns next healthy replica by round-robin or nil
+func (rs *replicaSet) pick() *replica {
+	n := uint64(len(rs.replicas))
+	if n == 0 {
+		return nil
+	}
+
+	start := rs.next.Add(1)
+	for i := uint64(0); i < n; i++ {
+		r := rs.replicas[(start+i)%n]
+		if r.healthy.Load() {
+			return r
+		}
+	}
+
+	return nil
+}
+
+func (rs *replicaSet) checkAll(ctx context.Context) {
+	if len(rs.replicas) == 0 {
+		return
+	}
+
+	var lsn string
+	err := withCheckTimeout(ctx, rs.interval, func(ctx context.Context) error {
+		return rs.primary.GetContext(ctx, &lsn, primaryLSNSQL)
+	})
+	if err != nil {
+		err = fmt.Errorf("primary wal position: %w", err)
+		for _, r := range rs.replicas {
+			rs.setHealthy(r, false, err, 0)
+		}
+		return
+	}
+
+	for _, r := range rs.replicas {
+		rs.check(ctx, r, lsn)
+	}
+}
+
+func (rs *replicaSet) check(ctx context.Context, r *replica, primaryLSN string) {
+	var lagSeconds sql.NullFloat64
+	err := withCheckTimeout(ctx, rs.interval, func(ctx context.Context) error {
+		return r.conn.GetContext(ctx, &lagSeconds, replicaLagSQL, primaryLSN)
+	})
+	if err == nil && !lagSeconds.Valid {
+		err = errors.New("replica is behind primary and hasn't replayed anything")
+	}
+	lag := time.Duration(lagSeconds.Float64 * float64(time.Second))
+
+	rs.setHealthy(r, err == nil && (rs.maxLag <= 0 || lag <= rs.maxLag), err, lag)
+}
+
+func (rs *replicaSet) setHealthy(r *replica, healthy bool, err error, lag time.Duration) {
+	if was := r.healthy.Swap(healthy); was != healthy {
+		if healthy {
+			zap.L().Info("replica " + r.name + " is back")
+		} else if err != nil {
+			zap.L().Warn("replica " + r.name + " is down: " + err.Error())
+		} else {
+			zap.L().Warn(fmt.Sprintf("replica %s lags for %s", r.name, lag))
+		}
+	}
+}
+
+func withCheckTimeout(ctx context.Context, interval time.Duration, fn func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, max(interval, time.Second))
+	defer cancel()
+	return fn(ctx)
+}
+
+func (rs *replicaSet) markDown(r *replica, err error) {
+	if r.healthy.Swap(false) {
+		zap.L().Warn("replica " + r.name + " failed, use primary: " + err.Error())
+	}
+}
+
+func (rs *replicaSet) startChecking() {
+	if len(rs.replicas) == 0 || rs.interval <= 0 {
+		return
+	}
+
+	rs.wg.Add(1)
+	go func() {
+		defer rs.wg.Done()
+		ticker := time.NewTicker(rs.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-rs.stop:
+				return
+			case <-ticker.C:
+				rs.checkAll(context.Background())
+			}
+		}
+	}()
+}
+
+func (rs *replicaSet) shutdown() {
+	close(rs.stop)
+	rs.wg.Wait()
+	for _, r := range rs.replicas {
+		if err := r.conn.Close(); err != nil {
+			zap.L().Error(err.Error())
+		}
+	}
+}
+
+// read runs fn on replica and falls back to primary if replica fails.
+// Not found on replica is asked on primary too, replica may not have
+// replayed the row yet, but it isn't failure of replica
+func (p *Postgres) read(ctx context.Context, fn func(db *sqlx.DB) error) error {
+	if r := p.replicas.pick(); r != nil {
+		err := fn(r.conn)
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+		if !errors.Is(err, sql.ErrNoRows) && !errors.Is(err, storage.ErrNotFound) {
+			p.replicas.markDown(r, err)
+		}
+	}
+
+	return fn(p.conn)
+}
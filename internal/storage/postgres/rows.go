@@ -0,0 +1,68 @@
+package postgres
+
+import (
+	"context"
+	item "first-task/internal/entities/Item"
+	order "first-task/internal/entities/Order"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// orders row without items, delivery and payment are scanned from
+// "delivery.*" and "payment.*" columns by db tags of order.Order
+type orderRow struct {
+	ID int64 `db:"id"`
+	order.Order
+}
+
+type itemRow struct {
+	OrderID int64 `db:"order_id"`
+	item.Item
+}
+
+// selectOrders returns orders matching cond with their items and ids, items
+// are loaded by one more query for all found orders
+func selectOrders(
+	ctx context.Context, db sqlx.QueryerContext, cond string, args ...any,
+) ([]*order.Order, []int64, error) {
+	rows := make([]orderRow, 0)
+	err := sqlx.SelectContext(ctx, db, &rows, GetSelectOrdersSQLString(cond), args...)
+	if err != nil || len(rows) == 0 {
+		return nil, nil, err
+	}
+
+	ids := make([]int64, len(rows))
+	for i := range rows {
+		ids[i] = rows[i].ID
+	}
+
+	items := make([]itemRow, 0, len(rows))
+	err = sqlx.SelectContext(
+		ctx, db, &items, GetSelectOrdersItemsSQLString(), pq.Array(ids),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return assembleOrders(rows, items), ids, nil
+}
+
+// assembleOrders attaches items to their orders, order of rows is kept
+func assembleOrders(rows []orderRow, items []itemRow) []*order.Order {
+	byID := make(map[int64]*order.Order, len(rows))
+	result := make([]*order.Order, len(rows))
+	for i := range rows {
+		ord := rows[i].Order
+		result[i] = &ord
+		byID[rows[i].ID] = &ord
+	}
+
+	for _, it := range items {
+		if ord, ok := byID[it.OrderID]; ok {
+			ord.Items = append(ord.Items, it.Item)
+		}
+	}
+
+	return result
+}
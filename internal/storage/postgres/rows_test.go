@@ -0,0 +1,157 @@
+package postgres
+
+import (
+	"encoding/json"
+	delivery "first-task/internal/entities/Delivery"
+	item "first-task/internal/entities/Item"
+	order "first-task/internal/entities/Order"
+	payment "first-task/internal/entities/Payment"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jmoiron/sqlx/reflectx"
+)
+
+var columnRe = regexp.MustCompile(`(?:as "([\w.]+)"|\b[a-z]+\.(\w+))`)
+
+// every selected column must have field in row struct, otherwise sqlx
+// fails on scan
+func checkColumns(t *testing.T, query string, row any) {
+	t.Helper()
+
+	selectPart, _, _ := strings.Cut(query, "from")
+	selectPart = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(selectPart), "select"))
+
+	fields := reflectx.NewMapperFunc("db", strings.ToLower).TypeMap(reflect.TypeOf(row)).Names
+	for _, col := range strings.Split(selectPart, ",") {
+		m := columnRe.FindAllStringSubmatch(col, -1)
+		if len(m) == 0 {
+			t.Errorf("can't parse column %q", col)
+			continue
+		}
+		last := m[len(m)-1]
+		name := last[1] + last[2]
+		if _, ok := fields[name]; !ok {
+			t.Errorf("column %s has no field in %T", name, row)
+		}
+	}
+}
+
+func TestRowColumns(t *testing.T) {
+	checkColumns(t, GetSelectOrdersSQLString(""), orderRow{})
+	checkColumns(t, GetSelectOrdersItemsSQLString(), itemRow{})
+}
+
+func TestAssembleOrders(t *testing.T) {
+	rows := []orderRow{
+		{ID: 3, Order: order.Order{OrderUID: "c"}},
+		{ID: 1, Order: order.Order{OrderUID: "a"}},
+		{ID: 2, Order: order.Order{OrderUID: "b"}},
+	}
+	items := []itemRow{
+		{OrderID: 1, Item: item.Item{ChrtID: 10}},
+		{OrderID: 3, Item: item.Item{ChrtID: 30}},
+		{OrderID: 1, Item: item.Item{ChrtID: 11}},
+		{OrderID: 4, Item: item.Item{ChrtID: 40}},
+	}
+
+	ords := assembleOrders(rows, items)
+	want := []*order.Order{
+		{OrderUID: "c", Items: []item.Item{{ChrtID: 30}}},
+		{OrderUID: "a", Items: []item.Item{{ChrtID: 10}, {ChrtID: 11}}},
+		{OrderUID: "b"},
+	}
+	if !reflect.DeepEqual(ords, want) {
+		t.Errorf("wrong orders\nwait: %+v\nget: %+v", want, ords)
+	}
+}
+
+const benchOrders = 10000
+
+func benchOrder(i int) order.Order {
+	return order.Order{
+		OrderUID:    fmt.Sprintf("b563feb7b2b84b6test%d", i),
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery: delivery.Delivery{
+			Name: "Test Testov", Phone: "+9720000000", Zip: "2639809",
+			City: "Kiryat Mozkin", Address: "Ploshad Mira 15", Region: "Kraiot",
+			Email: "test@gmail.com",
+		},
+		Payment: payment.Payment{
+			Transaction: "b563feb7b2b84b6test", RequestID: fmt.Sprint(i),
+			Currency: "USD", Provider: "wbpay", Amount: 1817,
+			PaymentDT: 1637907727, Bank: "alpha", DeliveryCost: 1500,
+			GoodsTotal: 317,
+		},
+		Items: []item.Item{{
+			ChrtID: 9934930, TrackNumber: "WBILMTESTTRACK", Price: 453,
+			RID: "ab4219087a764ae0btest", Name: "Mascaras", Sale: 30, Size: "0",
+			TotalPrice: 317, NMID: 2389212, Brand: "Vivienne Sabo", Status: 202,
+		}},
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		ShardKey:        "9",
+		SMID:            99,
+		DateCreated:     "2021-11-26T06:22:19Z",
+		OOFShard:        "1",
+	}
+}
+
+// Go side of loading orders only, with db see BenchmarkInitialData in
+// integrational tests
+
+// decoding of json_build_object rows as it was made before typed rows
+func BenchmarkDecodeJSON(b *testing.B) {
+	data := make([]string, benchOrders)
+	for i := range data {
+		ord := benchOrder(i)
+		tmp, err := json.Marshal(&ord)
+		if err != nil {
+			b.Fatal(err)
+		}
+		data[i] = string(tmp)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		result := make([]*order.Order, len(data))
+		wg := &sync.WaitGroup{}
+		wg.Add(len(data))
+		for i, v := range data {
+			go func(i int, str string) {
+				defer wg.Done()
+				newOrd := new(order.Order)
+				if err := json.Unmarshal([]byte(str), newOrd); err == nil {
+					result[i] = newOrd
+				}
+			}(i, v)
+		}
+		wg.Wait()
+	}
+}
+
+func BenchmarkAssembleRows(b *testing.B) {
+	rows := make([]orderRow, benchOrders)
+	items := make([]itemRow, 0, benchOrders)
+	for i := range rows {
+		ord := benchOrder(i)
+		for _, it := range ord.Items {
+			items = append(items, itemRow{OrderID: int64(i), Item: it})
+		}
+		ord.Items = nil
+		rows[i] = orderRow{ID: int64(i), Order: ord}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		assembleOrders(rows, items)
+	}
+}
@@ -7,125 +7,53 @@ import (
 	_ "github.com/lib/pq"
 )
 
-var GetOrderJSONFromDataBase = fmt.Sprintf(`
-	select json_build_object (
-		'order_uid', o.order_uid, 
-		'track_number', o.track_number, 
-		'entry', o.entry, 
-		'locale', o.locale, 
-		'internal_signature', o.internal_signature, 
-		'customer_id', o.customer_id, 
-		'delivery_service', o.delivery_service, 
-		'shardkey', o.shardkey, 
-		'sm_id', o.sm_id, 
-		'date_created', o.date_created,
-		'oof_shard', o.oof_shard,
-		'delivery', json_build_object(
-			'id', di.id,
-			'name', di.name,
-			'phone', di.phone, 
-			'zip', di.zip,
-			'city', di.city,
-			'address', di.address,
-			'region', di.region, 
-			'email', di.email
-		),
-		'payment', json_build_object(
-			'id', p.id,
-			'transaction', p.transaction,
-			'request_id', p.request_id,
-			'currency', p.currency,
-			'provider', p.provider,
-			'amount', p.amount,
-			'payment_dt', p.payment_dt,
-			'bank', p.bank,
-			'delivery_cost', p.delivery_cost,
-			'goods_total', p.goods_total,
-			'custom_fee', p.custom_fee
-		),
-		'items', (
-			select json_agg(json_build_object(
-				'chrt_id', i.chrt_id,
-				'track_number', i.track_number,
-				'price', i.price,
-				'rid', i.rid,
-				'name', i.name,
-				'sale', i.sale,
-				'size', i.size,
-				'total_price', i.total_price,
-				'nm_id', i.nm_id,
-				'brand', i.brand,
-				'status', i.status
-			))
-			from orders_items as oi join items as i on oi.item_id=i.chrt_id
-			where oi.order_id=o.id
-		)
-	)
+// columns of orders joined with delivery_info and payment_info, nested
+// fields are named like "delivery.name" to be scanned into orderRow
+var selectOrdersSQL = fmt.Sprintf(`
+	select o.id, o.order_uid, o.track_number, o.entry, o.locale,
+		o.internal_signature, o.customer_id, o.delivery_service, o.shardkey,
+		o.sm_id, o.date_created, o.oof_shard,
+		di.name as "delivery.name", di.phone as "delivery.phone",
+		di.zip as "delivery.zip", di.city as "delivery.city",
+		di.address as "delivery.address", di.region as "delivery.region",
+		di.email as "delivery.email",
+		p.transaction as "payment.transaction",
+		p.request_id as "payment.request_id", p.currency as "payment.currency",
+		p.provider as "payment.provider", p.amount as "payment.amount",
+		p.payment_dt as "payment.payment_dt", p.bank as "payment.bank",
+		p.delivery_cost as "payment.delivery_cost",
+		p.goods_total as "payment.goods_total",
+		p.custom_fee as "payment.custom_fee"
 	from %s as o join %s as di on o.delivery_id=di.id 
-	join %s as p on o.payment_id=p.id
-	where order_uid=$1;
-`, OrdersTable, DeliveryInfoTable, PaymentInfoTable)
+	join %s as p on o.payment_id=p.id`,
+	OrdersTable, DeliveryInfoTable, PaymentInfoTable,
+)
 
-const InitialRequestLength = 100
+// cond is where, order and limit part of query
+func GetSelectOrdersSQLString(cond string) string {
+	return selectOrdersSQL + "\n\t" + cond + ";"
+}
 
-func GetLastOrdersJSONFromDataBase(size int) string {
+// $1 - array of orders ids
+func GetSelectOrdersItemsSQLString() string {
 	return fmt.Sprintf(`
-select json_build_object (
-		'order_uid', o.order_uid, 
-		'track_number', o.track_number, 
-		'entry', o.entry, 
-		'locale', o.locale, 
-		'internal_signature', o.internal_signature, 
-		'customer_id', o.customer_id, 
-		'delivery_service', o.delivery_service, 
-		'shardkey', o.shardkey, 
-		'sm_id', o.sm_id, 
-		'date_created', o.date_created,
-		'oof_shard', o.oof_shard,
-		'delivery', json_build_object(
-			'id', di.id,
-			'name', di.name,
-			'phone', di.phone, 
-			'zip', di.zip,
-			'city', di.city,
-			'address', di.address,
-			'region', di.region, 
-			'email', di.email
-		),
-		'payment', json_build_object(
-			'id', p.id,
-			'transaction', p.transaction,
-			'request_id', p.request_id,
-			'currency', p.currency,
-			'provider', p.provider,
-			'amount', p.amount,
-			'payment_dt', p.payment_dt,
-			'bank', p.bank,
-			'delivery_cost', p.delivery_cost,
-			'goods_total', p.goods_total,
-			'custom_fee', p.custom_fee
-		),
-		'items', (
-			select json_agg(json_build_object(
-				'chrt_id', i.chrt_id,
-				'track_number', i.track_number,
-				'price', i.price,
-				'rid', i.rid,
-				'name', i.name,
-				'sale', i.sale,
-				'size', i.size,
-				'total_price', i.total_price,
-				'nm_id', i.nm_id,
-				'brand', i.brand,
-				'status', i.status
-			))
-			from orders_items as oi join items as i on oi.item_id=i.chrt_id
-			where oi.order_id=o.id
-		)
-	)
-	from %s as o join %s as di on o.delivery_id=di.id 
-	join %s as p on o.payment_id=p.id order by o.id desc limit %d;
-`, OrdersTable, DeliveryInfoTable, PaymentInfoTable, size)
+	select oi.order_id, i.chrt_id, i.track_number, i.price, i.rid, i.name,
+		i.sale, i.size, i.total_price, i.nm_id, i.brand, i.status
+	from %s as oi join %s as i on oi.item_id=i.chrt_id
+	where oi.order_id = any($1)
+	order by oi.id;`, OrdersItemsTable, ItemsTable)
+}
+
+const InitialRequestLength = 100
+
+// order_uid is unique only inside one partition, so adds of the same uid
+// are serialized by lock till the end of transaction and checked by hand.
+// $1 - order_uid
+const lockOrderUIDSQL = `select pg_advisory_xact_lock(hashtext('orders'), hashtext($1));`
+
+// $1 - order_uid
+func GetOrderExistsSQLString() string {
+	return fmt.Sprintf(`select exists(select 1 from %s where order_uid=$1);`, OrdersTable)
 }
 
 func GetInsertPaymentSQLString() string {
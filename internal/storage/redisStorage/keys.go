@@ -0,0 +1,151 @@
+package redisStorage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"first-task/internal/config"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const scanCount = 1000
+
+type keyBuilder struct {
+	prefix  string
+	version int
+	hash    bool
+}
+
+func newKeyBuilder(cfg config.RedisConfig) keyBuilder {
+	prefix := cfg.KeyPrefix
+	if prefix == "" {
+		prefix = DefaultKeyPrefix
+	}
+
+	return keyBuilder{
+		prefix:  prefix,
+		version: cfg.KeyVersion,
+		hash:    cfg.HashKeys,
+	}
+}
+
+func (kb keyBuilder) key(orderUID string) string {
+	if kb.hash {
+		sum := sha256.Sum256([]byte(orderUID))
+		orderUID = hex.EncodeToString(sum[:])
+	}
+	return kb.versionPrefix() + orderUID
+}
+
+func (kb keyBuilder) versionPrefix() string {
+	return fmt.Sprintf("%s:v%d:", kb.prefix, kb.version)
+}
+
+// matches keys of every version under our prefix, but also foreign keys
+// like prefix:v1x, they are filtered by owns
+func (kb keyBuilder) pattern() string {
+	return escapePattern(kb.prefix) + ":v[0-9]*"
+}
+
+// owns is true for keys like prefix:v<number>:<order_uid>
+func (kb keyBuilder) owns(key string) bool {
+	rest, ok := strings.CutPrefix(key, kb.prefix+":v")
+	if !ok {
+		return false
+	}
+	version, _, ok := strings.Cut(rest, ":")
+	if !ok || version == "" {
+		return false
+	}
+	for _, c := range version {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func escapePattern(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+	return r.Replace(s)
+}
+
+// Purge scans only keys under configured prefix and deletes them.
+// With staleOnly keys of current version are kept, with dryRun nothing is
+// deleted, only counted.
+func (rs *RedisStorage) Purge(ctx context.Context, staleOnly, dryRun bool) (int, error) {
+	const op = "internal.storage.redisStorage.Purge"
+
+	keep := func(key string) bool {
+		if !rs.keys.owns(key) {
+			return true
+		}
+		return staleOnly && strings.HasPrefix(key, rs.keys.versionPrefix())
+	}
+
+	var total atomic.Int64
+	purgeOne := func(ctx context.Context, node redis.Cmdable) error {
+		n, err := purgeNode(ctx, node, rs.keys.pattern(), keep, dryRun)
+		total.Add(int64(n))
+		return err
+	}
+
+	var err error
+	if cc, ok := rs.rdb.(*redis.ClusterClient); ok {
+		// every master holds own part of keyspace, ForEachMaster scans them
+		// in parallel
+		err = cc.ForEachMaster(ctx, func(ctx context.Context, c *redis.Client) error {
+			return purgeOne(ctx, c)
+		})
+	} else {
+		err = purgeOne(ctx, rs.rdb)
+	}
+	if err != nil {
+		return int(total.Load()), fmt.Errorf("%s: %w", op, err)
+	}
+
+	return int(total.Load()), nil
+}
+
+func purgeNode(
+	ctx context.Context, node redis.Cmdable, pattern string,
+	keep func(string) bool, dryRun bool,
+) (int, error) {
+	var deleted int
+	var cursor uint64
+
+	for {
+		keys, next, err := node.Scan(ctx, cursor, pattern, scanCount).Result()
+		if err != nil {
+			return deleted, err
+		}
+
+		toDelete := make([]string, 0, len(keys))
+		for _, k := range keys {
+			if !keep(k) {
+				toDelete = append(toDelete, k)
+			}
+		}
+
+		if len(toDelete) > 0 && !dryRun {
+			// one key per command, multi-key UNLINK fails with CROSSSLOT in cluster
+			pipe := node.Pipeline()
+			for _, k := range toDelete {
+				pipe.Unlink(ctx, k)
+			}
+			if _, err := pipe.Exec(ctx); err != nil {
+				return deleted, err
+			}
+		}
+		deleted += len(toDelete)
+
+		cursor = next
+		if cursor == 0 {
+			return deleted, nil
+		}
+	}
+}
@@ -2,27 +2,98 @@ package redisStorage
 
 import (
 	"context"
+	"errors"
 	order "first-task/internal/entities/Order"
+	"fmt"
+	"math/rand/v2"
 	"time"
 
+	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 )
 
-func (rs *RedisStorage) LoadInitialCache(ords []*order.Order) {
-	for _, v := range ords {
-		rs.Add(v)
+// not to make huge error when whole redis is down
+const maxReportedErrors = 10
+
+// LoadInitialCache writes orders by pipelined chunks, failed chunks don't stop
+// loading, all failures are returned in one error.
+func (rs *RedisStorage) LoadInitialCache(ctx context.Context, ords []*order.Order) error {
+	const op = "internal.storage.redisStorage.LoadInitialCache"
+
+	var failed int
+	errs := make([]error, 0, maxReportedErrors)
+	report := func(err error) {
+		failed++
+		if len(errs) < maxReportedErrors {
+			errs = append(errs, err)
+		}
 	}
+
+	for start := 0; start < len(ords); start += rs.chunkSize {
+		end := min(start+rs.chunkSize, len(ords))
+
+		pipe := rs.rdb.Pipeline()
+		cmds := make([]*redis.StatusCmd, end-start)
+		for i, ord := range ords[start:end] {
+			if ord == nil {
+				continue
+			}
+			sealed, err := rs.cipher.Seal(ord)
+			if err != nil {
+				report(fmt.Errorf("%s: %w", ord.OrderUID, err))
+				continue
+			}
+			cmds[i] = pipe.Set(
+				ctx, rs.keys.key(ord.OrderUID), sealed, rs.expiration(),
+			)
+		}
+
+		// error of Exec is error of first failed command, check every one
+		_, _ = pipe.Exec(ctx)
+		for i, cmd := range cmds {
+			if cmd == nil {
+				continue
+			}
+			if err := cmd.Err(); err != nil {
+				report(fmt.Errorf("%s: %w", ords[start+i].OrderUID, err))
+			}
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf(
+			"%s: %d of %d orders not cached: %w",
+			op, failed, len(ords), errors.Join(errs...),
+		)
+	}
+
+	return nil
 }
 
-func (rs *RedisStorage) Add(ord *order.Order) {
-	res := rs.rdb.Set(context.Background(), ord.OrderUID, ord, time.Hour*1)
+func (rs *RedisStorage) expiration() time.Duration {
+	if rs.ttlJitter <= 0 {
+		return rs.ttl
+	}
+	return rs.ttl + rand.N(rs.ttlJitter)
+}
+
+func (rs *RedisStorage) Add(ctx context.Context, ord *order.Order) {
+	sealed, err := rs.cipher.Seal(ord)
+	if err != nil {
+		zap.L().Error("on encrypting order for redis storage: " + err.Error())
+		return
+	}
+
+	res := rs.rdb.Set(
+		ctx, rs.keys.key(ord.OrderUID), sealed, rs.expiration(),
+	)
 	if res.Err() != nil {
 		zap.L().Error("on adding value to redis storage")
 	}
 }
 
-func (rs *RedisStorage) Find(orderUID string) *order.Order {
-	res := rs.rdb.Get(context.Background(), orderUID)
+func (rs *RedisStorage) Find(ctx context.Context, orderUID string) *order.Order {
+	res := rs.rdb.Get(ctx, rs.keys.key(orderUID))
 	if res.Err() != nil {
 		return nil
 	}
@@ -33,12 +104,16 @@ func (rs *RedisStorage) Find(orderUID string) *order.Order {
 		zap.L().Error("on scanning value from redis storage")
 		return nil
 	}
+	if err := rs.cipher.Open(&resultData); err != nil {
+		zap.L().Error("on decrypting value from redis storage: " + err.Error())
+		return nil
+	}
 
 	return &resultData
 }
 
-func (rs *RedisStorage) Delete(orderUID string) {
-	r := rs.rdb.Del(context.Background(), orderUID)
+func (rs *RedisStorage) Delete(ctx context.Context, orderUID string) {
+	r := rs.rdb.Del(ctx, rs.keys.key(orderUID))
 	if r.Err() != nil {
 		zap.L().Error("on deleting value from redis storage")
 	}
@@ -1,8 +1,15 @@
 package redisStorage
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"first-task/internal/config"
+	"first-task/internal/storage/fieldcrypt"
 	"fmt"
+	"os"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
@@ -15,18 +22,151 @@ const (
 	DB       = 0
 )
 
+const (
+	ModeStandalone = "standalone"
+	ModeSentinel   = "sentinel"
+	ModeCluster    = "cluster"
+)
+
+var ErrUnknownMode = errors.New("unknown redis mode")
+
+const (
+	DefaultKeyPrefix     = "orders"
+	DefaultTTL           = time.Hour
+	DefaultLoadChunkSize = 500
+)
+
 type RedisStorage struct {
-	rdb *redis.Client
+	rdb  redis.UniversalClient
+	keys keyBuilder
+
+	ttl       time.Duration
+	ttlJitter time.Duration
+	chunkSize int
+
+	cipher *fieldcrypt.Cipher
 }
 
+// if config is wrong throw panic
 func NewRedisStorage(cfg config.RedisConfig) *RedisStorage {
-	return &RedisStorage{
-		redis.NewClient(&redis.Options{
-			Addr:     fmt.Sprintf("%s:%s", cfg.Host, cfg.Port),
-			Password: cfg.Password,
-			DB:       cfg.DBName,
-		}),
+	rdb, err := newClient(cfg)
+	if err != nil {
+		panic(err)
 	}
+	rdb.AddHook(tracingHook{})
+
+	rs := &RedisStorage{
+		rdb:       rdb,
+		keys:      newKeyBuilder(cfg),
+		ttl:       cfg.TTL,
+		ttlJitter: cfg.TTLJitter,
+		chunkSize: cfg.LoadChunkSize,
+	}
+	if rs.ttl <= 0 {
+		rs.ttl = DefaultTTL
+	}
+	if rs.chunkSize <= 0 {
+		rs.chunkSize = DefaultLoadChunkSize
+	}
+
+	return rs
+}
+
+func newClient(cfg config.RedisConfig) (redis.UniversalClient, error) {
+	const op = "internal.storage.redisStorage.newClient"
+
+	tlsCfg, err := newTLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	switch cfg.Mode {
+	case "", ModeStandalone:
+		return redis.NewClient(&redis.Options{
+			Addr:      fmt.Sprintf("%s:%s", cfg.Host, cfg.Port),
+			Username:  cfg.Username,
+			Password:  cfg.Password,
+			DB:        cfg.DBName,
+			TLSConfig: tlsCfg,
+		}), nil
+	case ModeSentinel:
+		if cfg.MasterName == "" || len(cfg.Addrs) == 0 {
+			return nil, fmt.Errorf(
+				"%s: sentinel mode needs master_name and addrs", op,
+			)
+		}
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:       cfg.MasterName,
+			SentinelAddrs:    cfg.Addrs,
+			SentinelUsername: cfg.SentinelUsername,
+			SentinelPassword: cfg.SentinelPassword,
+			Username:         cfg.Username,
+			Password:         cfg.Password,
+			DB:               cfg.DBName,
+			TLSConfig:        tlsCfg,
+		}), nil
+	case ModeCluster:
+		if len(cfg.Addrs) == 0 {
+			return nil, fmt.Errorf("%s: cluster mode needs addrs", op)
+		}
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:     cfg.Addrs,
+			Username:  cfg.Username,
+			Password:  cfg.Password,
+			TLSConfig: tlsCfg,
+		}), nil
+	}
+
+	return nil, fmt.Errorf("%s: %w: %s", op, ErrUnknownMode, cfg.Mode)
+}
+
+func newTLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	tlsCfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CAFile != "" {
+		ca, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("can't parse ca file %s", cfg.CAFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsCfg, nil
+}
+
+// SetCipher makes cached orders keep delivery fields encrypted,
+// must be called before first use
+func (rs *RedisStorage) SetCipher(c *fieldcrypt.Cipher) {
+	rs.cipher = c
+}
+
+// Client returns client of storage for other users of the same redis
+func (rs *RedisStorage) Client() redis.UniversalClient {
+	return rs.rdb
+}
+
+func (rs *RedisStorage) Ping(ctx context.Context) error {
+	return rs.rdb.Ping(ctx).Err()
 }
 
 func (rs *RedisStorage) Shutdown() {
@@ -0,0 +1,149 @@
+package redisStorage
+
+import (
+	"errors"
+	"first-task/internal/config"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestNewClientModes(t *testing.T) {
+	tests := []struct {
+		Name string
+		Cfg  config.RedisConfig
+		Err  bool
+		Want func(redis.UniversalClient) bool
+	}{
+		{
+			Name: "default is standalone",
+			Cfg:  config.RedisConfig{Host: Host, Port: Port},
+			Want: func(c redis.UniversalClient) bool {
+				_, ok := c.(*redis.Client)
+				return ok
+			},
+		},
+		{
+			Name: "sentinel",
+			Cfg: config.RedisConfig{
+				Mode:       ModeSentinel,
+				Addrs:      []string{"localhost:26379"},
+				MasterName: "mymaster",
+			},
+			Want: func(c redis.UniversalClient) bool {
+				_, ok := c.(*redis.Client)
+				return ok
+			},
+		},
+		{
+			Name: "cluster",
+			Cfg: config.RedisConfig{
+				Mode:  ModeCluster,
+				Addrs: []string{"localhost:7000", "localhost:7001"},
+			},
+			Want: func(c redis.UniversalClient) bool {
+				_, ok := c.(*redis.ClusterClient)
+				return ok
+			},
+		},
+		{
+			Name: "sentinel without master name",
+			Cfg: config.RedisConfig{
+				Mode:  ModeSentinel,
+				Addrs: []string{"localhost:26379"},
+			},
+			Err: true,
+		},
+		{
+			Name: "cluster without addrs",
+			Cfg:  config.RedisConfig{Mode: ModeCluster},
+			Err:  true,
+		},
+		{
+			Name: "tls with missing ca file",
+			Cfg: config.RedisConfig{
+				Host: Host,
+				Port: Port,
+				TLS:  config.TLSConfig{Enabled: true, CAFile: "./not-exists.pem"},
+			},
+			Err: true,
+		},
+	}
+
+	for _, v := range tests {
+		c, err := newClient(v.Cfg)
+		if v.Err {
+			if err == nil {
+				t.Errorf("%s: waited error, get nil", v.Name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", v.Name, err.Error())
+			continue
+		}
+		if !v.Want(c) {
+			t.Errorf("%s: wrong client type %T", v.Name, c)
+		}
+		c.Close()
+	}
+
+	_, err := newClient(config.RedisConfig{Mode: "unknown"})
+	if !errors.Is(err, ErrUnknownMode) {
+		t.Errorf("wrong error for unknown mode: %v", err)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	kb := newKeyBuilder(config.RedisConfig{KeyPrefix: "wb", KeyVersion: 2})
+	if k := kb.key("test"); k != "wb:v2:test" {
+		t.Errorf("wrong key\nwait: %s\nget: %s", "wb:v2:test", k)
+	}
+	if p := kb.pattern(); p != "wb:v[0-9]*" {
+		t.Errorf("wrong pattern\nwait: %s\nget: %s", "wb:v[0-9]*", p)
+	}
+	owns := map[string]bool{
+		"wb:v1:test":   true,
+		"wb:v12:test":  true,
+		"wb:vendor:1":  false,
+		"wb:visits":    false,
+		"wb:v1x:test":  false,
+		"wb:v:test":    false,
+		"wb:v2":        false,
+		"other:v1:abc": false,
+	}
+	for key, wait := range owns {
+		if kb.owns(key) != wait {
+			t.Errorf("%s: wrong owns, wait %v", key, wait)
+		}
+	}
+
+	kb = newKeyBuilder(config.RedisConfig{KeyVersion: 1, HashKeys: true})
+	k := kb.key("test")
+	// sha256("test")
+	wait := "orders:v1:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	if k != wait {
+		t.Errorf("wrong hashed key\nwait: %s\nget: %s", wait, k)
+	}
+
+	kb = newKeyBuilder(config.RedisConfig{KeyPrefix: "a*b"})
+	if p := kb.pattern(); p != `a\*b:v[0-9]*` {
+		t.Errorf("prefix isn't escaped in pattern: %s", p)
+	}
+}
+
+func TestExpiration(t *testing.T) {
+	rs := &RedisStorage{ttl: time.Hour}
+	if e := rs.expiration(); e != time.Hour {
+		t.Errorf("ttl without jitter changed: %s", e)
+	}
+
+	rs.ttlJitter = time.Minute
+	for i := 0; i < 100; i++ {
+		e := rs.expiration()
+		if e < time.Hour || e >= time.Hour+time.Minute {
+			t.Fatalf("ttl out of jitter range: %s", e)
+		}
+	}
+}
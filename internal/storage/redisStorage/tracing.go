@@ -0,0 +1,53 @@
+package redisStorage
+
+import (
+	"context"
+	"errors"
+	"first-task/internal/tracing"
+
+	"github.com/redis/go-redis/v9"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracingHook makes span for every command or pipeline, keys and values
+// aren't recorded. Missing key isn't error.
+type tracingHook struct{}
+
+func (tracingHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (tracingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		ctx, span := startSpan(ctx, cmd.FullName())
+		err := next(ctx, cmd)
+		tracing.End(span, ignoreNil(err))
+		return err
+	}
+}
+
+func (tracingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		ctx, span := startSpan(ctx, "pipeline")
+		span.SetAttributes(semconv.DBOperationBatchSize(len(cmds)))
+		err := next(ctx, cmds)
+		tracing.End(span, ignoreNil(err))
+		return err
+	}
+}
+
+func startSpan(ctx context.Context, op string) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(
+		ctx, "redis."+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemNameRedis, semconv.DBOperationName(op)),
+	)
+}
+
+func ignoreNil(err error) error {
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,21 @@
+package sqlite
+
+import (
+	"fmt"
+	"net/url"
+
+	_ "modernc.org/sqlite"
+)
+
+// pure go driver, the app is built without cgo
+const driverName = "sqlite"
+
+// foreign keys are off in sqlite by default, cascade deletes need them
+func dsn(path string, busyTimeoutMS int64) string {
+	q := url.Values{}
+	q.Add("_pragma", "foreign_keys(1)")
+	q.Add("_pragma", "journal_mode(WAL)")
+	q.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", busyTimeoutMS))
+
+	return "file:" + path + "?" + q.Encode()
+}
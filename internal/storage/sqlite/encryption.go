@@ -0,0 +1,77 @@
+package sqlite
+
+import (
+	"context"
+	delivery "first-task/internal/entities/Delivery"
+	"fmt"
+)
+
+type deliveryRow struct {
+	ID int64 `db:"id"`
+	delivery.Delivery
+}
+
+// ResealDeliveries re-encrypts delivery rows by batches after rotation of
+// keys or change of encrypted fields, returns count of changed rows
+func (s *SQLite) ResealDeliveries(ctx context.Context, batchSize int) (int, error) {
+	const op = "internal.storage.sqlite.ResealDeliveries"
+
+	var changed int
+	var lastID int64
+	for {
+		n, last, err := s.resealBatch(ctx, lastID, batchSize)
+		changed += n
+		if err != nil {
+			return changed, fmt.Errorf("%s: %w", op, err)
+		}
+		if last == lastID {
+			return changed, nil
+		}
+		lastID = last
+	}
+}
+
+// in wal mode batch fails if rows are changed by other connection after
+// reading, so erasure can't be overwritten by old values
+func (s *SQLite) resealBatch(ctx context.Context, afterID int64, limit int) (int, int64, error) {
+	tx, err := s.conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, afterID, err
+	}
+	defer tx.Rollback()
+
+	rows := make([]deliveryRow, 0, limit)
+	err = tx.SelectContext(ctx, &rows, fmt.Sprintf(`
+		select id, name, phone, zip, city, address, region, email from %s
+		where id > ? order by id limit ?;`, DeliveryInfoTable,
+	), afterID, limit)
+	if err != nil || len(rows) == 0 {
+		return 0, afterID, err
+	}
+
+	var changed int
+	for i := range rows {
+		ok, err := s.cipher.Reseal(&rows[i].Delivery)
+		if err != nil {
+			return 0, afterID, err
+		}
+		if !ok {
+			continue
+		}
+
+		_, err = tx.ExecContext(ctx, fmt.Sprintf(`
+			update %s set name = ?, phone = ?, zip = ?, city = ?,
+			address = ?, region = ?, email = ? where id = ?;`, DeliveryInfoTable,
+		), append(rows[i].GetDataForSQLString(), rows[i].ID)...)
+		if err != nil {
+			return 0, afterID, err
+		}
+		changed++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, afterID, err
+	}
+
+	return changed, rows[len(rows)-1].ID, nil
+}
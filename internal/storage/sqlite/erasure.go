@@ -0,0 +1,169 @@
+package sqlite
+
+import (
+	"context"
+	"encoding/json"
+	"first-task/internal/storage"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type orderRef struct {
+	ID         int64  `db:"id"`
+	OrderUID   string `db:"order_uid"`
+	DeliveryID int64  `db:"delivery_id"`
+	PaymentID  int64  `db:"payment_id"`
+}
+
+func (s *SQLite) Delete(ctx context.Context, orderUID string) error {
+	const op = "internal.storage.sqlite.Delete"
+
+	tx, err := s.conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	refs, err := selectRefs(ctx, tx, "order_uid", orderUID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if len(refs) == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	if err := deleteOrders(ctx, tx, refs); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *SQLite) EraseCustomer(ctx context.Context, er *storage.Erasure) error {
+	const op = "internal.storage.sqlite.EraseCustomer"
+
+	tx, err := s.conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	refs, err := selectRefs(ctx, tx, "customer_id", er.CustomerID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	switch er.Mode {
+	case storage.ErasureDelete:
+		err = deleteOrders(ctx, tx, refs)
+	case storage.ErasureAnonymize:
+		err = anonymizeOrders(ctx, tx, refs)
+	default:
+		err = fmt.Errorf("%w: %s", storage.ErrUnknownErasureMode, er.Mode)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	er.OrderUIDs = make([]string, 0, len(refs))
+	for _, r := range refs {
+		er.OrderUIDs = append(er.OrderUIDs, r.OrderUID)
+	}
+	uids, err := json.Marshal(er.OrderUIDs)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	er.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	er.ID, err = insert(ctx, tx, fmt.Sprintf(`
+	insert into %s (customer_id, mode, actor, reason, order_uids, archive_files_kept, created_at)
+	values (?, ?, ?, ?, ?, ?, ?);`, ErasuresTable),
+		er.CustomerID, er.Mode, er.Actor, er.Reason, string(uids), er.ArchiveFilesKept,
+		er.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// column is order_uid or customer_id, sqlite locks whole db in write
+// transaction, so there is no "for update"
+func selectRefs(ctx context.Context, tx *sqlx.Tx, column, value string) ([]orderRef, error) {
+	refs := make([]orderRef, 0)
+	err := tx.SelectContext(ctx, &refs, fmt.Sprintf(`
+	select id, order_uid, delivery_id, payment_id from %s
+	where %s = ? order by id;`, OrdersTable, column,
+	), value)
+
+	return refs, err
+}
+
+func deleteOrders(ctx context.Context, tx *sqlx.Tx, refs []orderRef) error {
+	if len(refs) == 0 {
+		return nil
+	}
+
+	ids, deliveryIDs, paymentIDs := splitRefs(refs)
+	queries := []struct {
+		query string
+		ids   []int64
+	}{
+		{fmt.Sprintf(`delete from %s where order_id in (?);`, OrdersItemsTable), ids},
+		{fmt.Sprintf(`delete from %s where id in (?);`, OrdersTable), ids},
+		{fmt.Sprintf(`delete from %s where id in (?);`, DeliveryInfoTable), deliveryIDs},
+		{fmt.Sprintf(`delete from %s where id in (?);`, PaymentInfoTable), paymentIDs},
+	}
+	for _, q := range queries {
+		query, args, err := sqlx.In(q.query, q.ids)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func anonymizeOrders(ctx context.Context, tx *sqlx.Tx, refs []orderRef) error {
+	if len(refs) == 0 {
+		return nil
+	}
+
+	_, deliveryIDs, _ := splitRefs(refs)
+	query, args, err := sqlx.In(fmt.Sprintf(`
+	update %s set name = ?, phone = ?, address = ?, email = ?
+	where id in (?);`, DeliveryInfoTable),
+		storage.Anonymized, storage.Anonymized, storage.Anonymized,
+		storage.Anonymized, deliveryIDs,
+	)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, query, args...)
+
+	return err
+}
+
+func splitRefs(refs []orderRef) (ids, deliveryIDs, paymentIDs []int64) {
+	ids = make([]int64, len(refs))
+	deliveryIDs = make([]int64, len(refs))
+	paymentIDs = make([]int64, len(refs))
+	for i, r := range refs {
+		ids[i], deliveryIDs[i], paymentIDs[i] = r.ID, r.DeliveryID, r.PaymentID
+	}
+	return ids, deliveryIDs, paymentIDs
+}
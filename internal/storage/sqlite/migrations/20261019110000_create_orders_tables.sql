@@ -0,0 +1,78 @@
+-- +goose Up
+-- the same tables as in postgres migrations without partitioning of orders,
+-- so order_uid stays unique
+CREATE TABLE delivery_info(
+    id integer primary key autoincrement,
+    name varchar(255) not null,
+    phone varchar(100) not null,
+    zip varchar(100) not null,
+    city varchar(255) not null,
+    address varchar(255) not null,
+    region varchar(255) not null,
+    email varchar(255) not null
+);
+
+CREATE TABLE payment_info(
+    id integer primary key autoincrement,
+    "transaction" varchar(255) not null,
+    request_id text not null unique,
+    currency varchar(20) not null,
+    provider varchar(255) not null,
+    amount decimal(12, 2) not null,
+    payment_dt bigint not null,
+    bank varchar(255) not null,
+    delivery_cost decimal(12, 2) not null,
+    goods_total decimal(12, 2) not null,
+    custom_fee decimal(12, 2) not null
+);
+
+CREATE TABLE items(
+    chrt_id integer primary key,
+    track_number text not null,
+    price decimal(12, 2) not null,
+    rid text not null,
+    name varchar(255) not null,
+    sale smallint not null,
+    size varchar(10) not null,
+    total_price decimal(12, 2) not null,
+    nm_id bigint not null,
+    brand varchar(255) not null,
+    status smallint not null
+);
+
+CREATE TABLE orders (
+    id integer primary key autoincrement,
+    order_uid text not null unique,
+    track_number text not null,
+    entry varchar(255) not null,
+    delivery_id bigint not null,
+    payment_id bigint not null,
+    locale varchar(10) not null,
+    internal_signature text not null,
+    customer_id text not null,
+    delivery_service varchar(255) not null,
+    shardkey text not null,
+    sm_id smallint not null,
+    date_created varchar(255) not null,
+    oof_shard text not null,
+    created_at timestamp not null default current_timestamp,
+    FOREIGN KEY (delivery_id) REFERENCES delivery_info(id) ON DELETE CASCADE,
+    FOREIGN KEY (payment_id) REFERENCES payment_info(id) ON DELETE CASCADE
+);
+
+CREATE TABLE orders_items(
+    id integer primary key autoincrement,
+    order_id bigint not null,
+    item_id bigint not null,
+    FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE,
+    FOREIGN KEY (item_id) REFERENCES items(chrt_id) ON DELETE CASCADE
+);
+
+CREATE INDEX orders_items_order_id_idx ON orders_items (order_id);
+
+-- +goose Down
+DROP TABLE orders_items;
+DROP TABLE orders;
+DROP TABLE items;
+DROP TABLE payment_info;
+DROP TABLE delivery_info;
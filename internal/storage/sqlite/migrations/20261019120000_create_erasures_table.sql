@@ -0,0 +1,17 @@
+-- +goose Up
+-- audit of erasures of customer data, order_uids is json array
+CREATE TABLE erasures (
+    id integer primary key autoincrement,
+    customer_id text not null,
+    mode varchar(20) not null,
+    actor text not null,
+    reason text not null,
+    order_uids text not null,
+    created_at timestamp not null default current_timestamp
+);
+
+CREATE INDEX orders_customer_id_idx ON orders (customer_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS orders_customer_id_idx;
+DROP TABLE erasures;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- erasure doesn't change exported archive files, audit tells if they exist
+ALTER TABLE erasures ADD COLUMN archive_files_kept boolean not null default false;
+
+-- +goose Down
+ALTER TABLE erasures DROP COLUMN archive_files_kept;
@@ -0,0 +1,212 @@
+package sqlite
+
+import (
+	"context"
+	item "first-task/internal/entities/Item"
+	order "first-task/internal/entities/Order"
+	"first-task/internal/storage"
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	insertDeliverySQL = fmt.Sprintf(`
+	insert into %s (name, phone, zip, city, address, region, email)
+	values (?, ?, ?, ?, ?, ?, ?);`, DeliveryInfoTable)
+
+	// transaction is keyword in sqlite
+	insertPaymentSQL = fmt.Sprintf(`
+	insert into %s (
+	"transaction", request_id, currency, provider, amount, payment_dt, bank,
+	delivery_cost, goods_total, custom_fee
+	) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`, PaymentInfoTable)
+
+	orderExistsSQL = fmt.Sprintf(`select exists(select 1 from %s where order_uid=?);`, OrdersTable)
+
+	insertOrderSQL = fmt.Sprintf(`
+	insert into %s (order_uid, track_number, entry, delivery_id, payment_id,
+	locale, internal_signature, customer_id, delivery_service, shardkey,
+	sm_id, date_created, oof_shard)
+	values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`, OrdersTable)
+
+	// nested fields are named like "delivery.name" to be scanned into orderRow
+	selectOrdersSQL = fmt.Sprintf(`
+	select o.id, o.order_uid, o.track_number, o.entry, o.locale,
+		o.internal_signature, o.customer_id, o.delivery_service, o.shardkey,
+		o.sm_id, o.date_created, o.oof_shard,
+		di.name as "delivery.name", di.phone as "delivery.phone",
+		di.zip as "delivery.zip", di.city as "delivery.city",
+		di.address as "delivery.address", di.region as "delivery.region",
+		di.email as "delivery.email",
+		p."transaction" as "payment.transaction",
+		p.request_id as "payment.request_id", p.currency as "payment.currency",
+		p.provider as "payment.provider", p.amount as "payment.amount",
+		p.payment_dt as "payment.payment_dt", p.bank as "payment.bank",
+		p.delivery_cost as "payment.delivery_cost",
+		p.goods_total as "payment.goods_total",
+		p.custom_fee as "payment.custom_fee"
+	from %s as o join %s as di on o.delivery_id=di.id
+	join %s as p on o.payment_id=p.id`,
+		OrdersTable, DeliveryInfoTable, PaymentInfoTable,
+	)
+
+	selectOrdersItemsSQL = fmt.Sprintf(`
+	select oi.order_id, i.chrt_id, i.track_number, i.price, i.rid, i.name,
+		i.sale, i.size, i.total_price, i.nm_id, i.brand, i.status
+	from %s as oi join %s as i on oi.item_id=i.chrt_id
+	where oi.order_id in (?)
+	order by oi.id;`, OrdersItemsTable, ItemsTable)
+)
+
+type orderRow struct {
+	ID int64 `db:"id"`
+	order.Order
+}
+
+type itemRow struct {
+	OrderID int64 `db:"order_id"`
+	item.Item
+}
+
+func (s *SQLite) Add(ctx context.Context, ord *order.Order) error {
+	const op = "internal.storage.sqlite.AddOrder"
+
+	ord, err := s.cipher.Seal(ord)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	tx, err := s.conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	// checked before inserts, so duplicate leaves no delivery and payment
+	var exists bool
+	if err := tx.GetContext(ctx, &exists, orderExistsSQL, ord.OrderUID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if exists {
+		return fmt.Errorf("%s: %w", op, storage.ErrDuplicate)
+	}
+
+	deliveryID, err := insert(ctx, tx, insertDeliverySQL, ord.Delivery.GetDataForSQLString()...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	paymentID, err := insert(ctx, tx, insertPaymentSQL, ord.Payment.GetDataForSQLString()...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	orderID, err := insert(
+		ctx, tx, insertOrderSQL, ord.GetDataForSQLString(deliveryID, paymentID)...,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(ord.Items) > 0 {
+		_, err = tx.ExecContext(
+			ctx, insertOrdersItemsSQL(len(ord.Items)),
+			ord.GetDataForSQLStringOrdersItems(orderID)...,
+		)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func insert(ctx context.Context, tx *sqlx.Tx, query string, args ...any) (int64, error) {
+	res, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func insertOrdersItemsSQL(itmsLen int) string {
+	return fmt.Sprintf(
+		"insert into %s (order_id, item_id) values %s;", OrdersItemsTable,
+		strings.TrimSuffix(strings.Repeat("(?, ?), ", itmsLen), ", "),
+	)
+}
+
+func (s *SQLite) Find(ctx context.Context, orderUID string) (*order.Order, error) {
+	const op = "internal.storage.sqlite.FindOrder"
+
+	result, err := s.selectOrders(ctx, "where o.order_uid = ? limit 1", orderUID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(result) == 0 {
+		return nil, storage.ErrNotFound
+	}
+
+	return result[0], nil
+}
+
+func (s *SQLite) GetInitialData(ctx context.Context, size int) ([]*order.Order, error) {
+	const op = "internal.storage.sqlite.GetInitialData"
+
+	result, err := s.selectOrders(ctx, "order by o.id desc limit ?", size)
+	if err != nil {
+		return []*order.Order{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return result, nil
+}
+
+// selectOrders returns orders matching cond with their items in order of rows
+func (s *SQLite) selectOrders(ctx context.Context, cond string, args ...any) ([]*order.Order, error) {
+	rows := make([]orderRow, 0)
+	err := s.conn.SelectContext(ctx, &rows, selectOrdersSQL+"\n\t"+cond+";", args...)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*order.Order, len(rows))
+	if len(rows) == 0 {
+		return result, nil
+	}
+
+	byID := make(map[int64]*order.Order, len(rows))
+	ids := make([]int64, len(rows))
+	for i := range rows {
+		ord := rows[i].Order
+		result[i] = &ord
+		byID[rows[i].ID] = &ord
+		ids[i] = rows[i].ID
+	}
+
+	query, itemArgs, err := sqlx.In(selectOrdersItemsSQL, ids)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]itemRow, 0, len(rows))
+	if err := s.conn.SelectContext(ctx, &items, query, itemArgs...); err != nil {
+		return nil, err
+	}
+	for _, it := range items {
+		if ord, ok := byID[it.OrderID]; ok {
+			ord.Items = append(ord.Items, it.Item)
+		}
+	}
+	for _, ord := range result {
+		if err := s.cipher.Open(ord); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,92 @@
+package sqlite
+
+import (
+	"context"
+	"embed"
+	"first-task/internal/config"
+	"first-task/internal/storage/fieldcrypt"
+	"fmt"
+	"io/fs"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pressly/goose/v3"
+	"go.uber.org/zap"
+)
+
+const (
+	DeliveryInfoTable = "delivery_info"
+	PaymentInfoTable  = "payment_info"
+	ItemsTable        = "items"
+	OrdersTable       = "orders"
+	OrdersItemsTable  = "orders_items"
+	ErasuresTable     = "erasures"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// SQLite is DataBaser in one local file for development and tests without
+// postgres
+type SQLite struct {
+	conn   *sqlx.DB
+	cipher *fieldcrypt.Cipher
+}
+
+// if db can't be opened or migrated throw panic
+func NewSQLite(cfg config.SQLiteConfig) *SQLite {
+	s, err := Open(cfg)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// Open opens db file from config and applies embedded migrations
+func Open(cfg config.SQLiteConfig) (*SQLite, error) {
+	const op = "internal.storage.sqlite.Open"
+
+	db, err := sqlx.Open(driverName, dsn(cfg.Path, cfg.BusyTimeout.Milliseconds()))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	// sqlite has one writer, a single connection avoids "database is locked"
+	db.SetMaxOpenConns(1)
+
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &SQLite{conn: db}, nil
+}
+
+// SetCipher makes Add encrypt delivery fields and reads decrypt them,
+// must be called before first use
+func (s *SQLite) SetCipher(c *fieldcrypt.Cipher) {
+	s.cipher = c
+}
+
+func migrate(db *sqlx.DB) error {
+	fsys, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		return err
+	}
+
+	provider, err := goose.NewProvider(goose.DialectSQLite3, db.DB, fsys)
+	if err != nil {
+		return err
+	}
+
+	_, err = provider.Up(context.Background())
+	return err
+}
+
+func (s *SQLite) Ping(ctx context.Context) error {
+	return s.conn.PingContext(ctx)
+}
+
+func (s *SQLite) Shutdown() {
+	if err := s.conn.Close(); err != nil {
+		zap.L().Error(err.Error())
+	}
+}
@@ -0,0 +1,216 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"first-task/internal/config"
+	delivery "first-task/internal/entities/Delivery"
+	item "first-task/internal/entities/Item"
+	order "first-task/internal/entities/Order"
+	payment "first-task/internal/entities/Payment"
+	"first-task/internal/storage"
+	"first-task/internal/storage/fieldcrypt"
+	"first-task/internal/storage/storagetest"
+	"first-task/pkg/envelope"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const mockItemRow = `
+insert into items (
+	chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id,
+	brand, status
+)
+values(
+	9934930, 'WBILMTESTTRACK', 453, 'ab4219087a764ae0btest', 'Mascaras',
+	30, '0', 317, 2389212, 'Vivienne Sabo', 202
+);`
+
+func testOrder(orderUID string) *order.Order {
+	return &order.Order{
+		OrderUID:    orderUID,
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery: delivery.Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "test@gmail.com",
+		},
+		Payment: payment.Payment{
+			Transaction:  "b563feb7b2b84b6test",
+			RequestID:    orderUID,
+			Currency:     "USD",
+			Provider:     "wbpay",
+			Amount:       1817.5,
+			PaymentDT:    1637907727,
+			Bank:         "alpha",
+			DeliveryCost: 1500,
+			GoodsTotal:   317,
+		},
+		Items: []item.Item{
+			{
+				ChrtID:      9934930,
+				TrackNumber: "WBILMTESTTRACK",
+				Price:       453,
+				RID:         "ab4219087a764ae0btest",
+				Name:        "Mascaras",
+				Sale:        30,
+				Size:        "0",
+				TotalPrice:  317,
+				NMID:        2389212,
+				Brand:       "Vivienne Sabo",
+				Status:      202,
+			},
+		},
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		ShardKey:        "9",
+		SMID:            99,
+		DateCreated:     "2021-11-26T06:22:19Z",
+		OOFShard:        "1",
+	}
+}
+
+func newTestSQLite(t *testing.T) *SQLite {
+	s, err := Open(config.SQLiteConfig{
+		Path:        filepath.Join(t.TempDir(), "test.db"),
+		BusyTimeout: time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Shutdown)
+
+	if _, err := s.conn.Exec(mockItemRow); err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+func TestSQLite(t *testing.T) {
+	s := newTestSQLite(t)
+	ctx := context.Background()
+
+	first, second := testOrder("first"), testOrder("second")
+	for _, ord := range []*order.Order{first, second} {
+		if err := s.Add(ctx, ord); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fromDB, err := s.Find(ctx, first.OrderUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fromDB, first) {
+		t.Errorf("wrong order\nwait: %+v\nget: %+v", first, fromDB)
+	}
+
+	if _, err := s.Find(ctx, "unknown"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("waited ErrNotFound, get %v", err)
+	}
+
+	// the last added orders go first
+	initial, err := s.GetInitialData(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(initial) != 1 || !reflect.DeepEqual(initial[0], second) {
+		t.Errorf("wrong initial data: %+v", initial)
+	}
+
+	if err := s.Add(ctx, first); err == nil {
+		t.Error("order with the same order_uid is added twice")
+	}
+	// failed transaction leaves nothing
+	var deliveries int
+	if err := s.conn.Get(&deliveries, `select count(*) from delivery_info;`); err != nil {
+		t.Fatal(err)
+	}
+	if deliveries != 2 {
+		t.Errorf("waited 2 delivery rows, get %d", deliveries)
+	}
+}
+
+func TestMigrationsAreIdempotent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	for i := 0; i < 2; i++ {
+		s, err := Open(config.SQLiteConfig{Path: path, BusyTimeout: time.Second})
+		if err != nil {
+			t.Fatalf("open %d: %s", i, err.Error())
+		}
+		s.Shutdown()
+	}
+}
+
+func TestConformance(t *testing.T) {
+	storagetest.RunDataBaser(t, newTestSQLite(t))
+}
+
+func TestEncryption(t *testing.T) {
+	s := newTestSQLite(t)
+	ctx := context.Background()
+
+	kf := new(envelope.KeyFile)
+	if err := kf.Rotate("k1"); err != nil {
+		t.Fatal(err)
+	}
+	newCipher := func() *fieldcrypt.Cipher {
+		kr, err := envelope.NewKeyring(kf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fieldcrypt.New(kr, []string{"name", "phone", "email", "address"})
+	}
+	s.SetCipher(newCipher())
+
+	ord := testOrder("encrypted")
+	if err := s.Add(ctx, ord); err != nil {
+		t.Fatal(err)
+	}
+
+	var phone string
+	if err := s.conn.Get(&phone, `select phone from delivery_info;`); err != nil {
+		t.Fatal(err)
+	}
+	if envelope.KeyID(phone) != "k1" {
+		t.Errorf("phone is stored as %s", phone)
+	}
+
+	fromDB, err := s.Find(ctx, ord.OrderUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fromDB, ord) {
+		t.Errorf("wrong order\nwait: %+v\nget: %+v", ord, fromDB)
+	}
+
+	if err := kf.Rotate("k2"); err != nil {
+		t.Fatal(err)
+	}
+	s.SetCipher(newCipher())
+	if n, err := s.ResealDeliveries(ctx, 10); err != nil || n != 1 {
+		t.Errorf("wrong reseal: %d rows, %v", n, err)
+	}
+	if n, err := s.ResealDeliveries(ctx, 10); err != nil || n != 0 {
+		t.Errorf("resealed rows are changed again: %d rows, %v", n, err)
+	}
+
+	if err := s.conn.Get(&phone, `select phone from delivery_info;`); err != nil {
+		t.Fatal(err)
+	}
+	if envelope.KeyID(phone) != "k2" {
+		t.Errorf("phone isn't re-encrypted: %s", phone)
+	}
+	if fromDB, err := s.Find(ctx, ord.OrderUID); err != nil || !reflect.DeepEqual(fromDB, ord) {
+		t.Errorf("wrong order after reseal: %+v, %v", fromDB, err)
+	}
+}
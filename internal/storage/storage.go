@@ -1,38 +1,124 @@
 package storage
 
 import (
+	"context"
 	"errors"
+	"first-task/internal/config"
 	order "first-task/internal/entities/Order"
+	"sync/atomic"
+	"time"
 )
 
 type Storage struct {
 	localStorage    Cacher
 	dataBaseStorage DataBaser
+
+	timeouts config.TimeoutsConfig
+	// LoadInitialData is finished
+	warm atomic.Bool
+	// orders are exported to files, which erasure doesn't change
+	fileArchives bool
 }
 
 var ErrNotFound = errors.New("not found")
 
-func NewStorage(ls Cacher, dbs DataBaser) *Storage {
+// ErrDuplicate is returned by Add of order_uid which is saved already,
+// stored order isn't changed
+var ErrDuplicate = errors.New("order already exists")
+
+// erasure modes: delete removes orders with all their rows, anonymize
+// replaces personal data of delivery and keeps orders and payments
+const (
+	ErasureDelete    = "delete"
+	ErasureAnonymize = "anonymize"
+)
+
+// value of anonymized delivery fields
+const Anonymized = "[erased]"
+
+var ErrUnknownErasureMode = errors.New("unknown erasure mode")
+
+var ErrOffsetsUnsupported = errors.New("database doesn't keep kafka offsets")
+
+var ErrTopicLocked = errors.New("topic is read by other consumer")
+
+// TopicLock is held by the only consumer which reads topic with offsets
+// kept in db
+type TopicLock interface {
+	// error if lock may be lost, e.g. its connection is broken
+	Ping(ctx context.Context) error
+	Unlock() error
+}
+
+// KafkaOffset is position of consumer in partition, Offset is the next
+// message to read
+type KafkaOffset struct {
+	Topic     string `db:"topic"`
+	Partition int    `db:"partition_id"`
+	Offset    int64  `db:"next_offset"`
+}
+
+// Erasure is audit record of erasure of customer data
+type Erasure struct {
+	ID         int64     `json:"id"`
+	CustomerID string    `json:"customer_id"`
+	Mode       string    `json:"mode"`
+	Actor      string    `json:"actor"`
+	Reason     string    `json:"reason"`
+	OrderUIDs  []string  `json:"order_uids"`
+	CreatedAt  time.Time `json:"created_at"`
+	// archive files may still keep data of erased orders
+	ArchiveFilesKept bool `json:"archive_files_kept"`
+}
+
+func NewStorage(ls Cacher, dbs DataBaser, tc config.TimeoutsConfig) *Storage {
 	if ls == nil || dbs == nil {
 		panic("can't create storage without one or two storagers")
 	}
 	return &Storage{
 		localStorage:    ls,
 		dataBaseStorage: dbs,
+		timeouts:        tc,
 	}
 }
 
 type DataBaser interface {
-	Add(ord *order.Order) error
-	Find(orderUID string) (*order.Order, error)
-	GetInitialData(size int) ([]*order.Order, error)
+	Add(ctx context.Context, ord *order.Order) error
+	Find(ctx context.Context, orderUID string) (*order.Order, error)
+	GetInitialData(ctx context.Context, size int) ([]*order.Order, error)
+	// ErrNotFound if there is no order
+	Delete(ctx context.Context, orderUID string) error
+	// erases orders of er.CustomerID by er.Mode and saves er as audit record
+	// in the same transaction, ID, OrderUIDs and CreatedAt are filled
+	EraseCustomer(ctx context.Context, er *Erasure) error
+	Ping(ctx context.Context) error
 	Shutdown()
 }
 
+// OutboxEvent is saved in the same transaction as order and published by
+// relay, Trace is json of trace context of saving
+type OutboxEvent struct {
+	ID      int64  `db:"id"`
+	Type    string `db:"event_type"`
+	Key     string `db:"event_key"`
+	Payload []byte `db:"payload"`
+	Trace   []byte `db:"trace_context"`
+}
+
+// OffsetStorer is implemented by databases which keep kafka offsets with orders
+type OffsetStorer interface {
+	AddWithOffset(ctx context.Context, ord *order.Order, off KafkaOffset) error
+	SaveOffset(ctx context.Context, off KafkaOffset) error
+	Offsets(ctx context.Context, topic string) ([]KafkaOffset, error)
+	// ErrTopicLocked if lock is held by other consumer
+	LockTopic(ctx context.Context, topic string) (TopicLock, error)
+}
+
 type Cacher interface {
-	Add(ord *order.Order)
-	Find(orderUID string) *order.Order
-	Delete(orderUID string)
-	LoadInitialCache(ords []*order.Order)
+	Add(ctx context.Context, ord *order.Order)
+	Find(ctx context.Context, orderUID string) *order.Order
+	Delete(ctx context.Context, orderUID string)
+	LoadInitialCache(ctx context.Context, ords []*order.Order) error
+	Ping(ctx context.Context) error
 	Shutdown()
 }
@@ -1,31 +1,48 @@
 package storage
 
 import (
+	"context"
+	"errors"
 	order "first-task/internal/entities/Order"
+	"first-task/internal/metrics"
+	"first-task/internal/tracing"
 	"fmt"
+	"time"
 
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 )
 
-func (s *Storage) LoadInitialData(size int) error {
+func (s *Storage) LoadInitialData(ctx context.Context, size int) error {
 	const op = "internal.storage.LoadInitialData"
-	
+
 	zap.L().Info("start initialization cache")
+	// failed warmup is finished too, orders are read from db then
+	defer s.warm.Store(true)
+
+	ctx, cancel := withTimeout(ctx, s.timeouts.InitialLoad)
+	defer cancel()
 
-	ords, err := s.dataBaseStorage.GetInitialData(size)
+	ords, err := s.dataBaseStorage.GetInitialData(ctx, size)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	s.localStorage.LoadInitialCache(ords)
+	err = s.localStorage.LoadInitialCache(ctx, ords)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
 
 	return nil
 }
 
-func (s *Storage) AddOrder(ord *order.Order) error {
+func (s *Storage) AddOrder(ctx context.Context, ord *order.Order) error {
 	const op = "internal.storage.AddOrder"
 
-	err := s.dataBaseStorage.Add(ord)
+	ctx, cancel := withTimeout(ctx, s.timeouts.DBWrite)
+	defer cancel()
+
+	err := s.dataBaseStorage.Add(ctx, ord)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
@@ -33,27 +50,214 @@ func (s *Storage) AddOrder(ord *order.Order) error {
 	return nil
 }
 
-func (s *Storage) FindOrder(orderUID string) (*order.Order, error) {
+func (s *Storage) FindOrder(ctx context.Context, orderUID string) (_ *order.Order, err error) {
 	const op = "internal.storage.FindOrder"
 
+	ctx, span := tracing.Tracer().Start(ctx, "storage.FindOrder")
+	span.SetAttributes(attribute.String("order.uid", orderUID))
+	defer func() {
+		// missing order isn't failure of storage
+		if errors.Is(err, ErrNotFound) {
+			tracing.End(span, nil)
+			return
+		}
+		tracing.End(span, err)
+	}()
+
 	var result *order.Order
-	var err error
 
-	result = s.localStorage.Find(orderUID)
+	cacheCtx, cancel := withTimeout(ctx, s.timeouts.Cache)
+	result = s.localStorage.Find(cacheCtx, orderUID)
+	cancel()
+	metrics.ObserveCache(metrics.TierCache, result != nil)
+	span.SetAttributes(attribute.Bool("cache.hit", result != nil))
 	if result != nil {
 		return result, nil
 	}
 
-	result, err = s.dataBaseStorage.Find(orderUID)
+	dbCtx, cancel := withTimeout(ctx, s.timeouts.DBRead)
+	result, err = s.dataBaseStorage.Find(dbCtx, orderUID)
+	cancel()
+	if err == nil || errors.Is(err, ErrNotFound) {
+		metrics.ObserveCache(metrics.TierDB, err == nil)
+	}
 	if err != nil {
 		return &order.Order{}, fmt.Errorf("%s: %w", op, err)
 	}
-	s.localStorage.Add(result)
+
+	cacheCtx, cancel = withTimeout(ctx, s.timeouts.Cache)
+	s.localStorage.Add(cacheCtx, result)
+	cancel()
 
 	return result, nil
 }
 
-func (s Storage) Shutdown() {
+// DeleteOrder removes order from db and then from cache
+func (s *Storage) DeleteOrder(ctx context.Context, orderUID string) error {
+	const op = "internal.storage.DeleteOrder"
+
+	dbCtx, cancel := withTimeout(ctx, s.timeouts.DBWrite)
+	err := s.dataBaseStorage.Delete(dbCtx, orderUID)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.invalidate(ctx, orderUID)
+
+	return nil
+}
+
+// SetFileArchives tells that orders are exported to archive files, erasure
+// doesn't change them and marks it in audit record
+func (s *Storage) SetFileArchives(enabled bool) {
+	s.fileArchives = enabled
+}
+
+// EraseCustomer erases data of customer in db, drops erased orders from
+// cache and returns audit record
+func (s *Storage) EraseCustomer(
+	ctx context.Context, customerID, mode, actor, reason string,
+) (*Erasure, error) {
+	const op = "internal.storage.EraseCustomer"
+
+	if mode != ErasureDelete && mode != ErasureAnonymize {
+		return nil, fmt.Errorf("%s: %w: %s", op, ErrUnknownErasureMode, mode)
+	}
+
+	er := &Erasure{
+		CustomerID:       customerID,
+		Mode:             mode,
+		Actor:            actor,
+		Reason:           reason,
+		ArchiveFilesKept: s.fileArchives,
+	}
+
+	dbCtx, cancel := withTimeout(ctx, s.timeouts.DBWrite)
+	err := s.dataBaseStorage.EraseCustomer(dbCtx, er)
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.invalidate(ctx, er.OrderUIDs...)
+	zap.L().Info(fmt.Sprintf(
+		"erasure %d: %s orders of customer by %s: %d orders",
+		er.ID, mode, actor, len(er.OrderUIDs),
+	))
+	if er.ArchiveFilesKept {
+		zap.L().Warn(fmt.Sprintf("erasure %d: archive files aren't erased", er.ID))
+	}
+
+	return er, nil
+}
+
+func (s *Storage) invalidate(ctx context.Context, orderUIDs ...string) {
+	for _, uid := range orderUIDs {
+		cacheCtx, cancel := withTimeout(ctx, s.timeouts.Cache)
+		s.localStorage.Delete(cacheCtx, uid)
+		cancel()
+	}
+}
+
+func (s *Storage) Shutdown() {
 	s.localStorage.Shutdown()
 	s.dataBaseStorage.Shutdown()
 }
+
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// Warm reports whether LoadInitialData is finished
+func (s *Storage) Warm() bool {
+	return s.warm.Load()
+}
+
+func (s *Storage) PingDB(ctx context.Context) error {
+	return s.dataBaseStorage.Ping(ctx)
+}
+
+func (s *Storage) PingCache(ctx context.Context) error {
+	return s.localStorage.Ping(ctx)
+}
+
+// AddOrderAt saves order with offset after its message, ErrOffsetsUnsupported
+// if database doesn't keep offsets
+func (s *Storage) AddOrderAt(ctx context.Context, ord *order.Order, off KafkaOffset) error {
+	const op = "internal.storage.AddOrderAt"
+
+	db, ok := s.dataBaseStorage.(OffsetStorer)
+	if !ok {
+		return fmt.Errorf("%s: %w", op, ErrOffsetsUnsupported)
+	}
+
+	ctx, cancel := withTimeout(ctx, s.timeouts.DBWrite)
+	defer cancel()
+
+	if err := db.AddWithOffset(ctx, ord, off); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) SaveOffset(ctx context.Context, off KafkaOffset) error {
+	const op = "internal.storage.SaveOffset"
+
+	db, ok := s.dataBaseStorage.(OffsetStorer)
+	if !ok {
+		return fmt.Errorf("%s: %w", op, ErrOffsetsUnsupported)
+	}
+
+	ctx, cancel := withTimeout(ctx, s.timeouts.DBWrite)
+	defer cancel()
+
+	if err := db.SaveOffset(ctx, off); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) Offsets(ctx context.Context, topic string) ([]KafkaOffset, error) {
+	const op = "internal.storage.Offsets"
+
+	db, ok := s.dataBaseStorage.(OffsetStorer)
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", op, ErrOffsetsUnsupported)
+	}
+
+	ctx, cancel := withTimeout(ctx, s.timeouts.DBRead)
+	defer cancel()
+
+	offs, err := db.Offsets(ctx, topic)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return offs, nil
+}
+
+// LockTopic takes lock of topic, ErrTopicLocked if other consumer holds it
+func (s *Storage) LockTopic(ctx context.Context, topic string) (TopicLock, error) {
+	const op = "internal.storage.LockTopic"
+
+	db, ok := s.dataBaseStorage.(OffsetStorer)
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", op, ErrOffsetsUnsupported)
+	}
+
+	ctx, cancel := withTimeout(ctx, s.timeouts.DBWrite)
+	defer cancel()
+
+	lock, err := db.LockTopic(ctx, topic)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return lock, nil
+}
@@ -0,0 +1,326 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"first-task/internal/config"
+	order "first-task/internal/entities/Order"
+	"testing"
+	"time"
+)
+
+type CacherMock struct {
+	data map[string]*order.Order
+}
+
+func (cm *CacherMock) Add(_ context.Context, ord *order.Order) {
+	cm.data[ord.OrderUID] = ord
+}
+
+func (cm *CacherMock) Find(_ context.Context, orderUID string) *order.Order {
+	return cm.data[orderUID]
+}
+
+func (cm *CacherMock) Delete(_ context.Context, orderUID string) {
+	delete(cm.data, orderUID)
+}
+
+func (cm *CacherMock) LoadInitialCache(ctx context.Context, ords []*order.Order) error {
+	for _, v := range ords {
+		cm.Add(ctx, v)
+	}
+	return nil
+}
+
+func (cm *CacherMock) Ping(context.Context) error { return nil }
+
+func (cm *CacherMock) Shutdown() {}
+
+// answers after delay or when ctx is done
+type DataBaserMock struct {
+	delay time.Duration
+	data  map[string]*order.Order
+}
+
+func (dm *DataBaserMock) wait(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(dm.delay):
+		return nil
+	}
+}
+
+func (dm *DataBaserMock) Add(ctx context.Context, ord *order.Order) error {
+	if err := dm.wait(ctx); err != nil {
+		return err
+	}
+	dm.data[ord.OrderUID] = ord
+	return nil
+}
+
+func (dm *DataBaserMock) Find(ctx context.Context, orderUID string) (*order.Order, error) {
+	if err := dm.wait(ctx); err != nil {
+		return nil, err
+	}
+	if ord, ok := dm.data[orderUID]; ok {
+		return ord, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (dm *DataBaserMock) GetInitialData(ctx context.Context, size int) ([]*order.Order, error) {
+	if err := dm.wait(ctx); err != nil {
+		return nil, err
+	}
+	res := make([]*order.Order, 0, size)
+	for _, v := range dm.data {
+		res = append(res, v)
+	}
+	return res, nil
+}
+
+func (dm *DataBaserMock) Delete(ctx context.Context, orderUID string) error {
+	if err := dm.wait(ctx); err != nil {
+		return err
+	}
+	if _, ok := dm.data[orderUID]; !ok {
+		return ErrNotFound
+	}
+	delete(dm.data, orderUID)
+	return nil
+}
+
+func (dm *DataBaserMock) EraseCustomer(ctx context.Context, er *Erasure) error {
+	if err := dm.wait(ctx); err != nil {
+		return err
+	}
+	for uid, ord := range dm.data {
+		if ord.CustomerID != er.CustomerID {
+			continue
+		}
+		er.OrderUIDs = append(er.OrderUIDs, uid)
+		if er.Mode == ErasureDelete {
+			delete(dm.data, uid)
+		} else {
+			ord.Delivery.Name = Anonymized
+		}
+	}
+	er.ID, er.CreatedAt = 1, time.Now()
+	return nil
+}
+
+func (dm *DataBaserMock) Ping(ctx context.Context) error {
+	return dm.wait(ctx)
+}
+
+func (dm *DataBaserMock) Shutdown() {}
+
+func newTestStorage(delay time.Duration, tc config.TimeoutsConfig) (*Storage, *CacherMock) {
+	cache := &CacherMock{data: map[string]*order.Order{}}
+	db := &DataBaserMock{
+		delay: delay,
+		data:  map[string]*order.Order{"test": {OrderUID: "test"}},
+	}
+	return NewStorage(cache, db, tc), cache
+}
+
+func TestFindOrder(t *testing.T) {
+	str, cache := newTestStorage(0, config.TimeoutsConfig{})
+
+	ord, err := str.FindOrder(context.Background(), "test")
+	if err != nil || ord.OrderUID != "test" {
+		t.Fatalf("can't find order in db: %v", err)
+	}
+	if cache.data["test"] == nil {
+		t.Error("order from db isn't added to cache")
+	}
+
+	_, err = str.FindOrder(context.Background(), "unknown")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("wrong error\nwait: %v\nget: %v", ErrNotFound, err)
+	}
+}
+
+func TestTimeouts(t *testing.T) {
+	str, _ := newTestStorage(time.Second, config.TimeoutsConfig{
+		DBRead:      time.Millisecond * 10,
+		DBWrite:     time.Millisecond * 10,
+		InitialLoad: time.Millisecond * 10,
+	})
+
+	_, err := str.FindOrder(context.Background(), "test")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("find isn't stopped by db_read timeout: %v", err)
+	}
+
+	err = str.AddOrder(context.Background(), &order.Order{OrderUID: "new"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("add isn't stopped by db_write timeout: %v", err)
+	}
+
+	if str.Warm() {
+		t.Error("warm before initial load")
+	}
+	err = str.LoadInitialData(context.Background(), 10)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("initial load isn't stopped by timeout: %v", err)
+	}
+	// app works without cache, readiness doesn't wait for next attempt
+	if !str.Warm() {
+		t.Error("failed initial load isn't finished")
+	}
+}
+
+func TestCanceledContext(t *testing.T) {
+	str, _ := newTestStorage(time.Second, config.TimeoutsConfig{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := str.FindOrder(ctx, "test")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("find isn't stopped by canceled context: %v", err)
+	}
+}
+
+func TestDeleteOrder(t *testing.T) {
+	str, cache := newTestStorage(0, config.TimeoutsConfig{})
+	cache.data["test"] = &order.Order{OrderUID: "test"}
+
+	if err := str.DeleteOrder(context.Background(), "test"); err != nil {
+		t.Fatal(err)
+	}
+	if cache.data["test"] != nil {
+		t.Error("deleted order is left in cache")
+	}
+
+	err := str.DeleteOrder(context.Background(), "test")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("wrong error\nwait: %v\nget: %v", ErrNotFound, err)
+	}
+}
+
+func TestEraseCustomer(t *testing.T) {
+	str, cache := newTestStorage(0, config.TimeoutsConfig{})
+	ord := &order.Order{OrderUID: "erased", CustomerID: "customer"}
+	if err := str.AddOrder(context.Background(), ord); err != nil {
+		t.Fatal(err)
+	}
+	cache.data["erased"] = ord
+
+	_, err := str.EraseCustomer(context.Background(), "customer", "forget", "test", "")
+	if !errors.Is(err, ErrUnknownErasureMode) {
+		t.Errorf("wrong error\nwait: %v\nget: %v", ErrUnknownErasureMode, err)
+	}
+
+	er, err := str.EraseCustomer(
+		context.Background(), "customer", ErasureAnonymize, "test", "request",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(er.OrderUIDs) != 1 || er.Actor != "test" || er.Reason != "request" || er.ArchiveFilesKept {
+		t.Errorf("wrong audit record %+v", er)
+	}
+	if cache.data["erased"] != nil {
+		t.Error("erased order is left in cache")
+	}
+
+	found, err := str.FindOrder(context.Background(), "erased")
+	if err != nil || found.Delivery.Name != Anonymized {
+		t.Errorf("order isn't anonymized: %+v, %v", found, err)
+	}
+
+	// exported files aren't erased, audit tells it
+	str.SetFileArchives(true)
+	er, err = str.EraseCustomer(context.Background(), "customer", ErasureDelete, "test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !er.ArchiveFilesKept {
+		t.Error("kept archive files aren't written to audit record")
+	}
+}
+
+// database which keeps offsets
+type offsetDataBaserMock struct {
+	DataBaserMock
+	offsets map[int]int64
+	locked  bool
+}
+
+type topicLockMock struct{ db *offsetDataBaserMock }
+
+func (tl topicLockMock) Ping(context.Context) error { return nil }
+
+func (tl topicLockMock) Unlock() error {
+	tl.db.locked = false
+	return nil
+}
+
+func (om *offsetDataBaserMock) LockTopic(context.Context, string) (TopicLock, error) {
+	if om.locked {
+		return nil, ErrTopicLocked
+	}
+	om.locked = true
+	return topicLockMock{db: om}, nil
+}
+
+func (om *offsetDataBaserMock) AddWithOffset(ctx context.Context, ord *order.Order, off KafkaOffset) error {
+	if err := om.Add(ctx, ord); err != nil {
+		return err
+	}
+	return om.SaveOffset(ctx, off)
+}
+
+func (om *offsetDataBaserMock) SaveOffset(_ context.Context, off KafkaOffset) error {
+	om.offsets[off.Partition] = off.Offset
+	return nil
+}
+
+func (om *offsetDataBaserMock) Offsets(_ context.Context, topic string) ([]KafkaOffset, error) {
+	offs := make([]KafkaOffset, 0, len(om.offsets))
+	for p, o := range om.offsets {
+		offs = append(offs, KafkaOffset{Topic: topic, Partition: p, Offset: o})
+	}
+	return offs, nil
+}
+
+func TestOffsets(t *testing.T) {
+	str, _ := newTestStorage(0, config.TimeoutsConfig{})
+	err := str.AddOrderAt(context.Background(), &order.Order{OrderUID: "new"}, KafkaOffset{})
+	if !errors.Is(err, ErrOffsetsUnsupported) {
+		t.Errorf("wrong error\nwait: %v\nget: %v", ErrOffsetsUnsupported, err)
+	}
+
+	db := &offsetDataBaserMock{
+		DataBaserMock: DataBaserMock{data: map[string]*order.Order{}},
+		offsets:       map[int]int64{},
+	}
+	str = NewStorage(&CacherMock{data: map[string]*order.Order{}}, db, config.TimeoutsConfig{})
+
+	off := KafkaOffset{Topic: "orders", Partition: 1, Offset: 8}
+	if err := str.AddOrderAt(context.Background(), &order.Order{OrderUID: "new"}, off); err != nil {
+		t.Fatal(err)
+	}
+	if db.data["new"] == nil || db.offsets[1] != 8 {
+		t.Error("order isn't saved with offset")
+	}
+
+	offs, err := str.Offsets(context.Background(), "orders")
+	if err != nil || len(offs) != 1 || offs[0] != off {
+		t.Errorf("wrong offsets: %v, %v", offs, err)
+	}
+
+	lock, err := str.LockTopic(context.Background(), "orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := str.LockTopic(context.Background(), "orders"); !errors.Is(err, ErrTopicLocked) {
+		t.Errorf("lock is taken twice: %v", err)
+	}
+	if err := lock.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,392 @@
+// Package storagetest is conformance suite for implementations of
+// storage.DataBaser and storage.Cacher, run it from tests of implementation.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	delivery "first-task/internal/entities/Delivery"
+	item "first-task/internal/entities/Item"
+	order "first-task/internal/entities/Order"
+	payment "first-task/internal/entities/Payment"
+	"first-task/internal/storage"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ItemChrtID is item of all suite orders, DataBaser must have it in items
+const ItemChrtID = 9934930
+
+const concurrency = 20
+
+// Order returns valid order, payment request_id is unique for every uid
+func Order(orderUID string) *order.Order {
+	return &order.Order{
+		OrderUID:    orderUID,
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery: delivery.Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "test@gmail.com",
+		},
+		Payment: payment.Payment{
+			Transaction:  "b563feb7b2b84b6test",
+			RequestID:    orderUID,
+			Currency:     "USD",
+			Provider:     "wbpay",
+			Amount:       1817,
+			PaymentDT:    1637907727,
+			Bank:         "alpha",
+			DeliveryCost: 1500,
+			GoodsTotal:   317,
+		},
+		Items: []item.Item{
+			{
+				ChrtID:      ItemChrtID,
+				TrackNumber: "WBILMTESTTRACK",
+				Price:       453,
+				RID:         "ab4219087a764ae0btest",
+				Name:        "Mascaras",
+				Sale:        30,
+				Size:        "0",
+				TotalPrice:  317,
+				NMID:        2389212,
+				Brand:       "Vivienne Sabo",
+				Status:      202,
+			},
+		},
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		ShardKey:        "9",
+		SMID:            99,
+		DateCreated:     "2021-11-26T06:22:19Z",
+		OOFShard:        "1",
+	}
+}
+
+// uids are unique between runs, so suite can use not empty storage
+type uidGen struct {
+	prefix string
+	mu     sync.Mutex
+	n      int
+}
+
+func newUIDGen() *uidGen {
+	return &uidGen{prefix: "ct" + strconv.FormatInt(time.Now().UnixNano(), 36)}
+}
+
+func (g *uidGen) next() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.n++
+	return fmt.Sprintf("%sn%d", g.prefix, g.n)
+}
+
+func requireEqual(t *testing.T, want, got *order.Order) {
+	t.Helper()
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("wrong order\nwait: %+v\nget: %+v", want, got)
+	}
+}
+
+// RunDataBaser checks dbs, it isn't shut down by suite
+func RunDataBaser(t *testing.T, dbs storage.DataBaser) {
+	uids := newUIDGen()
+	ctx := context.Background()
+
+	t.Run("add and find", func(t *testing.T) {
+		ord := Order(uids.next())
+		if err := dbs.Add(ctx, ord); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := dbs.Find(ctx, ord.OrderUID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		requireEqual(t, ord, got)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := dbs.Find(ctx, uids.next())
+		if !errors.Is(err, storage.ErrNotFound) {
+			t.Fatalf("waited ErrNotFound, get %v", err)
+		}
+	})
+
+	// second add of the same uid fails and keeps stored order
+	t.Run("duplicate", func(t *testing.T) {
+		ord := Order(uids.next())
+		if err := dbs.Add(ctx, ord); err != nil {
+			t.Fatal(err)
+		}
+
+		changed := Order(ord.OrderUID)
+		changed.Payment.RequestID = uids.next()
+		changed.TrackNumber = "CHANGED"
+		if err := dbs.Add(ctx, changed); !errors.Is(err, storage.ErrDuplicate) {
+			t.Fatalf("waited ErrDuplicate, get %v", err)
+		}
+
+		got, err := dbs.Find(ctx, ord.OrderUID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		requireEqual(t, ord, got)
+	})
+
+	t.Run("initial load", func(t *testing.T) {
+		added := make([]*order.Order, 3)
+		for i := range added {
+			added[i] = Order(uids.next())
+			if err := dbs.Add(ctx, added[i]); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		got, err := dbs.GetInitialData(ctx, len(added))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(added) {
+			t.Fatalf("waited %d orders, get %d", len(added), len(got))
+		}
+		// the last added go first
+		for i, ord := range got {
+			requireEqual(t, added[len(added)-1-i], ord)
+		}
+	})
+
+	t.Run("concurrency", func(t *testing.T) {
+		ords := make([]*order.Order, concurrency)
+		for i := range ords {
+			ords[i] = Order(uids.next())
+		}
+
+		errs := make(chan error, len(ords))
+		wg := &sync.WaitGroup{}
+		for _, ord := range ords {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := dbs.Add(ctx, ord); err != nil {
+					errs <- err
+					return
+				}
+				if _, err := dbs.Find(ctx, ord.OrderUID); err != nil {
+					errs <- err
+				}
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			t.Error(err)
+		}
+
+		for _, ord := range ords {
+			got, err := dbs.Find(ctx, ord.OrderUID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			requireEqual(t, ord, got)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		ord := Order(uids.next())
+		if err := dbs.Add(ctx, ord); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := dbs.Delete(ctx, ord.OrderUID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := dbs.Find(ctx, ord.OrderUID); !errors.Is(err, storage.ErrNotFound) {
+			t.Fatalf("deleted order is found: %v", err)
+		}
+		if err := dbs.Delete(ctx, ord.OrderUID); !errors.Is(err, storage.ErrNotFound) {
+			t.Fatalf("waited ErrNotFound on second delete, get %v", err)
+		}
+	})
+
+	t.Run("erase customer", func(t *testing.T) {
+		customer, other := uids.next(), Order(uids.next())
+		if err := dbs.Add(ctx, other); err != nil {
+			t.Fatal(err)
+		}
+
+		ords := make([]*order.Order, 2)
+		for i := range ords {
+			ords[i] = Order(uids.next())
+			ords[i].CustomerID = customer
+			if err := dbs.Add(ctx, ords[i]); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		er := &storage.Erasure{
+			CustomerID: customer, Mode: storage.ErasureAnonymize, Actor: "test",
+		}
+		if err := dbs.EraseCustomer(ctx, er); err != nil {
+			t.Fatal(err)
+		}
+		if er.ID == 0 || er.CreatedAt.IsZero() || len(er.OrderUIDs) != len(ords) {
+			t.Fatalf("wrong audit record %+v", er)
+		}
+		for _, ord := range ords {
+			want := *ord
+			want.Delivery.Name = storage.Anonymized
+			want.Delivery.Phone = storage.Anonymized
+			want.Delivery.Address = storage.Anonymized
+			want.Delivery.Email = storage.Anonymized
+
+			got, err := dbs.Find(ctx, ord.OrderUID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			requireEqual(t, &want, got)
+		}
+
+		er = &storage.Erasure{
+			CustomerID: customer, Mode: storage.ErasureDelete, Actor: "test",
+		}
+		if err := dbs.EraseCustomer(ctx, er); err != nil {
+			t.Fatal(err)
+		}
+		if len(er.OrderUIDs) != len(ords) {
+			t.Fatalf("wrong erased orders %v", er.OrderUIDs)
+		}
+		for _, ord := range ords {
+			if _, err := dbs.Find(ctx, ord.OrderUID); !errors.Is(err, storage.ErrNotFound) {
+				t.Fatalf("erased order is found: %v", err)
+			}
+		}
+
+		// orders of other customers are kept
+		got, err := dbs.Find(ctx, other.OrderUID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		requireEqual(t, other, got)
+	})
+
+	t.Run("canceled context", func(t *testing.T) {
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		ord := Order(uids.next())
+		if err := dbs.Add(canceled, ord); err == nil {
+			t.Fatal("order is added with canceled context")
+		}
+		if _, err := dbs.Find(canceled, ord.OrderUID); err == nil || errors.Is(err, storage.ErrNotFound) {
+			t.Fatalf("waited context error, get %v", err)
+		}
+		if _, err := dbs.Find(ctx, ord.OrderUID); !errors.Is(err, storage.ErrNotFound) {
+			t.Fatalf("order of canceled add is saved: %v", err)
+		}
+	})
+}
+
+// RunCacher checks c, ttl is ttl which c was created with, 0 skips ttl check.
+// Cacher isn't shut down by suite.
+func RunCacher(t *testing.T, c storage.Cacher, ttl time.Duration) {
+	uids := newUIDGen()
+	ctx := context.Background()
+
+	t.Run("add and find", func(t *testing.T) {
+		ord := Order(uids.next())
+		c.Add(ctx, ord)
+		requireEqual(t, ord, c.Find(ctx, ord.OrderUID))
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		if got := c.Find(ctx, uids.next()); got != nil {
+			t.Fatalf("found not added order %+v", got)
+		}
+	})
+
+	t.Run("overwrite", func(t *testing.T) {
+		ord := Order(uids.next())
+		c.Add(ctx, ord)
+
+		changed := Order(ord.OrderUID)
+		changed.TrackNumber = "CHANGED"
+		c.Add(ctx, changed)
+
+		requireEqual(t, changed, c.Find(ctx, ord.OrderUID))
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		ord := Order(uids.next())
+		c.Add(ctx, ord)
+		c.Delete(ctx, ord.OrderUID)
+
+		if got := c.Find(ctx, ord.OrderUID); got != nil {
+			t.Fatalf("deleted order is found %+v", got)
+		}
+		// deleting of missing key is fine
+		c.Delete(ctx, ord.OrderUID)
+	})
+
+	t.Run("initial load", func(t *testing.T) {
+		ords := make([]*order.Order, 10)
+		for i := range ords {
+			ords[i] = Order(uids.next())
+		}
+		if err := c.LoadInitialCache(ctx, ords); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, ord := range ords {
+			requireEqual(t, ord, c.Find(ctx, ord.OrderUID))
+		}
+	})
+
+	t.Run("concurrency", func(t *testing.T) {
+		ords := make([]*order.Order, concurrency)
+		for i := range ords {
+			ords[i] = Order(uids.next())
+		}
+
+		wg := &sync.WaitGroup{}
+		for _, ord := range ords {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				c.Add(ctx, ord)
+				c.Find(ctx, ord.OrderUID)
+				c.Find(ctx, uids.next())
+			}()
+		}
+		wg.Wait()
+
+		for _, ord := range ords {
+			requireEqual(t, ord, c.Find(ctx, ord.OrderUID))
+		}
+	})
+
+	t.Run("ttl", func(t *testing.T) {
+		if ttl <= 0 {
+			t.Skip("ttl isn't set")
+		}
+
+		ord := Order(uids.next())
+		c.Add(ctx, ord)
+		time.Sleep(ttl + ttl/2)
+
+		if got := c.Find(ctx, ord.OrderUID); got != nil {
+			t.Fatalf("order is found after ttl %+v", got)
+		}
+	})
+}
@@ -0,0 +1,125 @@
+package integrational
+
+import (
+	"context"
+	"encoding/json"
+	"first-task/internal/config"
+	order "first-task/internal/entities/Order"
+	"first-task/internal/storage/postgres"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+const benchOrders = 10000
+
+// fills fresh db with n orders with ids 1..n, each has mock item
+var benchOrdersSQL = []string{
+	`insert into delivery_info (name, phone, zip, city, address, region, email)
+	select 'Test Testov', '+9720000000', '2639809', 'Kiryat Mozkin',
+		'Ploshad Mira 15', 'Kraiot', 'test@gmail.com'
+	from generate_series(1, $1);`,
+	`insert into payment_info (transaction, request_id, currency, provider,
+		amount, payment_dt, bank, delivery_cost, goods_total, custom_fee)
+	select 'b563feb7b2b84b6test', g::text, 'USD', 'wbpay', 1817, 1637907727,
+		'alpha', 1500, 317, 0
+	from generate_series(1, $1) as g;`,
+	`insert into orders (order_uid, track_number, entry, delivery_id,
+		payment_id, locale, internal_signature, customer_id, delivery_service,
+		shardkey, sm_id, date_created, oof_shard)
+	select 'bench' || g, 'WBILMTESTTRACK', 'WBIL', g, g, 'en', '', 'test',
+		'meest', '9', 99, '2021-11-26T06:22:19Z', '1'
+	from generate_series(1, $1) as g;`,
+	`insert into orders_items (order_id, item_id)
+	select g, 9934930 from generate_series(1, $1) as g;`,
+}
+
+// aggregate as json in db, how orders were loaded before typed rows
+const benchJSONSQL = `
+	select json_build_object(
+		'order_uid', o.order_uid, 'track_number', o.track_number,
+		'entry', o.entry, 'locale', o.locale,
+		'internal_signature', o.internal_signature,
+		'customer_id', o.customer_id, 'delivery_service', o.delivery_service,
+		'shardkey', o.shardkey, 'sm_id', o.sm_id,
+		'date_created', o.date_created, 'oof_shard', o.oof_shard,
+		'delivery', json_build_object(
+			'id', di.id, 'name', di.name, 'phone', di.phone, 'zip', di.zip,
+			'city', di.city, 'address', di.address, 'region', di.region,
+			'email', di.email
+		),
+		'payment', json_build_object(
+			'id', p.id, 'transaction', p.transaction,
+			'request_id', p.request_id, 'currency', p.currency,
+			'provider', p.provider, 'amount', p.amount,
+			'payment_dt', p.payment_dt, 'bank', p.bank,
+			'delivery_cost', p.delivery_cost, 'goods_total', p.goods_total,
+			'custom_fee', p.custom_fee
+		),
+		'items', (
+			select json_agg(json_build_object(
+				'chrt_id', i.chrt_id, 'track_number', i.track_number,
+				'price', i.price, 'rid', i.rid, 'name', i.name, 'sale', i.sale,
+				'size', i.size, 'total_price', i.total_price, 'nm_id', i.nm_id,
+				'brand', i.brand, 'status', i.status
+			))
+			from orders_items as oi join items as i on oi.item_id=i.chrt_id
+			where oi.order_id=o.id
+		)
+	)
+	from orders as o join delivery_info as di on o.delivery_id=di.id
+	join payment_info as p on o.payment_id=p.id
+	order by o.id desc limit $1;`
+
+func BenchmarkInitialData(b *testing.B) {
+	pgContainer := SetupTestDB(b)
+	defer pgContainer.Terminate(context.Background())
+
+	host, err := pgContainer.Host(context.Background())
+	require.NoError(b, err)
+	port, err := pgContainer.MappedPort(context.Background(), DBMapped)
+	require.NoError(b, err)
+
+	cfg := config.PostgresConfig{
+		Host:     host,
+		Port:     port.Port(),
+		User:     DBUser,
+		Password: DBPassword,
+		DBName:   DBName,
+		SSLMode:  "disable",
+	}
+	str := postgres.NewPostgres(cfg)
+	defer str.Shutdown()
+
+	db, err := sqlx.Open("postgres", postgres.ConnString(cfg))
+	require.NoError(b, err)
+	defer db.Close()
+
+	for _, q := range benchOrdersSQL {
+		_, err := db.Exec(q, benchOrders)
+		require.NoError(b, err)
+	}
+
+	b.Run("json", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			data := make([][]byte, 0, benchOrders)
+			require.NoError(b, db.Select(&data, benchJSONSQL, benchOrders))
+			for _, v := range data {
+				ord := new(order.Order)
+				require.NoError(b, json.Unmarshal(v, ord))
+			}
+			require.Len(b, data, benchOrders)
+		}
+	})
+
+	b.Run("rows", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			ords, err := str.GetInitialData(context.Background(), benchOrders)
+			require.NoError(b, err)
+			require.Len(b, ords, benchOrders)
+		}
+	})
+}
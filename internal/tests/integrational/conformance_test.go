@@ -0,0 +1,58 @@
+package integrational
+
+import (
+	"context"
+	"first-task/internal/config"
+	"first-task/internal/storage/postgres"
+	"first-task/internal/storage/redisStorage"
+	"first-task/internal/storage/storagetest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisConformance(t *testing.T) {
+	t.Parallel()
+	redisContainer := SetupTestRedis(t)
+	defer redisContainer.Terminate(context.Background())
+
+	host, err := redisContainer.Host(context.Background())
+	require.NoError(t, err)
+	port, err := redisContainer.MappedPort(context.Background(), RedisMapped)
+	require.NoError(t, err)
+
+	rs := redisStorage.NewRedisStorage(config.RedisConfig{
+		Host:       host,
+		Port:       port.Port(),
+		KeyPrefix:  redisStorage.DefaultKeyPrefix,
+		KeyVersion: 1,
+		TTL:        time.Second,
+	})
+	defer rs.Shutdown()
+
+	storagetest.RunCacher(t, rs, time.Second)
+}
+
+func TestPostgresConformance(t *testing.T) {
+	t.Parallel()
+	pgContainer := SetupTestDB(t)
+	defer pgContainer.Terminate(context.Background())
+
+	host, err := pgContainer.Host(context.Background())
+	require.NoError(t, err)
+	port, err := pgContainer.MappedPort(context.Background(), DBMapped)
+	require.NoError(t, err)
+
+	str := postgres.NewPostgres(config.PostgresConfig{
+		Host:     host,
+		Port:     port.Port(),
+		User:     DBUser,
+		Password: DBPassword,
+		DBName:   DBName,
+		SSLMode:  "disable",
+	})
+	defer str.Shutdown()
+
+	storagetest.RunDataBaser(t, str)
+}
@@ -0,0 +1,77 @@
+package integrational
+
+import (
+	"context"
+	"first-task/internal/config"
+	"first-task/internal/storage"
+	"first-task/internal/storage/postgres"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKafkaOffsets(t *testing.T) {
+	t.Parallel()
+	pgContainer := SetupTestDB(t)
+	defer pgContainer.Terminate(context.Background())
+
+	host, err := pgContainer.Host(context.Background())
+	require.NoError(t, err)
+	port, err := pgContainer.MappedPort(context.Background(), DBMapped)
+	require.NoError(t, err)
+
+	str := postgres.NewPostgres(config.PostgresConfig{
+		Host:     host,
+		Port:     port.Port(),
+		User:     DBUser,
+		Password: DBPassword,
+		DBName:   DBName,
+		SSLMode:  "disable",
+	})
+	defer str.Shutdown()
+
+	ctx := context.Background()
+	off := storage.KafkaOffset{Topic: "orders", Partition: 1, Offset: 11}
+	require.NoError(t, str.AddWithOffset(ctx, &testOrder, off))
+
+	fromDB, err := str.Find(ctx, testOrder.OrderUID)
+	require.NoError(t, err)
+	require.Equal(t, &testOrder, fromDB)
+
+	// offset isn't moved back by message processed twice
+	require.NoError(t, str.SaveOffset(ctx, storage.KafkaOffset{Topic: "orders", Partition: 1, Offset: 5}))
+	require.NoError(t, str.SaveOffset(ctx, storage.KafkaOffset{Topic: "orders", Partition: 0, Offset: 3}))
+	require.NoError(t, str.SaveOffset(ctx, storage.KafkaOffset{Topic: "other", Partition: 0, Offset: 7}))
+
+	offs, err := str.Offsets(ctx, "orders")
+	require.NoError(t, err)
+	require.Equal(t, []storage.KafkaOffset{
+		{Topic: "orders", Partition: 0, Offset: 3},
+		{Topic: "orders", Partition: 1, Offset: 11},
+	}, offs)
+
+	// order without items fails on its last insert, offset is rolled back too
+	bad := testOrder
+	bad.OrderUID = "withoutitems"
+	bad.Payment.RequestID = "withoutitems"
+	bad.Items = nil
+	require.Error(t, str.AddWithOffset(ctx, &bad, storage.KafkaOffset{Topic: "orders", Partition: 0, Offset: 4}))
+	offs, err = str.Offsets(ctx, "orders")
+	require.NoError(t, err)
+	require.Equal(t, int64(3), offs[0].Offset)
+
+	// topic is read by one replica
+	lock, err := str.LockTopic(ctx, "orders")
+	require.NoError(t, err)
+	require.NoError(t, lock.Ping(ctx))
+	_, err = str.LockTopic(ctx, "orders")
+	require.ErrorIs(t, err, storage.ErrTopicLocked)
+	other, err := str.LockTopic(ctx, "other")
+	require.NoError(t, err)
+	require.NoError(t, other.Unlock())
+
+	require.NoError(t, lock.Unlock())
+	lock, err = str.LockTopic(ctx, "orders")
+	require.NoError(t, err)
+	require.NoError(t, lock.Unlock())
+}
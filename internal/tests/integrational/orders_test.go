@@ -98,7 +98,7 @@ func TestNewOrder(t *testing.T) {
 					User:     DBUser,
 					Password: DBPassword,
 					DBName:   DBName,
-					SSLMode:  false,
+					SSLMode:  "disable",
 				},
 				RedisConfig: config.RedisConfig{
 					Host: RedisHost,
@@ -0,0 +1,70 @@
+package integrational
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"first-task/internal/config"
+	"first-task/internal/events"
+	"first-task/internal/storage"
+	"first-task/internal/storage/postgres"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutbox(t *testing.T) {
+	t.Parallel()
+	pgContainer := SetupTestDB(t)
+	defer pgContainer.Terminate(context.Background())
+
+	host, err := pgContainer.Host(context.Background())
+	require.NoError(t, err)
+	port, err := pgContainer.MappedPort(context.Background(), DBMapped)
+	require.NoError(t, err)
+
+	str := postgres.NewPostgres(config.PostgresConfig{
+		Host:     host,
+		Port:     port.Port(),
+		User:     DBUser,
+		Password: DBPassword,
+		DBName:   DBName,
+		SSLMode:  "disable",
+	})
+	defer str.Shutdown()
+	str.EnableOutbox()
+
+	ctx := context.Background()
+	require.NoError(t, str.Add(ctx, &testOrder))
+	// redelivered order adds no event
+	require.ErrorIs(t, str.Add(ctx, &testOrder), storage.ErrDuplicate)
+
+	// failed publish keeps event
+	errDown := errors.New("kafka is down")
+	_, err = str.PublishOutbox(ctx, 10, func(context.Context, []storage.OutboxEvent) error {
+		return errDown
+	})
+	require.ErrorIs(t, err, errDown)
+
+	var published []storage.OutboxEvent
+	n, err := str.PublishOutbox(ctx, 10, func(_ context.Context, evs []storage.OutboxEvent) error {
+		published = evs
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.Equal(t, events.TypeOrderIngested, published[0].Type)
+	require.Equal(t, testOrder.OrderUID, published[0].Key)
+
+	var ev events.OrderIngested
+	require.NoError(t, json.Unmarshal(published[0].Payload, &ev))
+	require.Equal(t, events.NewOrderIngested(&testOrder), ev)
+
+	// published events are deleted
+	n, err = str.PublishOutbox(ctx, 10, func(context.Context, []storage.OutboxEvent) error {
+		t.Error("published event is published again")
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 0, n)
+}
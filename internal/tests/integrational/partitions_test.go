@@ -0,0 +1,185 @@
+package integrational
+
+import (
+	"context"
+	"database/sql"
+	"first-task/internal/archive"
+	"first-task/internal/config"
+	"first-task/internal/storage"
+	"first-task/internal/storage/postgres"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartitions(t *testing.T) {
+	t.Parallel()
+	pgContainer := SetupTestDB(t)
+	defer pgContainer.Terminate(context.Background())
+
+	host, err := pgContainer.Host(context.Background())
+	require.NoError(t, err)
+	port, err := pgContainer.MappedPort(context.Background(), DBMapped)
+	require.NoError(t, err)
+
+	cfg := config.PostgresConfig{
+		Host:     host,
+		Port:     port.Port(),
+		User:     DBUser,
+		Password: DBPassword,
+		DBName:   DBName,
+		SSLMode:  "disable",
+	}
+	str := postgres.NewPostgres(cfg)
+	defer str.Shutdown()
+
+	db, err := sql.Open("postgres", postgres.ConnString(cfg))
+	require.NoError(t, err)
+	defer db.Close()
+
+	old := time.Date(2020, time.January, 15, 0, 0, 0, 0, time.UTC)
+
+	t.Run("rows of month without partition are moved from default one", func(t *testing.T) {
+		require.NoError(t, str.Add(context.Background(), &testOrder))
+		_, err := db.Exec(
+			`update orders set created_at = $1 where order_uid = $2;`,
+			old, testOrder.OrderUID,
+		)
+		require.NoError(t, err)
+
+		var inDefault int
+		require.NoError(t, db.QueryRow(`select count(*) from orders_default;`).Scan(&inDefault))
+		require.Equal(t, 1, inDefault)
+
+		created, err := str.EnsurePartitions(context.Background(), old, 0)
+		require.NoError(t, err)
+		require.Equal(t, []string{"orders_p202001"}, created)
+
+		require.NoError(t, db.QueryRow(`select count(*) from orders_default;`).Scan(&inDefault))
+		require.Equal(t, 0, inDefault)
+
+		fromDB, err := str.Find(context.Background(), testOrder.OrderUID)
+		require.NoError(t, err)
+		require.Equal(t, &testOrder, fromDB)
+	})
+
+	t.Run("future partitions", func(t *testing.T) {
+		now := time.Now()
+		_, err := str.EnsurePartitions(context.Background(), now, 3)
+		require.NoError(t, err)
+
+		months, err := str.Partitions(context.Background())
+		require.NoError(t, err)
+		for i := 0; i <= 3; i++ {
+			require.Contains(t, months, postgres.MonthStart(now).AddDate(0, i, 0))
+		}
+
+		// already existing partitions are skipped
+		created, err := str.EnsurePartitions(context.Background(), now, 3)
+		require.NoError(t, err)
+		require.Empty(t, created)
+	})
+
+	archiveDir := t.TempDir()
+	var archivePath string
+
+	t.Run("export old orders to files", func(t *testing.T) {
+		arc := archive.NewArchiver(str, config.ArchiveConfig{
+			Dir: archiveDir, Format: archive.FormatNDJSON, BatchSize: 10,
+		})
+		path, m, err := arc.Export(
+			context.Background(), time.Time{}, postgres.MonthStart(old).AddDate(0, 1, 0),
+		)
+		require.NoError(t, err)
+		require.Equal(t, 1, m.Orders)
+		archivePath = path
+	})
+
+	t.Run("archive old partitions", func(t *testing.T) {
+		removed, err := str.RemoveOldPartitions(
+			context.Background(), postgres.MonthStart(old).AddDate(0, 1, 0), "archive",
+		)
+		require.NoError(t, err)
+		require.Equal(t, []string{"orders_p202001"}, removed)
+
+		_, err = str.Find(context.Background(), testOrder.OrderUID)
+		require.ErrorIs(t, err, storage.ErrNotFound)
+
+		for _, table := range []string{
+			"orders_p202001", "orders_p202001_delivery_info",
+			"orders_p202001_payment_info", "orders_p202001_orders_items",
+		} {
+			var n int
+			require.NoError(t, db.QueryRow(
+				fmt.Sprintf(`select count(*) from archive.%s;`, table),
+			).Scan(&n))
+			require.Equal(t, 1, n, table)
+		}
+
+		for _, table := range []string{"delivery_info", "payment_info", "orders_items"} {
+			var n int
+			require.NoError(t, db.QueryRow(
+				fmt.Sprintf(`select count(*) from %s;`, table),
+			).Scan(&n))
+			require.Equal(t, 0, n, table)
+		}
+	})
+
+	t.Run("restore exported orders", func(t *testing.T) {
+		restored, skipped, err := archive.Restore(context.Background(), archivePath, str, nil)
+		require.NoError(t, err)
+		require.Equal(t, 1, restored)
+		require.Equal(t, 0, skipped)
+
+		fromDB, err := str.Find(context.Background(), testOrder.OrderUID)
+		require.NoError(t, err)
+		require.Equal(t, &testOrder, fromDB)
+	})
+
+	t.Run("erasure covers archived partitions", func(t *testing.T) {
+		er := &storage.Erasure{
+			CustomerID: testOrder.CustomerID, Mode: storage.ErasureAnonymize, Actor: "test",
+		}
+		require.NoError(t, str.EraseCustomer(context.Background(), er))
+		require.Equal(t, []string{testOrder.OrderUID}, er.OrderUIDs)
+
+		var name string
+		require.NoError(t, db.QueryRow(
+			`select name from archive.orders_p202001_delivery_info;`,
+		).Scan(&name))
+		require.Equal(t, storage.Anonymized, name)
+
+		require.NoError(t, str.Delete(context.Background(), testOrder.OrderUID))
+		for _, table := range []string{
+			"orders_p202001", "orders_p202001_delivery_info",
+			"orders_p202001_payment_info", "orders_p202001_orders_items",
+		} {
+			var n int
+			require.NoError(t, db.QueryRow(
+				fmt.Sprintf(`select count(*) from archive.%s;`, table),
+			).Scan(&n))
+			require.Equal(t, 0, n, table)
+		}
+		_, err := str.Find(context.Background(), testOrder.OrderUID)
+		require.ErrorIs(t, err, storage.ErrNotFound)
+	})
+
+	t.Run("drop old partitions", func(t *testing.T) {
+		_, err := str.EnsurePartitions(context.Background(), old.AddDate(0, 1, 0), 0)
+		require.NoError(t, err)
+
+		removed, err := str.RemoveOldPartitions(
+			context.Background(), postgres.MonthStart(old).AddDate(0, 2, 0), "",
+		)
+		require.NoError(t, err)
+		require.Equal(t, []string{"orders_p202002"}, removed)
+
+		var exists bool
+		require.NoError(t, db.QueryRow(
+			`select to_regclass('orders_p202002') is not null;`,
+		).Scan(&exists))
+		require.False(t, exists)
+	})
+}
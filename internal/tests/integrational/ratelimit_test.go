@@ -0,0 +1,55 @@
+package integrational
+
+import (
+	"context"
+	"first-task/internal/config"
+	"first-task/internal/storage/redisStorage"
+	"first-task/internal/web-app/ratelimit"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisRateLimit(t *testing.T) {
+	t.Parallel()
+	redisContainer := SetupTestRedis(t)
+	defer redisContainer.Terminate(context.Background())
+
+	host, err := redisContainer.Host(context.Background())
+	require.NoError(t, err)
+	port, err := redisContainer.MappedPort(context.Background(), RedisMapped)
+	require.NoError(t, err)
+
+	rs := redisStorage.NewRedisStorage(config.RedisConfig{Host: host, Port: port.Port()})
+	defer rs.Shutdown()
+
+	ctx := context.Background()
+	// two replicas share buckets
+	first := ratelimit.NewRedis(rs.Client(), "test")
+	second := ratelimit.NewRedis(rs.Client(), "test")
+
+	for i := 0; i < 2; i++ {
+		ok, _, err := first.Allow(ctx, "client", 1, 2)
+		require.NoError(t, err)
+		require.True(t, ok, "request %d of burst is rejected", i)
+	}
+	ok, retry, err := second.Allow(ctx, "client", 1, 2)
+	require.NoError(t, err)
+	require.False(t, ok)
+	require.Greater(t, retry, time.Duration(0))
+	require.LessOrEqual(t, retry, time.Second)
+
+	ok, _, err = second.Allow(ctx, "other", 1, 2)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	time.Sleep(retry + 50*time.Millisecond)
+	ok, _, err = first.Allow(ctx, "client", 1, 2)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ttl, err := rs.Client().PTTL(ctx, "test:ratelimit:client").Result()
+	require.NoError(t, err)
+	require.Greater(t, ttl, time.Duration(0))
+}
@@ -8,8 +8,11 @@ import (
 	order "first-task/internal/entities/Order"
 	payment "first-task/internal/entities/Payment"
 	"first-task/internal/storage/redisStorage"
+	"fmt"
 	"testing"
+	"time"
 
+	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/require"
 )
 
@@ -80,13 +83,188 @@ func TestRedis(t *testing.T) {
 	}
 
 	t.Run("add, find and delete test", func(t *testing.T) {
-		localStorage.Add(testOrder)
-		dataOrder := localStorage.Find(testOrder.OrderUID)
-		require.Equal(t, testOrder, dataOrder)
-		localStorage.Delete(testOrder.OrderUID)
-		dataOrder = localStorage.Find(testOrder.OrderUID)
-		if dataOrder != nil {
-			t.Error("order didn't deleted from cache")
+		checkAddFindDelete(t, localStorage, testOrder)
+	})
+}
+
+func TestRedisSentinel(t *testing.T) {
+	t.Parallel()
+	master, sentinel := SetupTestRedisSentinel(t)
+	defer master.Terminate(context.Background())
+	defer sentinel.Terminate(context.Background())
+
+	host, err := sentinel.Host(context.Background())
+	require.NoError(t, err)
+	port, err := sentinel.MappedPort(context.Background(), RedisSentinelPort)
+	require.NoError(t, err)
+
+	localStorage := redisStorage.NewRedisStorage(config.RedisConfig{
+		Mode:       redisStorage.ModeSentinel,
+		Addrs:      []string{fmt.Sprintf("%s:%s", host, port.Port())},
+		MasterName: RedisMasterName,
+	})
+	defer localStorage.Shutdown()
+
+	t.Run("add, find and delete through sentinel", func(t *testing.T) {
+		checkAddFindDelete(t, localStorage, &testOrder)
+	})
+}
+
+func TestRedisCluster(t *testing.T) {
+	t.Parallel()
+	clusterContainer := SetupTestRedisCluster(t)
+	defer clusterContainer.Terminate(context.Background())
+
+	addrs := make([]string, 0, len(RedisClusterPorts))
+	for _, p := range RedisClusterPorts {
+		addrs = append(addrs, fmt.Sprintf("localhost:%s", p))
+	}
+
+	localStorage := redisStorage.NewRedisStorage(config.RedisConfig{
+		Mode:  redisStorage.ModeCluster,
+		Addrs: addrs,
+	})
+	defer localStorage.Shutdown()
+
+	t.Run("add, find and delete in cluster", func(t *testing.T) {
+		ords := make([]*order.Order, 0, 10)
+		for i := 0; i < 10; i++ {
+			ord := testOrder
+			ord.OrderUID = fmt.Sprintf("cluster%d", i)
+			ords = append(ords, &ord)
+		}
+
+		// keys are spread across slots, so every master gets some of them
+		require.NoError(t, localStorage.LoadInitialCache(context.Background(), ords))
+		for _, ord := range ords {
+			require.Equal(t, ord, localStorage.Find(context.Background(), ord.OrderUID))
+		}
+
+		checkAddFindDelete(t, localStorage, ords[0])
+	})
+}
+
+func TestRedisPurge(t *testing.T) {
+	t.Parallel()
+	redisContainer := SetupTestRedis(t)
+	defer redisContainer.Terminate(context.Background())
+
+	host, err := redisContainer.Host(context.Background())
+	require.NoError(t, err)
+	port, err := redisContainer.MappedPort(context.Background(), RedisMapped)
+	require.NoError(t, err)
+
+	cfg := config.RedisConfig{
+		Host:       host,
+		Port:       port.Port(),
+		KeyPrefix:  "wb",
+		KeyVersion: 1,
+	}
+	oldStorage := redisStorage.NewRedisStorage(cfg)
+	defer oldStorage.Shutdown()
+	cfg.KeyVersion = 2
+	localStorage := redisStorage.NewRedisStorage(cfg)
+	defer localStorage.Shutdown()
+
+	// key of other app in the same db
+	rdb := redis.NewClient(&redis.Options{
+		Addr: fmt.Sprintf("%s:%s", host, port.Port()),
+	})
+	defer rdb.Close()
+	require.NoError(t, rdb.Set(context.Background(), testOrder.OrderUID, "foreign", 0).Err())
+	// foreign keys under our prefix which look like versions
+	for _, k := range []string{"wb:vendor:1", "wb:visits", "wb:v1x:test", "wb:v2"} {
+		require.NoError(t, rdb.Set(context.Background(), k, "foreign", 0).Err())
+	}
+
+	oldStorage.Add(context.Background(), &testOrder)
+	localStorage.Add(context.Background(), &testOrder)
+
+	t.Run("dry run doesn't delete", func(t *testing.T) {
+		n, err := localStorage.Purge(context.Background(), false, true)
+		require.NoError(t, err)
+		require.Equal(t, 2, n)
+		require.NotNil(t, oldStorage.Find(context.Background(), testOrder.OrderUID))
+	})
+
+	t.Run("stale keys only", func(t *testing.T) {
+		n, err := localStorage.Purge(context.Background(), true, false)
+		require.NoError(t, err)
+		require.Equal(t, 1, n)
+		require.Nil(t, oldStorage.Find(context.Background(), testOrder.OrderUID))
+		require.NotNil(t, localStorage.Find(context.Background(), testOrder.OrderUID))
+	})
+
+	t.Run("all our keys", func(t *testing.T) {
+		n, err := localStorage.Purge(context.Background(), false, false)
+		require.NoError(t, err)
+		require.Equal(t, 1, n)
+		require.Nil(t, localStorage.Find(context.Background(), testOrder.OrderUID))
+
+		for _, k := range []string{testOrder.OrderUID, "wb:vendor:1", "wb:visits", "wb:v1x:test", "wb:v2"} {
+			foreign, err := rdb.Get(context.Background(), k).Result()
+			require.NoError(t, err)
+			require.Equal(t, "foreign", foreign)
 		}
 	})
 }
+
+func TestRedisBulkLoad(t *testing.T) {
+	t.Parallel()
+	redisContainer := SetupTestRedis(t)
+	defer redisContainer.Terminate(context.Background())
+
+	host, err := redisContainer.Host(context.Background())
+	require.NoError(t, err)
+	port, err := redisContainer.MappedPort(context.Background(), RedisMapped)
+	require.NoError(t, err)
+
+	localStorage := redisStorage.NewRedisStorage(config.RedisConfig{
+		Host:          host,
+		Port:          port.Port(),
+		KeyPrefix:     "bulk",
+		TTL:           time.Hour,
+		TTLJitter:     time.Minute * 10,
+		LoadChunkSize: 100,
+	})
+	defer localStorage.Shutdown()
+
+	ords := make([]*order.Order, 0, 1050)
+	for i := 0; i < cap(ords); i++ {
+		ord := testOrder
+		ord.OrderUID = fmt.Sprintf("bulk%d", i)
+		ords = append(ords, &ord)
+	}
+	// nil rows from db are skipped
+	ords = append(ords, nil)
+
+	require.NoError(t, localStorage.LoadInitialCache(context.Background(), ords))
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: fmt.Sprintf("%s:%s", host, port.Port()),
+	})
+	defer rdb.Close()
+
+	ttls := make(map[time.Duration]struct{})
+	for _, ord := range ords[:len(ords)-1] {
+		require.Equal(t, ord, localStorage.Find(context.Background(), ord.OrderUID))
+
+		ttl, err := rdb.TTL(context.Background(), "bulk:v0:"+ord.OrderUID).Result()
+		require.NoError(t, err)
+		require.GreaterOrEqual(t, ttl, time.Hour-time.Minute)
+		require.LessOrEqual(t, ttl, time.Hour+time.Minute*10)
+		ttls[ttl.Truncate(time.Second)] = struct{}{}
+	}
+	require.Greater(t, len(ttls), 1, "ttl of loaded keys isn't jittered")
+}
+
+func checkAddFindDelete(t *testing.T, localStorage *redisStorage.RedisStorage, ord *order.Order) {
+	localStorage.Add(context.Background(), ord)
+	dataOrder := localStorage.Find(context.Background(), ord.OrderUID)
+	require.Equal(t, ord, dataOrder)
+	localStorage.Delete(context.Background(), ord.OrderUID)
+	dataOrder = localStorage.Find(context.Background(), ord.OrderUID)
+	if dataOrder != nil {
+		t.Error("order didn't deleted from cache")
+	}
+}
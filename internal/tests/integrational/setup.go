@@ -14,6 +14,7 @@ import (
 	"github.com/pressly/goose/v3"
 	"github.com/stretchr/testify/require"
 	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/network"
 	"github.com/testcontainers/testcontainers-go/wait"
 )
 
@@ -30,7 +31,7 @@ const (
 	KafkaMapped = "9092"
 )
 
-func SetupTestDB(t *testing.T) testcontainers.Container {
+func SetupTestDB(t testing.TB) testcontainers.Container {
 	ctx := context.Background()
 
 	req := testcontainers.ContainerRequest{
@@ -70,7 +71,7 @@ func SetupTestDB(t *testing.T) testcontainers.Container {
 	return pgContainer
 }
 
-func applyMigrations(t *testing.T, db *sql.DB) {
+func applyMigrations(t testing.TB, db *sql.DB) {
 	_, filename, _, _ := runtime.Caller(0)
 	migrationsDir := filepath.Join(filepath.Dir(filename), "../../../migrations")
 
@@ -157,3 +158,95 @@ func SetupTestKafka(t *testing.T) testcontainers.Container {
 
 	return kafkaContainer
 }
+
+const (
+	RedisMasterName   = "mymaster"
+	RedisSentinelPort = "26379"
+)
+
+// master and one sentinel on a shared network, sentinel announces master ip
+func SetupTestRedisSentinel(t *testing.T) (master, sentinel testcontainers.Container) {
+	ctx := context.Background()
+
+	nw, err := network.New(ctx)
+	require.NoError(t, err)
+	t.Cleanup(func() { nw.Remove(context.Background()) })
+
+	master, err = testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        "redis:7.2-alpine",
+			ExposedPorts: []string{fmt.Sprintf("%s/tcp", RedisMapped)},
+			Networks:     []string{nw.Name},
+			WaitingFor:   wait.ForLog("Ready to accept connections"),
+		},
+		Started: true,
+	})
+	require.NoError(t, err)
+
+	masterIP, err := master.ContainerIP(ctx)
+	require.NoError(t, err)
+
+	sentinelConf := fmt.Sprintf(
+		"port %s\nsentinel monitor %s %s %s 1\n"+
+			"sentinel down-after-milliseconds %s 1000\n",
+		RedisSentinelPort, RedisMasterName, masterIP, RedisMapped,
+		RedisMasterName,
+	)
+	sentinel, err = testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        "redis:7.2-alpine",
+			ExposedPorts: []string{fmt.Sprintf("%s/tcp", RedisSentinelPort)},
+			Networks:     []string{nw.Name},
+			Entrypoint:   []string{"sh", "-c"},
+			Cmd: []string{fmt.Sprintf(
+				"printf '%s' > /tmp/sentinel.conf && redis-sentinel /tmp/sentinel.conf",
+				sentinelConf,
+			)},
+			WaitingFor: wait.ForLog("+monitor master"),
+		},
+		Started: true,
+	})
+	require.NoError(t, err)
+
+	return master, sentinel
+}
+
+var RedisClusterPorts = []string{"7000", "7001", "7002", "7003", "7004", "7005"}
+
+// six nodes (3 masters, 3 replicas) in one container, nodes announce 0.0.0.0
+// so the client on host can follow MOVED redirects
+func SetupTestRedisCluster(t *testing.T) testcontainers.Container {
+	ctx := context.Background()
+
+	exposed := make([]string, 0, len(RedisClusterPorts))
+	bindings := nat.PortMap{}
+	for _, p := range RedisClusterPorts {
+		exposed = append(exposed, fmt.Sprintf("%s/tcp", p))
+		bindings[nat.Port(fmt.Sprintf("%s/tcp", p))] = []nat.PortBinding{
+			{HostIP: "0.0.0.0", HostPort: p},
+		}
+	}
+
+	clusterContainer, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        "grokzen/redis-cluster:7.0.10",
+			ExposedPorts: exposed,
+			Env: map[string]string{
+				"IP":                "0.0.0.0",
+				"INITIAL_PORT":      RedisClusterPorts[0],
+				"MASTERS":           "3",
+				"SLAVES_PER_MASTER": "1",
+			},
+			HostConfigModifier: func(hc *container.HostConfig) {
+				hc.PortBindings = bindings
+			},
+			WaitingFor: wait.ForLog("Cluster state changed: ok"),
+		},
+		Started: true,
+	})
+	require.NoError(t, err)
+
+	time.Sleep(time.Second * 3)
+
+	return clusterContainer
+}
@@ -0,0 +1,53 @@
+package integrational
+
+import (
+	"context"
+	"database/sql"
+	"first-task/internal/config"
+	"first-task/internal/storage"
+	mapcache "first-task/internal/storage/MAPCache"
+	"first-task/internal/storage/sqlite"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// storage with embedded db and in-memory cache, runs without docker
+func TestStorageSQLite(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	db := sqlite.NewSQLite(config.SQLiteConfig{Path: path, BusyTimeout: time.Second})
+
+	conn, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	_, err = conn.Exec(MockItemRow)
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+
+	str := storage.NewStorage(mapcache.NewMapStorage(), db, config.TimeoutsConfig{
+		DBRead: time.Second, DBWrite: time.Second, Cache: time.Second,
+	})
+	defer str.Shutdown()
+
+	t.Run("create and find order", func(t *testing.T) {
+		require.NoError(t, str.AddOrder(context.Background(), &testOrder))
+
+		fromDB, err := db.Find(context.Background(), testOrder.OrderUID)
+		require.NoError(t, err)
+		require.Equal(t, &testOrder, fromDB)
+
+		found, err := str.FindOrder(context.Background(), testOrder.OrderUID)
+		require.NoError(t, err)
+		require.Equal(t, &testOrder, found)
+	})
+
+	t.Run("load initial data", func(t *testing.T) {
+		require.NoError(t, str.LoadInitialData(context.Background(), 10))
+
+		_, err := str.FindOrder(context.Background(), "unknown")
+		require.ErrorIs(t, err, storage.ErrNotFound)
+	})
+}
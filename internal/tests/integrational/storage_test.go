@@ -7,8 +7,10 @@ import (
 	item "first-task/internal/entities/Item"
 	order "first-task/internal/entities/Order"
 	payment "first-task/internal/entities/Payment"
+	"first-task/internal/storage"
 	"first-task/internal/storage/postgres"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -29,7 +31,7 @@ func TestPostgres(t *testing.T) {
 		User:     DBUser,
 		Password: DBPassword,
 		DBName:   DBName,
-		SSLMode:  false,
+		SSLMode:  "disable",
 	})
 	defer str.Shutdown()
 
@@ -84,19 +86,91 @@ func TestPostgres(t *testing.T) {
 	}
 
 	t.Run("create and find order", func(t *testing.T) {
-		err := str.Add(testOrder)
+		err := str.Add(context.Background(), testOrder)
 		require.NoError(t, err)
 
-		fromDB, err := str.Find(testOrder.OrderUID)
+		fromDB, err := str.Find(context.Background(), testOrder.OrderUID)
 		require.NoError(t, err)
 
 		require.Equal(t, testOrder, fromDB)
 	})
 
 	t.Run("load initial data", func(t *testing.T) {
-		initialData, err := str.GetInitialData(100)
+		initialData, err := str.GetInitialData(context.Background(), 100)
 		require.NoError(t, err)
 		require.Equal(t, 1, len(initialData))
 		require.Equal(t, initialData[0], testOrder)
 	})
 }
+
+func TestPostgresReplicas(t *testing.T) {
+	t.Parallel()
+	primaryContainer := SetupTestDB(t)
+	defer primaryContainer.Terminate(context.Background())
+	// independent db plays replica, so it's seen which one answered
+	replicaContainer := SetupTestDB(t)
+	defer replicaContainer.Terminate(context.Background())
+
+	primaryHost, err := primaryContainer.Host(context.Background())
+	require.NoError(t, err)
+	primaryPort, err := primaryContainer.MappedPort(context.Background(), DBMapped)
+	require.NoError(t, err)
+	replicaHost, err := replicaContainer.Host(context.Background())
+	require.NoError(t, err)
+	replicaPort, err := replicaContainer.MappedPort(context.Background(), DBMapped)
+	require.NoError(t, err)
+
+	cfg := config.PostgresConfig{
+		Host:     replicaHost,
+		Port:     replicaPort.Port(),
+		User:     DBUser,
+		Password: DBPassword,
+		DBName:   DBName,
+		SSLMode:  "disable",
+	}
+	onlyReplica := postgres.NewPostgres(cfg)
+	defer onlyReplica.Shutdown()
+
+	ord := testOrder
+	ord.OrderUID = "replicaonly"
+	ord.Payment.RequestID = "replicaonly"
+	require.NoError(t, onlyReplica.Add(context.Background(), &ord))
+
+	cfg.Host, cfg.Port = primaryHost, primaryPort.Port()
+	cfg.Replicas = []config.ReplicaConfig{
+		{Host: replicaHost, Port: replicaPort.Port()},
+	}
+	cfg.ReplicaMaxLag = time.Second * 10
+	cfg.ReplicaCheckInterval = time.Hour
+	str := postgres.NewPostgres(cfg)
+	defer str.Shutdown()
+
+	t.Run("reads go to replica", func(t *testing.T) {
+		fromDB, err := str.Find(context.Background(), ord.OrderUID)
+		require.NoError(t, err)
+		require.Equal(t, &ord, fromDB)
+	})
+
+	t.Run("writes go to primary", func(t *testing.T) {
+		require.NoError(t, str.Add(context.Background(), &testOrder))
+		_, err := onlyReplica.Find(context.Background(), testOrder.OrderUID)
+		require.ErrorIs(t, err, storage.ErrNotFound)
+	})
+
+	t.Run("not replayed order is read from primary", func(t *testing.T) {
+		fromDB, err := str.Find(context.Background(), testOrder.OrderUID)
+		require.NoError(t, err)
+		require.Equal(t, &testOrder, fromDB)
+
+		_, err = str.Find(context.Background(), "nosuchorder")
+		require.ErrorIs(t, err, storage.ErrNotFound)
+	})
+
+	t.Run("fallback to primary when replica is down", func(t *testing.T) {
+		require.NoError(t, replicaContainer.Stop(context.Background(), nil))
+
+		fromDB, err := str.Find(context.Background(), testOrder.OrderUID)
+		require.NoError(t, err)
+		require.Equal(t, &testOrder, fromDB)
+	})
+}
@@ -0,0 +1,75 @@
+package tracing
+
+import (
+	"context"
+	"first-task/internal/config"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestKafkaPropagation(t *testing.T) {
+	NewProvider(config.TracingConfig{Exporter: ExporterNone}).Shutdown(context.Background())
+
+	tp := sdktrace.NewTracerProvider()
+	ctx, span := tp.Tracer("test").Start(context.Background(), "produce")
+	defer span.End()
+
+	msg := kafka.Message{Headers: []kafka.Header{
+		{Key: "traceparent", Value: []byte("old")},
+		{Key: "other", Value: []byte("value")},
+	}}
+	InjectKafka(ctx, &msg)
+
+	if len(msg.Headers) != 2 {
+		t.Errorf("traceparent isn't replaced: %v", msg.Headers)
+	}
+
+	got := trace.SpanContextFromContext(ExtractKafka(context.Background(), msg))
+	if got.TraceID() != span.SpanContext().TraceID() || got.SpanID() != span.SpanContext().SpanID() {
+		t.Errorf("wrong trace context\nwait: %s\nget: %s", span.SpanContext().TraceID(), got.TraceID())
+	}
+	if !got.IsRemote() {
+		t.Error("extracted context isn't remote")
+	}
+
+	// message without headers starts new trace
+	if sc := trace.SpanContextFromContext(ExtractKafka(context.Background(), kafka.Message{})); sc.IsValid() {
+		t.Error("trace context from message without headers")
+	}
+}
+
+func TestCarrier(t *testing.T) {
+	NewProvider(config.TracingConfig{Exporter: ExporterNone})
+
+	tp := sdktrace.NewTracerProvider()
+	ctx, span := tp.Tracer("test").Start(context.Background(), "save")
+	defer span.End()
+
+	got := trace.SpanContextFromContext(FromCarrier(context.Background(), Carrier(ctx)))
+	if got.TraceID() != span.SpanContext().TraceID() || got.SpanID() != span.SpanContext().SpanID() {
+		t.Errorf("wrong trace context\nwait: %s\nget: %s", span.SpanContext().TraceID(), got.TraceID())
+	}
+	if len(Carrier(context.Background())) != 0 {
+		t.Error("carrier of ctx without span isn't empty")
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	for _, exp := range []string{"", ExporterNone, ExporterStdout, ExporterOTLP} {
+		p := NewProvider(config.TracingConfig{Exporter: exp, Endpoint: "localhost:4318", SampleRatio: 1})
+		if (p.tp != nil) != (exp == ExporterStdout || exp == ExporterOTLP) {
+			t.Errorf("%q: wrong provider", exp)
+		}
+		p.Shutdown(context.Background())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("unknown exporter doesn't panic")
+		}
+	}()
+	NewProvider(config.TracingConfig{Exporter: "jaeger"})
+}
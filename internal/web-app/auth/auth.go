@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"first-task/internal/config"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	ScopeOrdersRead  = "orders:read"
+	ScopeOrdersWrite = "orders:write"
+	// admin has every scope
+	ScopeAdmin = "admin"
+)
+
+const APIKeyHeader = "X-API-Key"
+
+var (
+	ErrNoCredentials  = errors.New("no credentials")
+	ErrBadCredentials = errors.New("bad credentials")
+)
+
+// Principal is authenticated client of api
+type Principal struct {
+	// "key:<name>" or "jwt:<sub>"
+	Subject string
+	Scopes  []string
+}
+
+func (p *Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope) || slices.Contains(p.Scopes, ScopeAdmin)
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+type Authenticator struct {
+	// by hex of sha256 of key
+	keys map[string]*Principal
+	jwks *JWKS
+
+	parser     *jwt.Parser
+	scopeClaim string
+}
+
+// returns nil if neither api keys nor jwks file are set, if config is wrong
+// throw panic
+func NewAuthenticator(ca config.AuthConfig, adminToken string) *Authenticator {
+	a, err := New(ca, adminToken)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+func New(ca config.AuthConfig, adminToken string) (*Authenticator, error) {
+	const op = "internal.web-app.auth.New"
+
+	if len(ca.APIKeys) == 0 && ca.JWKSFile == "" && adminToken == "" {
+		return nil, nil
+	}
+
+	a := &Authenticator{
+		keys:       make(map[string]*Principal, len(ca.APIKeys)+1),
+		scopeClaim: ca.ScopeClaim,
+	}
+	for _, k := range ca.APIKeys {
+		hash := strings.ToLower(k.Hash)
+		if raw, err := hex.DecodeString(hash); err != nil || len(raw) != sha256.Size {
+			return nil, fmt.Errorf("%s: api key %s: hash isn't hex of sha256", op, k.Name)
+		}
+		a.keys[hash] = &Principal{Subject: "key:" + k.Name, Scopes: k.Scopes}
+	}
+	if adminToken != "" {
+		a.keys[HashKey(adminToken)] = &Principal{
+			Subject: "key:admin_token", Scopes: []string{ScopeAdmin},
+		}
+	}
+
+	if ca.JWKSFile != "" {
+		jwks, err := LoadJWKS(ca.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		a.jwks = jwks
+
+		opts := []jwt.ParserOption{
+			jwt.WithValidMethods(jwks.Methods()),
+			jwt.WithLeeway(ca.Leeway),
+			jwt.WithExpirationRequired(),
+		}
+		if ca.Issuer != "" {
+			opts = append(opts, jwt.WithIssuer(ca.Issuer))
+		}
+		if ca.Audience != "" {
+			opts = append(opts, jwt.WithAudience(ca.Audience))
+		}
+		a.parser = jwt.NewParser(opts...)
+	}
+
+	return a, nil
+}
+
+// HashKey returns value of api_keys.hash for key
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Authenticate checks X-API-Key header or bearer token, bearer token is
+// api key or jwt
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return a.apiKey(key)
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return nil, ErrNoCredentials
+	}
+	if strings.Count(token, ".") == 2 {
+		return a.jwt(token)
+	}
+	return a.apiKey(token)
+}
+
+func (a *Authenticator) apiKey(key string) (*Principal, error) {
+	p, ok := a.keys[HashKey(key)]
+	if !ok {
+		return nil, ErrBadCredentials
+	}
+	return p, nil
+}
+
+func (a *Authenticator) jwt(token string) (*Principal, error) {
+	if a.parser == nil {
+		return nil, ErrBadCredentials
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := a.parser.ParseWithClaims(token, claims, a.jwks.Keyfunc)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrBadCredentials, err)
+	}
+
+	sub, _ := claims.GetSubject()
+	return &Principal{Subject: "jwt:" + sub, Scopes: scopes(claims[a.scopeClaim])}, nil
+}
+
+// scopes claim is "a b c" by rfc 8693 or ["a", "b"] by some issuers
+func scopes(claim any) []string {
+	switch v := claim.(type) {
+	case string:
+		return strings.Fields(v)
+	case []any:
+		res := make([]string, 0, len(v))
+		for _, s := range v {
+			if s, ok := s.(string); ok {
+				res = append(res, s)
+			}
+		}
+		return res
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"first-task/internal/config"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type testKeys struct {
+	rsa *rsa.PrivateKey
+	ec  *ecdsa.PrivateKey
+	ed  ed25519.PrivateKey
+}
+
+func newTestKeys(t *testing.T) *testKeys {
+	t.Helper()
+	rk, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ek, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, dk, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testKeys{rsa: rk, ec: ek, ed: dk}
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func (tk *testKeys) writeJWKS(t *testing.T) string {
+	t.Helper()
+	set := map[string][]map[string]string{"keys": {
+		{
+			"kty": "RSA", "kid": "rsa", "use": "sig",
+			"n": b64(tk.rsa.N.Bytes()), "e": b64(big.NewInt(int64(tk.rsa.E)).Bytes()),
+		},
+		{
+			"kty": "EC", "kid": "ec", "crv": "P-256",
+			"x": b64(tk.ec.X.FillBytes(make([]byte, 32))), "y": b64(tk.ec.Y.FillBytes(make([]byte, 32))),
+		},
+		{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": b64(tk.ed.Public().(ed25519.PublicKey))},
+		// encryption keys are skipped
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": "AQAB", "e": "AQAB"},
+	}}
+
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func sign(t *testing.T, m jwt.SigningMethod, kid string, key any, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(m, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestAPIKeys(t *testing.T) {
+	a, err := New(config.AuthConfig{APIKeys: []config.APIKeyConfig{
+		{Name: "reader", Hash: HashKey("secret"), Scopes: []string{ScopeOrdersRead}},
+	}}, "admin-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	if _, err := a.Authenticate(r); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("wrong error without credentials: %v", err)
+	}
+
+	r.Header.Set(APIKeyHeader, "secret")
+	p, err := a.Authenticate(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Subject != "key:reader" || !p.HasScope(ScopeOrdersRead) || p.HasScope(ScopeAdmin) {
+		t.Errorf("wrong principal %+v", p)
+	}
+
+	r = httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Authorization", "Bearer admin-secret")
+	if p, err := a.Authenticate(r); err != nil || !p.HasScope(ScopeOrdersWrite) {
+		t.Errorf("admin token hasn't every scope: %+v, %v", p, err)
+	}
+
+	r.Header.Set("Authorization", "Bearer wrong")
+	if _, err := a.Authenticate(r); !errors.Is(err, ErrBadCredentials) {
+		t.Errorf("wrong key is accepted: %v", err)
+	}
+
+	if _, err := New(config.AuthConfig{APIKeys: []config.APIKeyConfig{{Name: "bad", Hash: "secret"}}}, ""); err == nil {
+		t.Error("not hashed key is accepted")
+	}
+	if a, err := New(config.AuthConfig{}, ""); a != nil || err != nil {
+		t.Errorf("authenticator without keys: %v, %v", a, err)
+	}
+}
+
+func TestJWT(t *testing.T) {
+	tk := newTestKeys(t)
+	a, err := New(config.AuthConfig{
+		JWKSFile:   tk.writeJWKS(t),
+		Issuer:     "issuer",
+		Audience:   "orders",
+		ScopeClaim: "scope",
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := func(mod func(jwt.MapClaims)) jwt.MapClaims {
+		c := jwt.MapClaims{
+			"sub": "user", "iss": "issuer", "aud": "orders",
+			"exp": time.Now().Add(time.Hour).Unix(), "scope": "orders:read orders:write",
+		}
+		if mod != nil {
+			mod(c)
+		}
+		return c
+	}
+
+	tests := []struct {
+		Name   string
+		Token  string
+		Scopes []string
+	}{
+		{"rsa", sign(t, jwt.SigningMethodRS256, "rsa", tk.rsa, claims(nil)), []string{"orders:read", "orders:write"}},
+		{"ec", sign(t, jwt.SigningMethodES256, "ec", tk.ec, claims(nil)), []string{"orders:read", "orders:write"}},
+		{"ed25519", sign(t, jwt.SigningMethodEdDSA, "ed", tk.ed, claims(func(c jwt.MapClaims) {
+			c["scope"] = []string{"admin"}
+		})), []string{"admin"}},
+		{"expired", sign(t, jwt.SigningMethodRS256, "rsa", tk.rsa, claims(func(c jwt.MapClaims) {
+			c["exp"] = time.Now().Add(-time.Hour).Unix()
+		})), nil},
+		{"without exp", sign(t, jwt.SigningMethodRS256, "rsa", tk.rsa, claims(func(c jwt.MapClaims) {
+			delete(c, "exp")
+		})), nil},
+		{"wrong issuer", sign(t, jwt.SigningMethodRS256, "rsa", tk.rsa, claims(func(c jwt.MapClaims) {
+			c["iss"] = "other"
+		})), nil},
+		{"wrong audience", sign(t, jwt.SigningMethodRS256, "rsa", tk.rsa, claims(func(c jwt.MapClaims) {
+			c["aud"] = "other"
+		})), nil},
+		{"unknown kid", sign(t, jwt.SigningMethodRS256, "other", tk.rsa, claims(nil)), nil},
+		{"key of other kid", sign(t, jwt.SigningMethodRS256, "ec", tk.rsa, claims(nil)), nil},
+		// public key can't be used as hmac secret
+		{"hs256", sign(t, jwt.SigningMethodHS256, "rsa", tk.rsa.N.Bytes(), claims(nil)), nil},
+	}
+
+	for _, v := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Authorization", "Bearer "+v.Token)
+		p, err := a.Authenticate(r)
+		if v.Scopes == nil {
+			if !errors.Is(err, ErrBadCredentials) {
+				t.Errorf("%s: token is accepted: %v", v.Name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", v.Name, err.Error())
+			continue
+		}
+		if p.Subject != "jwt:user" || !slices.Equal(p.Scopes, v.Scopes) {
+			t.Errorf("%s: wrong principal %+v", v.Name, p)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrUnknownKey = errors.New("unknown key id")
+
+// JWKS is set of public keys by kid, only asymmetric keys are accepted,
+// so token can't be signed by hs256 with public key as secret
+type JWKS struct {
+	keys map[string]crypto.PublicKey
+	// single key of file is used for tokens without kid
+	single crypto.PublicKey
+	// signing methods of loaded key types
+	methods []string
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func LoadJWKS(path string) (*JWKS, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseJWKS(data)
+}
+
+func ParseJWKS(data []byte) (*JWKS, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	j := &JWKS{keys: make(map[string]crypto.PublicKey, len(set.Keys))}
+	methods := make(map[string]bool)
+	for i, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, algs, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("key %d (%s): %w", i, k.Kid, err)
+		}
+		j.keys[k.Kid] = pub
+		for _, a := range algs {
+			methods[a] = true
+		}
+	}
+	if len(j.keys) == 0 {
+		return nil, errors.New("no signing keys in jwks")
+	}
+	if len(j.keys) == 1 {
+		for _, pub := range j.keys {
+			j.single = pub
+		}
+	}
+	for m := range methods {
+		j.methods = append(j.methods, m)
+	}
+
+	return j, nil
+}
+
+func (j *JWKS) Methods() []string {
+	return j.methods
+}
+
+func (j *JWKS) Keyfunc(t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+	if kid == "" && j.single != nil {
+		return j.single, nil
+	}
+	pub, ok := j.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, kid)
+	}
+	return pub, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, []string, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, nil, err
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !e.IsInt64() {
+			return nil, nil, errors.New("wrong rsa exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())},
+			[]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512"}, nil
+
+	case "EC":
+		curves := map[string]struct {
+			curve elliptic.Curve
+			alg   string
+		}{
+			"P-256": {elliptic.P256(), "ES256"},
+			"P-384": {elliptic.P384(), "ES384"},
+			"P-521": {elliptic.P521(), "ES512"},
+		}
+		c, ok := curves[k.Crv]
+		if !ok {
+			return nil, nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, nil, err
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !c.curve.IsOnCurve(x, y) {
+			return nil, nil, errors.New("point isn't on curve")
+		}
+		return &ecdsa.PublicKey{Curve: c.curve, X: x, Y: y}, []string{c.alg}, nil
+
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, nil, errors.New("wrong ed25519 key")
+		}
+		return ed25519.PublicKey(x), []string{"EdDSA"}, nil
+	}
+
+	return nil, nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("wrong base64url number")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
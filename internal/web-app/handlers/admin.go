@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"first-task/internal/storage"
+	"first-task/internal/web-app/auth"
+	"fmt"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+type OrderEraser interface {
+	DeleteOrder(ctx context.Context, orderUID string) error
+	EraseCustomer(
+		ctx context.Context, customerID, mode, actor, reason string,
+	) (*storage.Erasure, error)
+}
+
+type EraseRequest struct {
+	// delete or anonymize
+	Mode   string `json:"mode"`
+	Reason string `json:"reason"`
+}
+
+// added to subject of authenticated client in audit record
+const ActorHeader = "X-Actor"
+
+func writeError(w http.ResponseWriter, code int, status string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(ErrorResponse{
+		Status: status,
+		Code:   code,
+	})
+}
+
+func actor(r *http.Request) string {
+	a := "anonymous " + r.RemoteAddr
+	if p, ok := auth.FromContext(r.Context()); ok {
+		a = p.Subject
+	}
+	if h := r.Header.Get(ActorHeader); h != "" {
+		a += " for " + h
+	}
+	return a
+}
+
+// @Summary DeleteOrderAPI
+// @Tags Admin
+// @Description delete order with delivery, payment and items
+// @Security BearerAuth
+// @Param order_uid path string true "Уникальный номер заказа"
+// @Success 204 "Заказ удален"
+// @Failure 401 {object} ErrorResponse "Нет ключа или токена"
+// @Failure 403 {object} ErrorResponse "Нет scope admin"
+// @Failure 404 {object} ErrorResponse "Заказ не найден"
+// @Failure 429 {object} ErrorResponse "Слишком много запросов"
+// @Failure 500 {object} ErrorResponse "Ошибка сервера"
+// @Router /admin/orders/{order_uid} [delete]
+func DeleteOrderAPI(str OrderEraser) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.web-app.handlers.DeleteOrderAPI"
+
+		orderUID := r.PathValue("order_uid")
+		err := str.DeleteOrder(r.Context(), orderUID)
+		if errors.Is(err, storage.ErrNotFound) {
+			writeError(w, http.StatusNotFound, StatusNotFound)
+			return
+		} else if errors.Is(err, context.Canceled) {
+			return
+		} else if err != nil {
+			zap.L().Error(fmt.Sprintf("%s: %s", op, err.Error()))
+			writeError(w, http.StatusInternalServerError, StatusInternalServerError)
+			return
+		}
+
+		zap.L().Info(fmt.Sprintf("order %s is deleted by %s", orderUID, actor(r)))
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// @Summary EraseCustomerAPI
+// @Tags Admin
+// @Description delete or anonymize all orders of customer, returns audit record
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param customer_id path string true "Идентификатор покупателя"
+// @Param request body EraseRequest true "Режим удаления"
+// @Success 200 {object} storage.Erasure "Данные удалены"
+// @Failure 400 {object} ErrorResponse "Неверный режим"
+// @Failure 401 {object} ErrorResponse "Нет ключа или токена"
+// @Failure 403 {object} ErrorResponse "Нет scope admin"
+// @Failure 429 {object} ErrorResponse "Слишком много запросов"
+// @Failure 500 {object} ErrorResponse "Ошибка сервера"
+// @Router /admin/customers/{customer_id}/erase [post]
+func EraseCustomerAPI(str OrderEraser) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "internal.web-app.handlers.EraseCustomerAPI"
+
+		var req EraseRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, StatusBadRequest)
+			return
+		}
+
+		er, err := str.EraseCustomer(
+			r.Context(), r.PathValue("customer_id"), req.Mode, actor(r), req.Reason,
+		)
+		if errors.Is(err, storage.ErrUnknownErasureMode) {
+			writeError(w, http.StatusBadRequest, StatusBadRequest)
+			return
+		} else if errors.Is(err, context.Canceled) {
+			return
+		} else if err != nil {
+			zap.L().Error(fmt.Sprintf("%s: %s", op, err.Error()))
+			writeError(w, http.StatusInternalServerError, StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(er)
+	}
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"first-task/internal/config"
+	"first-task/internal/storage"
+	"first-task/internal/web-app/auth"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const adminToken = "secret"
+
+type EraserMock struct {
+	actor string
+}
+
+func (em *EraserMock) DeleteOrder(_ context.Context, orderUID string) error {
+	switch orderUID {
+	case "found":
+		return nil
+	case "not_found":
+		return storage.ErrNotFound
+	}
+	return errors.New("test error from db")
+}
+
+func (em *EraserMock) EraseCustomer(
+	_ context.Context, customerID, mode, actor, reason string,
+) (*storage.Erasure, error) {
+	if mode != storage.ErasureDelete && mode != storage.ErasureAnonymize {
+		return nil, storage.ErrUnknownErasureMode
+	}
+	if customerID == "wrong_answer" {
+		return nil, errors.New("test error from db")
+	}
+	em.actor = actor
+	return &storage.Erasure{
+		ID: 1, CustomerID: customerID, Mode: mode, Actor: actor, Reason: reason,
+		OrderUIDs: []string{"found"},
+	}, nil
+}
+
+const readerKey = "reader-key"
+
+func testAuth() *auth.Authenticator {
+	a, err := auth.New(config.AuthConfig{
+		APIKeys: []config.APIKeyConfig{
+			{Name: "reader", Hash: auth.HashKey(readerKey), Scopes: []string{auth.ScopeOrdersRead}},
+		},
+	}, adminToken)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+func newAdminServer(em *EraserMock) *httptest.Server {
+	a := testAuth()
+	r := http.NewServeMux()
+	r.HandleFunc(
+		"DELETE /admin/orders/{order_uid}",
+		RequireScope(a, auth.ScopeAdmin, DeleteOrderAPI(em)),
+	)
+	r.HandleFunc(
+		"POST /admin/customers/{customer_id}/erase",
+		RequireScope(a, auth.ScopeAdmin, EraseCustomerAPI(em)),
+	)
+	return httptest.NewServer(r)
+}
+
+func adminRequest(t *testing.T, method, url, token, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	req.Header.Set(ActorHeader, "tester")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestDeleteOrderAPI(t *testing.T) {
+	srv := newAdminServer(&EraserMock{})
+	defer srv.Close()
+
+	tests := []struct {
+		Arg   string
+		Token string
+		Code  int
+	}{
+		{Arg: "found", Token: adminToken, Code: http.StatusNoContent},
+		{Arg: "not_found", Token: adminToken, Code: http.StatusNotFound},
+		{Arg: "wrong_answer", Token: adminToken, Code: http.StatusInternalServerError},
+		{Arg: "found", Token: "", Code: http.StatusUnauthorized},
+		{Arg: "found", Token: "wrong", Code: http.StatusUnauthorized},
+		{Arg: "found", Token: readerKey, Code: http.StatusForbidden},
+	}
+
+	for _, v := range tests {
+		resp := adminRequest(
+			t, http.MethodDelete, fmt.Sprintf("%s/admin/orders/%s", srv.URL, v.Arg), v.Token, "",
+		)
+		resp.Body.Close()
+		if resp.StatusCode != v.Code {
+			t.Errorf(
+				"wrong response \nget: %d\nwait: %d\nfrom: %s token %q",
+				resp.StatusCode, v.Code, v.Arg, v.Token,
+			)
+		}
+	}
+}
+
+func TestEraseCustomerAPI(t *testing.T) {
+	em := &EraserMock{}
+	srv := newAdminServer(em)
+	defer srv.Close()
+
+	tests := []struct {
+		Arg  string
+		Body string
+		Code int
+	}{
+		{Arg: "test", Body: `{"mode": "anonymize", "reason": "gdpr"}`, Code: http.StatusOK},
+		{Arg: "test", Body: `{"mode": "forget"}`, Code: http.StatusBadRequest},
+		{Arg: "test", Body: `not json`, Code: http.StatusBadRequest},
+		{Arg: "wrong_answer", Body: `{"mode": "delete"}`, Code: http.StatusInternalServerError},
+	}
+
+	for _, v := range tests {
+		resp := adminRequest(
+			t, http.MethodPost, fmt.Sprintf("%s/admin/customers/%s/erase", srv.URL, v.Arg),
+			adminToken, v.Body,
+		)
+		if resp.StatusCode != v.Code {
+			t.Errorf(
+				"wrong response \nget: %d\nwait: %d\nfrom: %s %s",
+				resp.StatusCode, v.Code, v.Arg, v.Body,
+			)
+		}
+		if resp.StatusCode == http.StatusOK {
+			var er storage.Erasure
+			if err := json.NewDecoder(resp.Body).Decode(&er); err != nil {
+				t.Error(err)
+			}
+			if er.Mode != storage.ErasureAnonymize || er.Reason != "gdpr" || em.actor != "key:admin_token for tester" {
+				t.Errorf("wrong audit record %+v", er)
+			}
+		}
+		resp.Body.Close()
+	}
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"errors"
+	"first-task/internal/web-app/auth"
+	"net/http"
+)
+
+var StatusUnauthorized = "unauthorized"
+var StatusForbidden = "forbidden"
+
+// RequireScope lets through clients with scope, nil authenticator lets
+// through everyone
+func RequireScope(a *auth.Authenticator, scope string, next http.HandlerFunc) http.HandlerFunc {
+	if a == nil {
+		return next
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		// principal is put by Identify
+		p, ok := auth.FromContext(r.Context())
+		var err error
+		if !ok {
+			p, err = a.Authenticate(r)
+		}
+		if err != nil {
+			if !errors.Is(err, auth.ErrNoCredentials) {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			} else {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+			}
+			writeError(w, http.StatusUnauthorized, StatusUnauthorized)
+			return
+		}
+		if !p.HasScope(scope) {
+			writeError(w, http.StatusForbidden, StatusForbidden)
+			return
+		}
+
+		next(w, r.WithContext(auth.WithPrincipal(r.Context(), p)))
+	}
+}
+
+// Identify puts principal of valid credentials into context and passes
+// other requests as is, so limit before RequireScope counts clients by
+// subject and requests with wrong credentials by ip
+func Identify(a *auth.Authenticator, next http.HandlerFunc) http.HandlerFunc {
+	if a == nil {
+		return next
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if p, err := a.Authenticate(r); err == nil {
+			r = r.WithContext(auth.WithPrincipal(r.Context(), p))
+		}
+		next(w, r)
+	}
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	order "first-task/internal/entities/Order"
@@ -14,7 +15,7 @@ import (
 )
 
 type OrderGetter interface {
-	FindOrder(orderUID string) (*order.Order, error)
+	FindOrder(ctx context.Context, orderUID string) (*order.Order, error)
 }
 
 type ErrorResponse struct {
@@ -41,24 +42,27 @@ func MainPage() http.HandlerFunc {
 	}
 }
 
-func FindOrder(str OrderGetter) http.HandlerFunc {
+func FindOrder(str OrderGetter, pv *Privacy) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "internal.web-app.handlers.HandleFindOrder"
 		
 		r.ParseForm()
 		orderUID := r.FormValue("order_uid")
 
-		ord, err := str.FindOrder(orderUID)
+		ord, err := str.FindOrder(r.Context(), orderUID)
 		if errors.Is(err, storage.ErrNotFound) {
 			NotFoundOrderTmpl(w)
 			return
+		} else if errors.Is(err, context.Canceled) {
+			// client is gone, nobody reads the answer
+			return
 		} else if err != nil {
 			zap.L().Error(fmt.Sprintf("%s: %s", op, err.Error()))
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
 
-		FoundOrderTmpl(w, ord)
+		FoundOrderTmpl(w, pv.Order(r, ord))
 	}
 }
 
@@ -66,21 +70,25 @@ func FindOrder(str OrderGetter) http.HandlerFunc {
 
 // @Summary FindOrderAPI
 // @Tags Order
-// @Description get order
+// @Description get order, customer data is masked without admin scope
+// @Security BearerAuth
 // @Produce json
 // @Param order_uid path string true "Уникальный номер заказа"
 // @Success 200 {object} order.Order "Успешный запрос"
+// @Failure 401 {object} ErrorResponse "Нет ключа или токена"
+// @Failure 403 {object} ErrorResponse "Нет scope orders:read"
 // @Failure 404 {object} ErrorResponse "Заказ не найден"
+// @Failure 429 {object} ErrorResponse "Слишком много запросов"
 // @Failure 500 {object} ErrorResponse "Ошибка сервера"
 // @Router /order/{order_uid} [get]
-func FindOrderAPI(str OrderGetter) http.HandlerFunc {
+func FindOrderAPI(str OrderGetter, pv *Privacy) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "internal.web-app.handlers.HandleOrderPage"
 
 		w.Header().Set("Content-Type", "application/json")
 
 		orderUID := r.PathValue("order_uid")
-		ord, err := str.FindOrder(orderUID)
+		ord, err := str.FindOrder(r.Context(), orderUID)
 		if errors.Is(err, storage.ErrNotFound) {
 			w.WriteHeader(http.StatusNotFound)
 			json.NewEncoder(w).Encode(ErrorResponse{
@@ -88,6 +96,8 @@ func FindOrderAPI(str OrderGetter) http.HandlerFunc {
 				Code:   http.StatusNotFound,
 			})
 			return
+		} else if errors.Is(err, context.Canceled) {
+			return
 		} else if err != nil {
 			zap.L().Error(fmt.Sprintf("%s: %s", op, err.Error()))
 			w.WriteHeader(http.StatusInternalServerError)
@@ -99,7 +109,7 @@ func FindOrderAPI(str OrderGetter) http.HandlerFunc {
 		}
 
 		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(ord)
+		json.NewEncoder(w).Encode(pv.Order(r, ord))
 	}
 
 }
@@ -1,15 +1,21 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"first-task/internal/config"
 	delivery "first-task/internal/entities/Delivery"
 	item "first-task/internal/entities/Item"
 	order "first-task/internal/entities/Order"
 	payment "first-task/internal/entities/Payment"
 	"first-task/internal/storage"
+	"first-task/internal/web-app/auth"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -40,7 +46,7 @@ type TestCase struct {
 
 type StorageMock struct{}
 
-func (sm *StorageMock) FindOrder(orderUID string) (*order.Order, error) {
+func (sm *StorageMock) FindOrder(_ context.Context, orderUID string) (*order.Order, error) {
 	switch orderUID {
 	case "found":
 		return &order.Order{
@@ -117,7 +123,7 @@ func TestOrderPage(t *testing.T) {
 	}
 
 	r := http.NewServeMux()
-	r.HandleFunc("/find-order", FindOrder(&StorageMock{}))
+	r.HandleFunc("/find-order", FindOrder(&StorageMock{}, testPrivacy()))
 
 	srv := httptest.NewServer(r)
 	defer srv.Close()
@@ -157,7 +163,7 @@ func TestOrderAPI(t *testing.T) {
 	}
 
 	r := http.NewServeMux()
-	r.HandleFunc("/order/{order_uid}", FindOrderAPI(&StorageMock{}))
+	r.HandleFunc("/order/{order_uid}", FindOrderAPI(&StorageMock{}, testPrivacy()))
 	
 	srv := httptest.NewServer(r)
 	defer srv.Close()
@@ -180,6 +186,68 @@ func TestOrderAPI(t *testing.T) {
 	}
 }
 
+func testPrivacy() *Privacy {
+	return NewPrivacy(config.PrivacyConfig{
+		SensitiveFields: []string{"name", "phone", "email", "address"},
+		UnmaskedRoles:   []string{RoleAdmin},
+	})
+}
+
+func TestOrderAPIMasking(t *testing.T) {
+	r := http.NewServeMux()
+	r.HandleFunc(
+		"/order/{order_uid}",
+		RequireScope(testAuth(), auth.ScopeOrdersRead, FindOrderAPI(&StorageMock{}, testPrivacy())),
+	)
+	r.HandleFunc("/find-order", FindOrder(&StorageMock{}, testPrivacy()))
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	tests := []struct {
+		Token string
+		Wait  delivery.Delivery
+	}{
+		{
+			Token: readerKey,
+			Wait: delivery.Delivery{
+				Name: "T***", Phone: "+9720***0000", Zip: "2639809", City: "Kiryat Mozkin",
+				Address: "P***", Region: "Kraiot", Email: "t***@gmail.com",
+			},
+		},
+		{
+			Token: adminToken,
+			Wait: delivery.Delivery{
+				Name: "Test Testov", Phone: "+9720000000", Zip: "2639809", City: "Kiryat Mozkin",
+				Address: "Ploshad Mira 15", Region: "Kraiot", Email: "test@gmail.com",
+			},
+		},
+	}
+
+	for _, v := range tests {
+		resp := adminRequest(t, http.MethodGet, srv.URL+"/order/found", v.Token, "")
+		var ord order.Order
+		if err := json.NewDecoder(resp.Body).Decode(&ord); err != nil {
+			t.Error(err)
+		}
+		resp.Body.Close()
+
+		if ord.Delivery != v.Wait {
+			t.Errorf("wrong delivery with token %q\nwait: %+v\nget: %+v", v.Token, v.Wait, ord.Delivery)
+		}
+	}
+
+	resp := adminRequest(t, http.MethodGet, srv.URL+"/find-order?order_uid=found", "", "")
+	defer resp.Body.Close()
+	page := new(strings.Builder)
+	if _, err := io.Copy(page, resp.Body); err != nil {
+		t.Error(err)
+	}
+	if strings.Contains(page.String(), "+9720000000") || strings.Contains(page.String(), "test@gmail.com") {
+		t.Error("page has not masked customer data")
+	}
+}
+
 // func TestFindOrder(t *testing.T) {
 
 // }
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"first-task/internal/health"
+	"net/http"
+)
+
+type ReadinessChecker interface {
+	Check(ctx context.Context) health.Report
+}
+
+type LivenessResponse struct {
+	Status string `json:"status"`
+}
+
+// @Summary Healthz
+// @Tags Health
+// @Description liveness, process answers requests, dependencies aren't checked
+// @Produce json
+// @Success 200 {object} LivenessResponse
+// @Router /healthz [get]
+func Healthz() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(LivenessResponse{Status: "ok"})
+	}
+}
+
+// @Summary Readyz
+// @Tags Health
+// @Description readiness, status and latency of every dependency and cache warmup
+// @Produce json
+// @Success 200 {object} health.Report "Готов принимать трафик"
+// @Failure 503 {object} health.Report "Зависимость недоступна или кеш не прогрет"
+// @Router /readyz [get]
+func Readyz(rc ReadinessChecker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rep := rc.Check(r.Context())
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if rep.Ready {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(rep)
+	}
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"first-task/internal/health"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReadyz(t *testing.T) {
+	var pgErr error
+	hc := health.NewChecker(0)
+	hc.Add("postgres", func(context.Context) error { return pgErr })
+
+	tests := []struct {
+		Name string
+		Err  error
+		Code int
+	}{
+		{Name: "ready", Code: http.StatusOK},
+		{Name: "postgres is down", Err: errors.New("connection refused"), Code: http.StatusServiceUnavailable},
+	}
+
+	for _, v := range tests {
+		pgErr = v.Err
+		w := httptest.NewRecorder()
+		Readyz(hc)(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+		if w.Code != v.Code {
+			t.Errorf("%s: wrong response \nget: %d\nwait: %d", v.Name, w.Code, v.Code)
+		}
+		var rep health.Report
+		if err := json.NewDecoder(w.Body).Decode(&rep); err != nil {
+			t.Fatalf("%s: %s", v.Name, err.Error())
+		}
+		if _, ok := rep.Checks["postgres"]; !ok || rep.Ready != (v.Err == nil) {
+			t.Errorf("%s: wrong report %+v", v.Name, rep)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	Healthz()(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("liveness isn't ok: %d", w.Code)
+	}
+}
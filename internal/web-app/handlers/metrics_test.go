@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"first-task/internal/metrics"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestMetrics(t *testing.T) {
+	const route = "GET /test/{id}"
+	tests := []struct {
+		Name   string
+		H      http.HandlerFunc
+		Status string
+	}{
+		{
+			Name:   "body without header",
+			H:      func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) },
+			Status: "200",
+		},
+		{
+			Name:   "error",
+			H:      func(w http.ResponseWriter, r *http.Request) { writeError(w, http.StatusNotFound, "no") },
+			Status: "404",
+		},
+		{
+			Name: "second header is ignored",
+			H: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+				w.WriteHeader(http.StatusOK)
+			},
+			Status: "418",
+		},
+	}
+
+	for _, v := range tests {
+		before := samples(t, route, v.Status)
+		Metrics(route, v.H)(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/test/1", nil))
+
+		if n := samples(t, route, v.Status); n != before+1 {
+			t.Errorf("%s: request isn't recorded with status %s", v.Name, v.Status)
+		}
+	}
+}
+
+func samples(t *testing.T, route, status string) uint64 {
+	m := &dto.Metric{}
+	h := metrics.HTTPRequestDuration.WithLabelValues(route, status).(prometheus.Metric)
+	if err := h.Write(m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"first-task/internal/config"
+	order "first-task/internal/entities/Order"
+	"first-task/internal/web-app/auth"
+	"first-task/pkg/mask"
+	"net/http"
+)
+
+const (
+	RolePublic = "public"
+	RoleAdmin  = "admin"
+)
+
+// Privacy masks customer data of orders for roles which shouldn't see it
+type Privacy struct {
+	masker   *mask.Masker
+	unmasked map[string]bool
+}
+
+func NewPrivacy(cp config.PrivacyConfig) *Privacy {
+	p := &Privacy{
+		masker:   mask.New(cp.SensitiveFields),
+		unmasked: make(map[string]bool, len(cp.UnmaskedRoles)),
+	}
+	for _, r := range cp.UnmaskedRoles {
+		p.unmasked[r] = true
+	}
+	return p
+}
+
+// Role returns admin for clients with admin scope, public for others
+func (p *Privacy) Role(r *http.Request) string {
+	if pr, ok := auth.FromContext(r.Context()); ok && pr.HasScope(auth.ScopeAdmin) {
+		return RoleAdmin
+	}
+	return RolePublic
+}
+
+// Order returns ord as is for unmasked roles and masked copy for others
+func (p *Privacy) Order(r *http.Request, ord *order.Order) *order.Order {
+	if p.unmasked[p.Role(r)] {
+		return ord
+	}
+
+	masked := *ord
+	for f, v := range masked.Delivery.Fields() {
+		*v = p.masker.Value(f, *v)
+	}
+
+	return &masked
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"first-task/internal/config"
+	"first-task/internal/web-app/auth"
+	"first-task/internal/web-app/ratelimit"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+var StatusTooManyRequests = "too many requests"
+
+// RateLimit limits requests of client to route by token bucket, client is
+// authenticated principal or ip. If config is wrong throw panic.
+func RateLimit(
+	l ratelimit.Limiter, rl config.RateLimitConfig, ipHeader string, next http.HandlerFunc,
+) http.HandlerFunc {
+	if rl.Rate <= 0 || rl.Burst < 1 {
+		panic(fmt.Sprintf("rate limit of %s needs rate > 0 and burst >= 1", rl.Route))
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := rl.Route + "|" + clientKey(r, ipHeader)
+		ok, retry, err := l.Allow(r.Context(), key, rl.Rate, rl.Burst)
+		if err != nil {
+			// broken limiter doesn't stop the api
+			zap.L().Warn("rate limiter: " + err.Error())
+			next(w, r)
+			return
+		}
+		if !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(max(1, int(math.Ceil(retry.Seconds())))))
+			writeError(w, http.StatusTooManyRequests, StatusTooManyRequests)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+func clientKey(r *http.Request, ipHeader string) string {
+	if p, ok := auth.FromContext(r.Context()); ok {
+		return p.Subject
+	}
+
+	if ipHeader != "" {
+		// the last address is added by our proxy, others are sent by client
+		if v := r.Header.Get(ipHeader); v != "" {
+			ips := strings.Split(v, ",")
+			return "ip:" + strings.TrimSpace(ips[len(ips)-1])
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"first-task/internal/config"
+	"first-task/internal/web-app/auth"
+	"first-task/internal/web-app/ratelimit"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type brokenLimiter struct{}
+
+func (brokenLimiter) Allow(context.Context, string, float64, int) (bool, time.Duration, error) {
+	return false, 0, errors.New("redis is down")
+}
+
+func TestRateLimit(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	rl := config.RateLimitConfig{Route: "GET /order/{order_uid}", Rate: 0.5, Burst: 1}
+	a := testAuth()
+	h := Identify(a, RateLimit(
+		ratelimit.NewMemory(), rl, "X-Real-IP", RequireScope(a, auth.ScopeOrdersRead, ok),
+	))
+
+	tests := []struct {
+		Name  string
+		IP    string
+		Token string
+		Code  int
+	}{
+		// wrong credentials are rejected after limit, so guessing is limited
+		{Name: "first of ip", IP: "10.0.0.1", Code: http.StatusUnauthorized},
+		{Name: "second of ip", IP: "10.0.0.1", Token: "wrong", Code: http.StatusTooManyRequests},
+		{Name: "other ip", IP: "10.0.0.2", Token: "wrong", Code: http.StatusUnauthorized},
+		{Name: "proxy chain", IP: "1.1.1.1, 10.0.0.1", Code: http.StatusTooManyRequests},
+		{Name: "client behind limited ip", IP: "10.0.0.1", Token: adminToken, Code: http.StatusOK},
+		{Name: "client again", IP: "10.0.0.3", Token: adminToken, Code: http.StatusTooManyRequests},
+	}
+
+	for _, v := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/order/test", nil)
+		r.Header.Set("X-Real-IP", v.IP)
+		if v.Token != "" {
+			r.Header.Set("Authorization", "Bearer "+v.Token)
+		}
+		w := httptest.NewRecorder()
+		h(w, r)
+
+		if w.Code != v.Code {
+			t.Errorf("%s: wrong response \nget: %d\nwait: %d", v.Name, w.Code, v.Code)
+		}
+		if w.Code == http.StatusTooManyRequests && w.Header().Get("Retry-After") != "2" {
+			t.Errorf("%s: wrong Retry-After %q", v.Name, w.Header().Get("Retry-After"))
+		}
+	}
+
+	// api works while limiter is broken
+	w := httptest.NewRecorder()
+	RateLimit(brokenLimiter{}, rl, "", ok)(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("request is rejected by broken limiter: %d", w.Code)
+	}
+}
@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter is token bucket per key: bucket holds up to burst tokens, gets
+// rate tokens per second and every request takes one
+type Limiter interface {
+	// Allow returns false and time until the next token if bucket is empty
+	Allow(ctx context.Context, key string, rate float64, burst int) (bool, time.Duration, error)
+}
+
+// buckets which are full are forgotten on sweep
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	// time when bucket gets full again
+	full time.Time
+}
+
+// Memory keeps buckets of one app instance
+type Memory struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+
+	now func() time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+func (m *Memory) Allow(_ context.Context, key string, rate float64, burst int) (bool, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	if now.Sub(m.lastSweep) > sweepInterval {
+		m.sweep(now)
+	}
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(burst), last: now}
+		m.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	b.full = now.Add(wait(float64(burst)-b.tokens, rate))
+
+	if !allowed {
+		return false, wait(1-b.tokens, rate), nil
+	}
+	return true, 0, nil
+}
+
+func (m *Memory) sweep(now time.Time) {
+	for k, b := range m.buckets {
+		if now.After(b.full) {
+			delete(m.buckets, k)
+		}
+	}
+	m.lastSweep = now
+}
+
+// wait returns time to get tokens
+func wait(tokens, rate float64) time.Duration {
+	if rate <= 0 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(math.Ceil(tokens / rate * float64(time.Second)))
+}
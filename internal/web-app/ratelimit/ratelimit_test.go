@@ -0,0 +1,53 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemory(t *testing.T) {
+	now := time.Unix(0, 0)
+	m := NewMemory()
+	m.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	// burst is available at once
+	for i := 0; i < 3; i++ {
+		if ok, _, _ := m.Allow(ctx, "a", 2, 3); !ok {
+			t.Fatalf("request %d of burst is rejected", i)
+		}
+	}
+	ok, retry, _ := m.Allow(ctx, "a", 2, 3)
+	if ok || retry != 500*time.Millisecond {
+		t.Errorf("empty bucket: allowed %v, retry after %s", ok, retry)
+	}
+
+	// other key has own bucket
+	if ok, _, _ := m.Allow(ctx, "b", 2, 3); !ok {
+		t.Error("bucket of other key is empty")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if ok, _, _ := m.Allow(ctx, "a", 2, 3); !ok {
+		t.Error("token isn't added after 1/rate")
+	}
+	if ok, _, _ := m.Allow(ctx, "a", 2, 3); ok {
+		t.Error("more tokens than rate")
+	}
+
+	// full buckets are forgotten, empty ones are kept
+	now = now.Add(time.Hour)
+	m.Allow(ctx, "c", 0.0001, 1)
+	now = now.Add(2 * time.Minute)
+	m.Allow(ctx, "d", 1, 1)
+	if _, ok := m.buckets["a"]; ok {
+		t.Error("full bucket isn't swept")
+	}
+	if _, ok := m.buckets["c"]; !ok {
+		t.Error("empty bucket is swept")
+	}
+	if ok, _, _ := m.Allow(ctx, "c", 0.0001, 1); ok {
+		t.Error("swept bucket gives burst again")
+	}
+}
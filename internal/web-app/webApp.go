@@ -4,35 +4,89 @@ import (
 	"context"
 	"errors"
 	"first-task/internal/config"
+	"first-task/internal/metrics"
+	"first-task/internal/web-app/auth"
 	"first-task/internal/web-app/handlers"
+	"first-task/internal/web-app/ratelimit"
 	"fmt"
 	"net/http"
+	"sync/atomic"
 
 	httpSwager "github.com/swaggo/http-swagger"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"go.uber.org/zap"
 )
 
 type WebApp struct {
 	server *http.Server
+	// requests in processing
+	inFlight atomic.Int64
 }
 
 func NewWebApp() *WebApp {
 	return &WebApp{}
 }
 
-func (wa *WebApp) CreateServer(str handlers.OrderGetter, cw config.WebConfig) {
+type OrderStorage interface {
+	handlers.OrderGetter
+	handlers.OrderEraser
+}
+
+func (wa *WebApp) CreateServer(
+	str OrderStorage, cfg *config.Config, lim ratelimit.Limiter, rc handlers.ReadinessChecker,
+) {
+	cw := cfg.WebConfig
 	mux := http.NewServeMux()
+	pv := handlers.NewPrivacy(cfg.PrivacyConfig)
+	// nil without keys, api is open then
+	au := auth.NewAuthenticator(cfg.AuthConfig, cw.AdminToken)
+
+	limits := make(map[string]config.RateLimitConfig, len(cw.RateLimits))
+	for _, rl := range cw.RateLimits {
+		limits[rl.Route] = rl
+	}
+	// limit is checked before authentication, so guessing of credentials is
+	// limited too: per api key or token for known clients, per ip for others
+	route := func(pattern, scope string, h http.HandlerFunc) {
+		if scope != "" {
+			h = handlers.RequireScope(au, scope, h)
+		}
+		if rl, ok := limits[pattern]; ok {
+			h = handlers.Identify(au, handlers.RateLimit(lim, rl, cw.ClientIPHeader, h))
+			delete(limits, pattern)
+		}
+		// span of request is named by route, spans of storage are its children
+		mux.Handle(pattern, otelhttp.NewHandler(handlers.Metrics(pattern, h), pattern))
+	}
 
 	// swagger
-	mux.HandleFunc("/swagger/", httpSwager.WrapHandler)
+	route("/swagger/", "", httpSwager.WrapHandler)
+	mux.Handle("GET /metrics", metrics.Handler())
+	// probes are frequent, they aren't traced and limited
+	mux.HandleFunc("GET /healthz", handlers.Healthz())
+	mux.HandleFunc("GET /readyz", handlers.Readyz(rc))
+
+	route("GET /order/{order_uid}", auth.ScopeOrdersRead, handlers.FindOrderAPI(str, pv))
+	// pages are for browsers without credentials, data on them is masked
+	route("GET /find-order", "", handlers.FindOrder(str, pv))
+	route("/", "", handlers.MainPage())
 
-	mux.HandleFunc("GET /order/{order_uid}", handlers.FindOrderAPI(str))
-	mux.HandleFunc("GET /find-order", handlers.FindOrder(str))
-	mux.HandleFunc("/", handlers.MainPage())
+	// admin api is off without authentication
+	if au != nil {
+		route("DELETE /admin/orders/{order_uid}", auth.ScopeAdmin, handlers.DeleteOrderAPI(str))
+		route(
+			"POST /admin/customers/{customer_id}/erase", auth.ScopeAdmin,
+			handlers.EraseCustomerAPI(str),
+		)
+	}
+
+	for pattern := range limits {
+		zap.L().Warn("rate limit of unknown route " + pattern)
+	}
 
 	wa.server = &http.Server{
 		Addr:         fmt.Sprintf("%s:%s", cw.Host, cw.Port),
-		Handler:      mux,
+		Handler:      wa.track(mux),
 		ReadTimeout:  cw.ReadTimeout,
 		WriteTimeout: cw.WriteTimeout,
 	}
@@ -45,8 +99,28 @@ func (wa *WebApp) StartServer() {
 	}
 }
 
-func (wa *WebApp) Shutdown() {
-	if err := wa.server.Shutdown(context.Background()); err != nil {
-		zap.L().Error("Error on shutdown server(http): " + err.Error())
+func (wa *WebApp) track(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		wa.inFlight.Add(1)
+		defer wa.inFlight.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Shutdown stops accepting connections and waits for requests in processing
+// until ctx is done, then closes connections of abandoned ones
+func (wa *WebApp) Shutdown(ctx context.Context) error {
+	const op = "internal.web-app.Shutdown"
+
+	err := wa.server.Shutdown(ctx)
+	if err == nil {
+		return nil
 	}
+
+	n := wa.inFlight.Load()
+	if cerr := wa.server.Close(); cerr != nil {
+		zap.L().Error("Error on closing server(http): " + cerr.Error())
+	}
+
+	return fmt.Errorf("%s: %d requests are abandoned: %w", op, n, err)
 }
@@ -0,0 +1,71 @@
+-- +goose Up
+-- orders are partitioned by month of created_at (time of insert),
+-- partitions are created and removed by partition manager of the app.
+-- Partition key must be part of every unique constraint, so order_uid is
+-- unique only inside one partition and orders_items can't reference orders.
+ALTER TABLE orders RENAME TO orders_old;
+ALTER TABLE orders_items DROP CONSTRAINT IF EXISTS orders_items_order_id_fkey;
+ALTER SEQUENCE orders_id_seq OWNED BY NONE;
+
+CREATE TABLE orders (
+    LIKE orders_old INCLUDING DEFAULTS,
+    created_at timestamptz not null default now(),
+    primary key (id, created_at),
+    FOREIGN KEY (delivery_id) REFERENCES delivery_info(id) ON DELETE CASCADE,
+    FOREIGN KEY (payment_id) REFERENCES payment_info(id) ON DELETE CASCADE
+) PARTITION BY RANGE (created_at);
+
+ALTER SEQUENCE orders_id_seq OWNED BY orders.id;
+
+CREATE TABLE orders_default PARTITION OF orders DEFAULT;
+
+-- +goose StatementBegin
+DO $$
+DECLARE
+    month_start timestamptz := date_trunc('month', now() at time zone 'UTC') at time zone 'UTC';
+    next_start timestamptz;
+BEGIN
+    -- current and next month, later ones are created by the app
+    FOR i IN 0..1 LOOP
+        next_start := month_start + interval '1 month';
+        EXECUTE format(
+            'CREATE TABLE IF NOT EXISTS %I PARTITION OF orders FOR VALUES FROM (%L) TO (%L)',
+            'orders_p' || to_char(month_start at time zone 'UTC', 'YYYYMM'),
+            month_start, next_start
+        );
+        month_start := next_start;
+    END LOOP;
+END
+$$;
+-- +goose StatementEnd
+
+INSERT INTO orders SELECT o.*, now() FROM orders_old AS o;
+DROP TABLE orders_old;
+
+CREATE INDEX orders_order_uid_idx ON orders (order_uid);
+CREATE INDEX orders_id_idx ON orders (id);
+CREATE INDEX orders_items_order_id_idx ON orders_items (order_id);
+
+-- +goose Down
+ALTER TABLE orders RENAME TO orders_partitioned;
+ALTER SEQUENCE orders_id_seq OWNED BY NONE;
+
+CREATE TABLE orders (
+    LIKE orders_partitioned INCLUDING DEFAULTS,
+    primary key (id),
+    unique (order_uid),
+    FOREIGN KEY (delivery_id) REFERENCES delivery_info(id) ON DELETE CASCADE,
+    FOREIGN KEY (payment_id) REFERENCES payment_info(id) ON DELETE CASCADE
+);
+ALTER TABLE orders DROP COLUMN created_at;
+ALTER SEQUENCE orders_id_seq OWNED BY orders.id;
+
+INSERT INTO orders
+SELECT id, order_uid, track_number, entry, delivery_id, payment_id, locale,
+    internal_signature, customer_id, delivery_service, shardkey, sm_id,
+    date_created, oof_shard
+FROM orders_partitioned;
+DROP TABLE orders_partitioned;
+
+DROP INDEX IF EXISTS orders_items_order_id_idx;
+ALTER TABLE orders_items ADD FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE;
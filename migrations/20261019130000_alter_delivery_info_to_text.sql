@@ -0,0 +1,22 @@
+-- +goose Up
+-- encrypted values are several times longer than plain ones
+ALTER TABLE delivery_info
+    ALTER COLUMN name TYPE text,
+    ALTER COLUMN phone TYPE text,
+    ALTER COLUMN zip TYPE text,
+    ALTER COLUMN city TYPE text,
+    ALTER COLUMN address TYPE text,
+    ALTER COLUMN region TYPE text,
+    ALTER COLUMN email TYPE text;
+
+-- +goose Down
+-- fails while encrypted values are stored, decrypt them before with
+-- empty encryption.fields and cmd/reencrypt
+ALTER TABLE delivery_info
+    ALTER COLUMN name TYPE varchar(255),
+    ALTER COLUMN phone TYPE varchar(100),
+    ALTER COLUMN zip TYPE varchar(100),
+    ALTER COLUMN city TYPE varchar(255),
+    ALTER COLUMN address TYPE varchar(255),
+    ALTER COLUMN region TYPE varchar(255),
+    ALTER COLUMN email TYPE varchar(255);
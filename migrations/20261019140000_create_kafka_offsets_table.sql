@@ -0,0 +1,13 @@
+-- +goose Up
+-- positions of consumer with offset_store postgres, next_offset is the next
+-- message to read and is saved in the same transaction as order
+CREATE TABLE kafka_offsets (
+    topic text not null,
+    partition_id int not null,
+    next_offset bigint not null,
+    updated_at timestamptz not null default now(),
+    primary key (topic, partition_id)
+);
+
+-- +goose Down
+DROP TABLE kafka_offsets;
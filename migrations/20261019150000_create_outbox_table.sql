@@ -0,0 +1,13 @@
+-- +goose Up
+-- events saved with orders, rows are deleted by relay after publishing
+CREATE TABLE outbox (
+    id bigserial primary key,
+    event_type text not null,
+    event_key text not null,
+    payload jsonb not null,
+    trace_context jsonb not null default '{}',
+    created_at timestamptz not null default now()
+);
+
+-- +goose Down
+DROP TABLE outbox;
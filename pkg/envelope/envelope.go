@@ -0,0 +1,228 @@
+package envelope
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// encrypted value is "enc:v1:<key id>:<wrapped data key>:<ciphertext>",
+// every value has own data key wrapped by key of keyring
+const (
+	prefix  = "enc:v1:"
+	KeySize = 32
+)
+
+var (
+	ErrUnknownKey = errors.New("unknown key id")
+	ErrBadValue   = errors.New("malformed encrypted value")
+)
+
+var b64 = base64.RawStdEncoding
+
+// KeyFile is json file of keyring, new values are encrypted by primary key,
+// other keys are kept to decrypt old values
+type KeyFile struct {
+	Primary string `json:"primary"`
+	Keys    []Key  `json:"keys"`
+}
+
+type Key struct {
+	ID string `json:"id"`
+	// base64 of 32 random bytes
+	Key string `json:"key"`
+}
+
+type Keyring struct {
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+func LoadKeyring(path string) (*Keyring, error) {
+	const op = "pkg.envelope.LoadKeyring"
+
+	kf, err := ReadKeyFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	kr, err := NewKeyring(kf)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s: %w", op, path, err)
+	}
+	return kr, nil
+}
+
+func NewKeyring(kf *KeyFile) (*Keyring, error) {
+	kr := &Keyring{primary: kf.Primary, keys: make(map[string]cipher.AEAD, len(kf.Keys))}
+	for _, k := range kf.Keys {
+		if k.ID == "" || strings.Contains(k.ID, ":") {
+			return nil, fmt.Errorf("wrong key id %q", k.ID)
+		}
+		raw, err := base64.StdEncoding.DecodeString(k.Key)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", k.ID, err)
+		}
+		if len(raw) != KeySize {
+			return nil, fmt.Errorf("key %s: size is %d, need %d", k.ID, len(raw), KeySize)
+		}
+		aead, err := newAEAD(raw)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", k.ID, err)
+		}
+		kr.keys[k.ID] = aead
+	}
+	if _, ok := kr.keys[kr.primary]; !ok {
+		return nil, fmt.Errorf("%w: primary %q", ErrUnknownKey, kr.primary)
+	}
+	return kr, nil
+}
+
+func ReadKeyFile(path string) (*KeyFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	kf := new(KeyFile)
+	if err := json.Unmarshal(data, kf); err != nil {
+		return nil, err
+	}
+	return kf, nil
+}
+
+func (kf *KeyFile) Write(path string) error {
+	data, err := json.MarshalIndent(kf, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0600)
+}
+
+// Rotate adds new random key and makes it primary
+func (kf *KeyFile) Rotate(id string) error {
+	for _, k := range kf.Keys {
+		if k.ID == id {
+			return fmt.Errorf("key %s already exists", id)
+		}
+	}
+	raw := make([]byte, KeySize)
+	if _, err := rand.Read(raw); err != nil {
+		return err
+	}
+	kf.Keys = append(kf.Keys, Key{ID: id, Key: base64.StdEncoding.EncodeToString(raw)})
+	kf.Primary = id
+	return nil
+}
+
+func (kr *Keyring) Primary() string {
+	return kr.primary
+}
+
+func IsEncrypted(v string) bool {
+	return strings.HasPrefix(v, prefix)
+}
+
+// KeyID returns id of key which encrypted v, empty for plain values
+func KeyID(v string) string {
+	rest, ok := strings.CutPrefix(v, prefix)
+	if !ok {
+		return ""
+	}
+	id, _, _ := strings.Cut(rest, ":")
+	return id
+}
+
+func (kr *Keyring) Encrypt(plain string) (string, error) {
+	dataKey := make([]byte, KeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+	data, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	wrapped, err := seal(kr.keys[kr.primary], dataKey, []byte(kr.primary))
+	if err != nil {
+		return "", err
+	}
+	ct, err := seal(data, []byte(plain), nil)
+	if err != nil {
+		return "", err
+	}
+
+	return prefix + kr.primary + ":" + b64.EncodeToString(wrapped) + ":" + b64.EncodeToString(ct), nil
+}
+
+// Decrypt returns plain values as is
+func (kr *Keyring) Decrypt(v string) (string, error) {
+	rest, ok := strings.CutPrefix(v, prefix)
+	if !ok {
+		return v, nil
+	}
+
+	parts := strings.Split(rest, ":")
+	if len(parts) != 3 {
+		return "", ErrBadValue
+	}
+	kek, ok := kr.keys[parts[0]]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownKey, parts[0])
+	}
+	wrapped, err := b64.DecodeString(parts[1])
+	if err != nil {
+		return "", ErrBadValue
+	}
+	ct, err := b64.DecodeString(parts[2])
+	if err != nil {
+		return "", ErrBadValue
+	}
+
+	dataKey, err := open(kek, wrapped, []byte(parts[0]))
+	if err != nil {
+		return "", err
+	}
+	data, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	plain, err := open(data, ct, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal returns nonce with ciphertext
+func seal(aead cipher.AEAD, plain, ad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plain)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plain, ad), nil
+}
+
+func open(aead cipher.AEAD, data, ad []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, ErrBadValue
+	}
+	nonce, ct := data[:aead.NonceSize()], data[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ct, ad)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrBadValue, err.Error())
+	}
+	return plain, nil
+}
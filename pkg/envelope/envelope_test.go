@@ -0,0 +1,111 @@
+package envelope
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newKeyFile(t *testing.T, ids ...string) *KeyFile {
+	t.Helper()
+	kf := new(KeyFile)
+	for _, id := range ids {
+		if err := kf.Rotate(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return kf
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	kr, err := NewKeyring(newKeyFile(t, "k1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []string{"", "+79001110000", "Тест Тестов", "a:b:c"} {
+		enc, err := kr.Encrypt(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !IsEncrypted(enc) || KeyID(enc) != "k1" || (v != "" && strings.Contains(enc, v)) {
+			t.Errorf("wrong encrypted value of %q: %s", v, enc)
+		}
+
+		dec, err := kr.Decrypt(enc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dec != v {
+			t.Errorf("wrong decrypted value\nwait: %q\nget: %q", v, dec)
+		}
+	}
+
+	if v, err := kr.Decrypt("plain"); err != nil || v != "plain" {
+		t.Errorf("plain value is changed: %q, %v", v, err)
+	}
+	enc, _ := kr.Encrypt("test")
+	if _, err := kr.Decrypt(enc[:len(enc)-2]); !errors.Is(err, ErrBadValue) {
+		t.Errorf("broken value is decrypted: %v", err)
+	}
+}
+
+func TestRotation(t *testing.T) {
+	kf := newKeyFile(t, "k1")
+	old, err := NewKeyring(kf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := old.Encrypt("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "keys.json")
+	if err := kf.Rotate("k2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := kf.Rotate("k2"); err == nil {
+		t.Error("key with existing id is added")
+	}
+	if err := kf.Write(path); err != nil {
+		t.Fatal(err)
+	}
+
+	kr, err := LoadKeyring(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kr.Primary() != "k2" {
+		t.Errorf("wrong primary key: %s", kr.Primary())
+	}
+	if dec, err := kr.Decrypt(enc); err != nil || dec != "test" {
+		t.Errorf("value of old key isn't decrypted: %q, %v", dec, err)
+	}
+	if _, err := old.Decrypt(mustEncrypt(t, kr, "test")); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("wrong error for unknown key: %v", err)
+	}
+}
+
+func TestWrongKeyFile(t *testing.T) {
+	tests := []*KeyFile{
+		{Primary: "k1"},
+		{Primary: "k1", Keys: []Key{{ID: "k1", Key: "c2hvcnQ="}}},
+		{Primary: "k:1", Keys: newKeyFile(t, "k:1").Keys},
+	}
+	for i, kf := range tests {
+		if _, err := NewKeyring(kf); err == nil {
+			t.Errorf("%d: wrong key file is loaded", i)
+		}
+	}
+}
+
+func mustEncrypt(t *testing.T, kr *Keyring, v string) string {
+	t.Helper()
+	enc, err := kr.Encrypt(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return enc
+}
@@ -0,0 +1,53 @@
+package logger
+
+import (
+	"first-task/pkg/mask"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Redact masks sensitive fields, emails and phones in messages and fields
+// of every entry before it reaches any output of logger
+func Redact(m *mask.Masker) zap.Option {
+	return zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return &redactCore{Core: c, m: m}
+	})
+}
+
+type redactCore struct {
+	zapcore.Core
+	m *mask.Masker
+}
+
+func (rc *redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactCore{Core: rc.Core.With(rc.fields(fields)), m: rc.m}
+}
+
+func (rc *redactCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if rc.Enabled(e.Level) {
+		return ce.AddCore(e, rc)
+	}
+	return ce
+}
+
+func (rc *redactCore) Write(e zapcore.Entry, fields []zapcore.Field) error {
+	e.Message = rc.m.Text(e.Message)
+	return rc.Core.Write(e, rc.fields(fields))
+}
+
+func (rc *redactCore) fields(fields []zapcore.Field) []zapcore.Field {
+	out := make([]zapcore.Field, len(fields))
+	for i, f := range fields {
+		switch f.Type {
+		case zapcore.StringType:
+			f.String = rc.m.Text(rc.m.Value(f.Key, f.String))
+		case zapcore.ErrorType:
+			if err, ok := f.Interface.(error); ok {
+				f = zap.String(f.Key, rc.m.Text(err.Error()))
+			}
+		}
+		out[i] = f
+	}
+	return out
+}
@@ -0,0 +1,44 @@
+package logger
+
+import (
+	"errors"
+	"first-task/pkg/mask"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRedact(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	l := zap.New(core, Redact(mask.New([]string{"name", "phone", "email"})))
+
+	l.With(zap.String("name", "Test Testov")).Info(
+		"order of test@gmail.com",
+		zap.String("phone", "+79001110000"),
+		zap.String("city", "Moscow"),
+		zap.Error(errors.New("bad phone +79001110000")),
+	)
+
+	entries := logs.All()
+	if len(entries) != 1 {
+		t.Fatalf("wrong count of entries: %d", len(entries))
+	}
+	e := entries[0]
+	if e.Message != "order of t***@gmail.com" {
+		t.Errorf("message isn't masked: %s", e.Message)
+	}
+
+	wait := map[string]string{
+		"name":  "T***",
+		"phone": "+7900***0000",
+		"city":  "Moscow",
+		"error": "bad phone +7900***0000",
+	}
+	get := e.ContextMap()
+	for k, v := range wait {
+		if get[k] != v {
+			t.Errorf("wrong field %s\nwait: %s\nget: %v", k, v, get[k])
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package mask
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// names of fields are json names of delivery
+const (
+	FieldName    = "name"
+	FieldPhone   = "phone"
+	FieldZip     = "zip"
+	FieldCity    = "city"
+	FieldAddress = "address"
+	FieldRegion  = "region"
+	FieldEmail   = "email"
+)
+
+var Fields = []string{
+	FieldName, FieldPhone, FieldZip, FieldCity, FieldAddress, FieldRegion, FieldEmail,
+}
+
+const stars = "***"
+
+var (
+	emailRe = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	phoneRe = regexp.MustCompile(`\+[1-9]\d{6,14}`)
+)
+
+type Masker struct {
+	fields map[string]bool
+}
+
+// if field is unknown throw panic
+func New(fields []string) *Masker {
+	m := &Masker{fields: make(map[string]bool, len(fields))}
+	for _, f := range fields {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if f == "" {
+			continue
+		}
+		if !known(f) {
+			panic(fmt.Sprintf("unknown sensitive field %q, known are %v", f, Fields))
+		}
+		m.fields[f] = true
+	}
+	return m
+}
+
+func known(field string) bool {
+	for _, f := range Fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Masker) Sensitive(field string) bool {
+	return m.fields[field]
+}
+
+// Value masks v if field is sensitive
+func (m *Masker) Value(field, v string) string {
+	if !m.Sensitive(field) || v == "" {
+		return v
+	}
+
+	switch field {
+	case FieldPhone:
+		return Phone(v)
+	case FieldEmail:
+		return Email(v)
+	}
+	return Other(v)
+}
+
+// Text masks emails and phones of sensitive fields inside free text,
+// names and addresses can't be found in text and have to be kept out of it
+func (m *Masker) Text(s string) string {
+	if m.Sensitive(FieldEmail) {
+		s = emailRe.ReplaceAllStringFunc(s, Email)
+	}
+	if m.Sensitive(FieldPhone) {
+		s = phoneRe.ReplaceAllStringFunc(s, Phone)
+	}
+	return s
+}
+
+// Phone keeps country with operator code and last 4 digits: +7900***0000
+func Phone(v string) string {
+	r := []rune(v)
+	if len(r) < 10 {
+		return stars
+	}
+	return string(r[:5]) + stars + string(r[len(r)-4:])
+}
+
+// Email keeps first letter and domain: t***@gmail.com
+func Email(v string) string {
+	local, domain, ok := strings.Cut(v, "@")
+	if !ok || local == "" {
+		return Other(v)
+	}
+	return string([]rune(local)[:1]) + stars + "@" + domain
+}
+
+// Other keeps first letter only
+func Other(v string) string {
+	r := []rune(v)
+	if len(r) < 2 {
+		return stars
+	}
+	return string(r[:1]) + stars
+}
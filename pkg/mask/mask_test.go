@@ -0,0 +1,61 @@
+package mask
+
+import (
+	"testing"
+)
+
+func TestMasks(t *testing.T) {
+	tests := []struct {
+		Fn   func(string) string
+		Arg  string
+		Wait string
+	}{
+		{Phone, "+79001110000", "+7900***0000"},
+		{Phone, "+9720000000", "+9720***0000"},
+		{Phone, "+123", "***"},
+		{Email, "test@gmail.com", "t***@gmail.com"},
+		{Email, "тест@почта.рф", "т***@почта.рф"},
+		{Email, "not email", "n***"},
+		{Other, "Test Testov", "T***"},
+		{Other, "a", "***"},
+	}
+
+	for _, v := range tests {
+		if get := v.Fn(v.Arg); get != v.Wait {
+			t.Errorf("wrong mask of %s\nwait: %s\nget: %s", v.Arg, v.Wait, get)
+		}
+	}
+}
+
+func TestMasker(t *testing.T) {
+	m := New([]string{"phone", " Email ", "name"})
+
+	if v := m.Value(FieldCity, "Moscow"); v != "Moscow" {
+		t.Errorf("not sensitive field is masked: %s", v)
+	}
+	if v := m.Value(FieldName, "Test Testov"); v != "T***" {
+		t.Errorf("wrong masked name: %s", v)
+	}
+	if v := m.Value(FieldEmail, "test@gmail.com"); v != "t***@gmail.com" {
+		t.Errorf("wrong masked email: %s", v)
+	}
+
+	text := "order of test@gmail.com with phone +79001110000 isn't valid"
+	wait := "order of t***@gmail.com with phone +7900***0000 isn't valid"
+	if get := m.Text(text); get != wait {
+		t.Errorf("wrong masked text\nwait: %s\nget: %s", wait, get)
+	}
+
+	if get := New(nil).Text(text); get != text {
+		t.Errorf("text is masked without sensitive fields: %s", get)
+	}
+}
+
+func TestUnknownField(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("waited panic on unknown field")
+		}
+	}()
+	New([]string{"passport"})
+}
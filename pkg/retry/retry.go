@@ -0,0 +1,119 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	DefaultInitial    = 100 * time.Millisecond
+	DefaultMultiplier = 2
+)
+
+var ErrExhausted = errors.New("retries are exhausted")
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err of fn which isn't worth retrying, Do returns err as is
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// Policy is exponential backoff with jitter, zero MaxAttempts and MaxElapsed
+// mean without limit
+type Policy struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+	// share of delay which is random, 0.2 gives delay in [0.8d, 1.2d]
+	Jitter      float64
+	MaxAttempts int
+	MaxElapsed  time.Duration
+}
+
+// Delay returns pause after failed attempt, attempts start from 1
+func (p Policy) Delay(attempt int) time.Duration {
+	initial, mult := p.Initial, p.Multiplier
+	if initial <= 0 {
+		initial = DefaultInitial
+	}
+	if mult < 1 {
+		mult = DefaultMultiplier
+	}
+
+	// without Max delay is limited by Duration, bigger float overflows it
+	limit := float64(math.MaxInt64)
+	if p.Max > 0 {
+		limit = float64(p.Max)
+	}
+
+	d := float64(initial)
+	for i := 1; i < attempt && d < limit; i++ {
+		d *= mult
+	}
+	d = min(d, limit)
+	if p.Jitter > 0 {
+		j := min(p.Jitter, 1)
+		d *= 1 - j + 2*j*rand.Float64()
+	}
+	if d >= math.MaxInt64 {
+		return math.MaxInt64
+	}
+
+	return time.Duration(d)
+}
+
+// Do calls fn until it succeeds, ctx is done or policy is exhausted. Error of
+// exhausted policy wraps ErrExhausted and last error of fn, error of done ctx
+// wraps ctx.Err().
+func (p Policy) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("%w: %w", ctx.Err(), err)
+		}
+		var perr *permanentError
+		if errors.As(err, &perr) {
+			return perr.err
+		}
+		if p.MaxAttempts > 0 && attempt >= p.MaxAttempts {
+			return fmt.Errorf("%w after %d attempts: %w", ErrExhausted, attempt, err)
+		}
+
+		d := p.Delay(attempt)
+		if p.MaxElapsed > 0 && time.Since(start)+d > p.MaxElapsed {
+			return fmt.Errorf("%w after %s: %w", ErrExhausted, time.Since(start).Round(time.Millisecond), err)
+		}
+		if err := sleep(ctx, d); err != nil {
+			return err
+		}
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,100 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestDelay(t *testing.T) {
+	p := Policy{Initial: time.Second, Max: 5 * time.Second, Multiplier: 2}
+	tests := map[int]time.Duration{
+		1:  time.Second,
+		2:  2 * time.Second,
+		3:  4 * time.Second,
+		4:  5 * time.Second,
+		50: 5 * time.Second,
+	}
+	for attempt, wait := range tests {
+		if d := p.Delay(attempt); d != wait {
+			t.Errorf("attempt %d: wrong delay\nwait: %s\nget: %s", attempt, wait, d)
+		}
+	}
+
+	p.Jitter = 0.2
+	for i := 0; i < 100; i++ {
+		if d := p.Delay(2); d < 1600*time.Millisecond || d > 2400*time.Millisecond {
+			t.Fatalf("delay out of jitter range: %s", d)
+		}
+	}
+}
+
+func TestDelayWithoutMax(t *testing.T) {
+	p := Policy{Initial: time.Second, Multiplier: 2}
+	if d := p.Delay(10); d != 512*time.Second {
+		t.Errorf("wrong delay: %s", d)
+	}
+
+	for _, attempt := range []int{64, 1000, math.MaxInt32} {
+		if d := p.Delay(attempt); d != math.MaxInt64 {
+			t.Errorf("attempt %d: delay isn't clamped: %s", attempt, d)
+		}
+	}
+
+	p.Jitter = 0.5
+	if d := p.Delay(1000); d <= 0 {
+		t.Errorf("delay with jitter overflows: %s", d)
+	}
+}
+
+func TestDo(t *testing.T) {
+	errDown := errors.New("db is down")
+	failing := func(n *int, failures int) func(context.Context) error {
+		return func(context.Context) error {
+			*n++
+			if *n <= failures {
+				return errDown
+			}
+			return nil
+		}
+	}
+
+	var n int
+	p := Policy{Initial: time.Millisecond, MaxAttempts: 3}
+	if err := p.Do(context.Background(), failing(&n, 2)); err != nil || n != 3 {
+		t.Errorf("success on last attempt: err %v, attempts %d", err, n)
+	}
+
+	n = 0
+	err := p.Do(context.Background(), failing(&n, 10))
+	if !errors.Is(err, ErrExhausted) || !errors.Is(err, errDown) || n != 3 {
+		t.Errorf("max attempts: err %v, attempts %d", err, n)
+	}
+
+	n = 0
+	p = Policy{Initial: 20 * time.Millisecond, MaxElapsed: 50 * time.Millisecond}
+	if err := p.Do(context.Background(), failing(&n, 100)); !errors.Is(err, ErrExhausted) || n > 3 {
+		t.Errorf("max elapsed: err %v, attempts %d", err, n)
+	}
+
+	n = 0
+	p = Policy{Initial: time.Millisecond, MaxAttempts: 3}
+	err = p.Do(context.Background(), func(context.Context) error {
+		n++
+		return Permanent(errDown)
+	})
+	if err != errDown || n != 1 {
+		t.Errorf("permanent error is retried: err %v, attempts %d", err, n)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	p = Policy{Initial: time.Hour}
+	err = p.Do(ctx, failing(&n, 100))
+	if !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > time.Second {
+		t.Errorf("canceled ctx doesn't stop retries: %v", err)
+	}
+}